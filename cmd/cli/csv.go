@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/log"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/personcsv"
+)
+
+func exportCSV(log *log.Logger, args []string) error {
+	fs, dsn := newFlagSet("export-csv")
+	ownerID := fs.Int("owner", users.DefaultID, "Id of the user whose persons are exported")
+	output := fs.String("o", "-", "File to write, - for standard output")
+	fs.Parse(args)
+
+	database, err := openDB(*dsn, log)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	w := io.Writer(os.Stdout)
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	ctx := owner.WithID(context.Background(), *ownerID)
+	return personcsv.Export(ctx, w, person.NewRepository(database))
+}
+
+func importCSV(log *log.Logger, args []string) error {
+	fs, dsn := newFlagSet("import-csv")
+	ownerID := fs.Int("owner", users.DefaultID, "Id of the user the persons are imported for")
+	file := fs.String("file", "", "CSV file to import, - for standard input")
+	mapping := fs.String("map", "", "Columns to import as field=column pairs separated by commas, guessed from the header if empty")
+	dryRun := fs.Bool("dry-run", false, "Report what would be imported without saving anything")
+	fs.Parse(args)
+
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if *file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+
+	header, _, err := personcsv.ReadHeader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	m := personcsv.GuessMapping(header)
+	if *mapping != "" {
+		if m, err = personcsv.ParseMapping(*mapping, header); err != nil {
+			return err
+		}
+	}
+
+	database, err := openDB(*dsn, log)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	importer := personcsv.NewImporter(database, person.NewRepository(database))
+
+	ctx := owner.WithID(context.Background(), *ownerID)
+	result, err := importer.Import(ctx, bytes.NewReader(data), m, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	writeImportReport(os.Stdout, result)
+
+	if result.Invalid() > 0 {
+		return fmt.Errorf("%d rows are invalid, nothing was imported", result.Invalid())
+	}
+
+	return nil
+}
+
+// writeImportReport lists the rows that weren't imported and why, followed
+// by the totals.
+func writeImportReport(w io.Writer, result *personcsv.Result) {
+	for _, row := range result.Rows {
+		for _, e := range row.Errors {
+			fmt.Fprintf(w, "line %d: %s\n", row.Line, e)
+		}
+
+		switch dup := row.Duplicate; {
+		case dup == nil:
+		case dup.PersonID != 0:
+			fmt.Fprintf(w, "line %d: skipped, %s is already person %d\n", row.Line, dup.Name, dup.PersonID)
+		default:
+			fmt.Fprintf(w, "line %d: skipped, %s is already on line %d\n", row.Line, dup.Name, dup.Line)
+		}
+	}
+
+	verb := "would be imported"
+	if result.Applied {
+		verb = "imported"
+	}
+
+	fmt.Fprintf(w, "%d new %s, %d duplicates, %d invalid\n", result.New(), verb, result.Duplicates(), result.Invalid())
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lincentpega/personal-crm/internal/personcsv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteImportReport(t *testing.T) {
+	result := &personcsv.Result{
+		Rows: []personcsv.Row{
+			{Line: 2},
+			{Line: 3, Errors: []string{"first_name: cannot be blank", "phone: invalid"}},
+			{Line: 4, Duplicate: &personcsv.Duplicate{Name: "Anna Petrova", PersonID: 7}},
+			{Line: 5, Duplicate: &personcsv.Duplicate{Name: "Boris", Line: 2}},
+		},
+	}
+
+	var b strings.Builder
+	writeImportReport(&b, result)
+
+	assert.Equal(t, `line 3: first_name: cannot be blank
+line 3: phone: invalid
+line 4: skipped, Anna Petrova is already person 7
+line 5: skipped, Boris is already on line 2
+1 new would be imported, 2 duplicates, 1 invalid
+`, b.String())
+}
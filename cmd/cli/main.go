@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lincentpega/personal-crm/internal/config"
+	"github.com/lincentpega/personal-crm/internal/db"
+	"github.com/lincentpega/personal-crm/internal/log"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(log *log.Logger, args []string) error
+}
+
+var commands = []command{
+	{"create-user", "create a user who logs in to the web UI", createUser},
+	{"set-password", "set the login email and password of an existing user", setPassword},
+	{"export-csv", "export the persons of a user as CSV", exportCSV},
+	{"import-csv", "import persons from a CSV file, skipping duplicates", importCSV},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -h for the flags of a command.\n", os.Args[0])
+}
+
+func main() {
+	log := log.New()
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(log, os.Args[2:]); err != nil {
+				log.ErrorLog.Fatal(err)
+			}
+			return
+		}
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+// newFlagSet returns the flags of a command, including the datasource name
+// shared by all of them.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	dsn := fs.String("dsn", config.DefaultDSN, "PostgreSQL datasource name")
+	return fs, dsn
+}
+
+func openDB(dsn string, log *log.Logger) (*sql.DB, error) {
+	database, err := db.Connect(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.ExecMigrations(database, log); err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	return database, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lincentpega/personal-crm/internal/log"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+)
+
+const minPasswordLength = 8
+
+func createUser(log *log.Logger, args []string) error {
+	fs, dsn := newFlagSet("create-user")
+	name := fs.String("name", "", "Name of the user")
+	email := fs.String("email", "", "Email the user logs in with")
+	fs.Parse(args)
+
+	if *email == "" {
+		return errors.New("-email is required")
+	}
+
+	password, err := readPassword(os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	database, err := openDB(*dsn, log)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	u, err := users.NewRepository(database).Insert(context.Background(), *name, *email, password)
+	if err != nil {
+		return err
+	}
+
+	log.InfoLog.Printf("Created user %d with email %s", u.ID, u.Email.String)
+	return nil
+}
+
+func setPassword(log *log.Logger, args []string) error {
+	fs, dsn := newFlagSet("set-password")
+	id := fs.Int("id", users.DefaultID, "Id of the user")
+	email := fs.String("email", "", "Email the user logs in with")
+	fs.Parse(args)
+
+	if *email == "" {
+		return errors.New("-email is required")
+	}
+
+	password, err := readPassword(os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	database, err := openDB(*dsn, log)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if err := users.NewRepository(database).SetCredentials(context.Background(), *id, *email, password); err != nil {
+		return err
+	}
+
+	log.InfoLog.Printf("Set the login of user %d to %s", *id, *email)
+	return nil
+}
+
+// readPassword reads the password from the first line of r, so it can be
+// piped in rather than passed as a flag visible in the process list.
+func readPassword(r io.Reader, prompt io.Writer) (string, error) {
+	fmt.Fprint(prompt, "Password: ")
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters long", minPasswordLength)
+	}
+
+	return password, nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPassword(t *testing.T) {
+	password, err := readPassword(strings.NewReader("correct horse\r\nignored\n"), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "correct horse", password)
+
+	password, err = readPassword(strings.NewReader("no newline"), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "no newline", password)
+
+	_, err = readPassword(strings.NewReader("short\n"), io.Discard)
+	assert.Error(t, err)
+}
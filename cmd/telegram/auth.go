@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"gopkg.in/telebot.v3"
+)
+
+const actingUserKey = "acting_user"
+
+// authorize lets through only updates from the allowed users and stores the
+// sender's user record in the context as the acting user. Everyone else
+// gets a polite refusal, and the attempt is logged.
+func (b *bot) authorize(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		sender := ctx.Sender()
+		if sender == nil {
+			return nil
+		}
+
+		if !b.allowedUsers[sender.ID] {
+			b.log.InfoLog.Printf("Unauthorized access attempt: user %d (@%s)", sender.ID, sender.Username)
+			return refuse(ctx)
+		}
+
+		name := strings.TrimSpace(sender.FirstName + " " + sender.LastName)
+		user, err := b.userRepo.EnsureTelegramUser(context.Background(), sender.ID, name)
+		if err != nil {
+			return err
+		}
+
+		ctx.Set(actingUserKey, user)
+
+		return next(ctx)
+	}
+}
+
+func refuse(ctx telebot.Context) error {
+	const refusal = "Sorry, this is a private bot and you don't have access to it"
+
+	switch {
+	case ctx.Query() != nil:
+		return ctx.Answer(&telebot.QueryResponse{Results: telebot.Results{}, IsPersonal: true})
+	case ctx.Callback() != nil:
+		return ctx.Respond(&telebot.CallbackResponse{Text: refusal})
+	case ctx.Chat() != nil && ctx.Chat().Type == telebot.ChatPrivate:
+		return ctx.Send(refusal)
+	default:
+		return nil
+	}
+}
+
+// actingUser returns the user the update is handled for. It is only set
+// behind the authorize middleware.
+func actingUser(ctx telebot.Context) *users.User {
+	user, _ := ctx.Get(actingUserKey).(*users.User)
+	return user
+}
+
+// ownerContext returns a context acting for the acting user, which scopes
+// all repository calls to their data.
+func ownerContext(ctx telebot.Context) context.Context {
+	return owner.WithID(context.Background(), actingUser(ctx).ID)
+}
@@ -1,22 +1,36 @@
 package main
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/lincentpega/personal-crm/internal/log"
+	"github.com/lincentpega/personal-crm/internal/models/conversations"
+	"github.com/lincentpega/personal-crm/internal/models/interactions"
+	"github.com/lincentpega/personal-crm/internal/models/merges"
 	"github.com/lincentpega/personal-crm/internal/models/notifications"
 	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/services"
 	"gopkg.in/telebot.v3"
 )
 
 type bot struct {
 	*telebot.Bot
-	personRepo *person.PersonRepository
-	notifRepo  *notifications.NotificationRepository
-	log        *log.Logger
+	db               *sql.DB
+	personRepo       *person.PersonRepository
+	notifRepo        *notifications.NotificationRepository
+	interactionRepo  *interactions.InteractionRepository
+	conversationRepo *conversations.ConversationRepository
+	userRepo         *users.UserRepository
+	mergeRepo        *merges.MergeRepository
+	notifService     *services.NotificationService
+	log              *log.Logger
+	allowedUsers     map[int64]bool
 }
 
-func newBot(token string, log *log.Logger, pr *person.PersonRepository, nr *notifications.NotificationRepository) (*bot, error) {
+func newBot(token string, allowedUserIDs []int64, log *log.Logger, db *sql.DB, pr *person.PersonRepository, nr *notifications.NotificationRepository,
+	ir *interactions.InteractionRepository, cr *conversations.ConversationRepository, ur *users.UserRepository, mr *merges.MergeRepository) (*bot, error) {
 	pref := telebot.Settings{
 		Token:  token,
 		Poller: &telebot.LongPoller{Timeout: 10 * time.Second},
@@ -27,7 +41,12 @@ func newBot(token string, log *log.Logger, pr *person.PersonRepository, nr *noti
 		return nil, err
 	}
 
-	return &bot{Bot: b, log: log, personRepo: pr, notifRepo: nr}, nil
+	allowedUsers := make(map[int64]bool, len(allowedUserIDs))
+	for _, id := range allowedUserIDs {
+		allowedUsers[id] = true
+	}
+
+	return &bot{Bot: b, allowedUsers: allowedUsers, log: log, db: db, personRepo: pr, notifRepo: nr, interactionRepo: ir, conversationRepo: cr, userRepo: ur, mergeRepo: mr}, nil
 }
 
 func (b *bot) logStart() error {
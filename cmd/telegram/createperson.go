@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/conversations"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"gopkg.in/telebot.v3"
+)
+
+const createPersonFlow = "create_person"
+
+const (
+	stepFirstName      = "first_name"
+	stepLastName       = "last_name"
+	stepBirthDate      = "birth_date"
+	stepContacts       = "contacts"
+	stepJob            = "job"
+	stepBirthdayNotify = "birthday_notify"
+	stepConfirm        = "confirm"
+)
+
+const birthDateLayout = "02.01.2006"
+
+var (
+	skipKeyboard    = replyKeyboard("Skip")
+	contactKeyboard = replyKeyboard("Done")
+	yesNoKeyboard   = replyKeyboard("Yes", "No")
+	confirmKeyboard = replyKeyboard("Save", "Cancel")
+	removeKeyboard  = &telebot.ReplyMarkup{RemoveKeyboard: true}
+)
+
+// personDraft is the person being created, persisted between the steps of
+// the conversation.
+type personDraft struct {
+	FirstName      string         `json:"first_name"`
+	LastName       string         `json:"last_name,omitempty"`
+	BirthDate      string         `json:"birth_date,omitempty"`
+	Contacts       []contactDraft `json:"contacts,omitempty"`
+	Company        string         `json:"company,omitempty"`
+	Position       string         `json:"position,omitempty"`
+	BirthdayNotify bool           `json:"birthday_notify,omitempty"`
+}
+
+type contactDraft struct {
+	Method person.ContactMethod `json:"method"`
+	Data   string               `json:"data"`
+}
+
+// inputError is a reply to the user explaining why the input was rejected.
+type inputError string
+
+func (e inputError) Error() string {
+	return string(e)
+}
+
+type stepPrompt struct {
+	text     string
+	keyboard *telebot.ReplyMarkup
+}
+
+var stepPrompts = map[string]stepPrompt{
+	stepFirstName: {"What is the first name?", removeKeyboard},
+	stepLastName:  {"What is the last name?", skipKeyboard},
+	stepBirthDate: {"When is the birthday? Send it as DD.MM.YYYY", skipKeyboard},
+	stepContacts: {"Send contacts one per message as <method> <value>, e.g. \"phone +7 999 123-45-67\" " +
+		"or \"telegram @anna\". Press Done when finished", contactKeyboard},
+	stepJob:            {"Where does the person work? Send it as <company>, <position>", skipKeyboard},
+	stepBirthdayNotify: {"Remind you about the birthday?", yesNoKeyboard},
+}
+
+func replyKeyboard(buttons ...string) *telebot.ReplyMarkup {
+	rm := &telebot.ReplyMarkup{ResizeKeyboard: true, OneTimeKeyboard: true}
+
+	row := make(telebot.Row, 0, len(buttons))
+	for _, text := range buttons {
+		row = append(row, rm.Text(text))
+	}
+	rm.Reply(row)
+
+	return rm
+}
+
+func isSkip(input string) bool {
+	switch strings.ToLower(input) {
+	case "-", "skip":
+		return true
+	}
+	return false
+}
+
+// apply records the answer to the step and returns the step to ask next.
+func (d *personDraft) apply(step, input string) (string, error) {
+	input = strings.TrimSpace(input)
+
+	switch step {
+	case stepFirstName:
+		if input == "" || isSkip(input) {
+			return step, inputError("First name is required")
+		}
+		d.FirstName = input
+		return stepLastName, nil
+
+	case stepLastName:
+		if !isSkip(input) {
+			d.LastName = input
+		}
+		return stepBirthDate, nil
+
+	case stepBirthDate:
+		if !isSkip(input) {
+			birthDate, err := time.Parse(birthDateLayout, input)
+			if err != nil {
+				return step, inputError("Birth date is not recognized, send it as DD.MM.YYYY")
+			}
+			d.BirthDate = birthDate.Format(time.DateOnly)
+		}
+		return stepContacts, nil
+
+	case stepContacts:
+		if isSkip(input) || strings.EqualFold(input, "done") {
+			return stepJob, nil
+		}
+
+		methodName, data, _ := strings.Cut(input, " ")
+		method, err := person.ParseContactMethod(methodName)
+		if err != nil {
+			return step, inputError("Contact method is not recognized, use phone, email, telegram, whatsapp, url, address or other")
+		}
+
+		contact, err := person.ContactInfo{Method: method, Data: data}.Normalize()
+		if err != nil {
+			return step, inputError(fmt.Sprintf("This doesn't look like a valid %s", method))
+		}
+
+		d.Contacts = append(d.Contacts, contactDraft{Method: contact.Method, Data: contact.Data})
+		return stepContacts, nil
+
+	case stepJob:
+		if !isSkip(input) {
+			company, position, _ := strings.Cut(input, ",")
+			d.Company = strings.TrimSpace(company)
+			d.Position = strings.TrimSpace(position)
+			if d.Company == "" {
+				return step, inputError("Company is required, send it as <company>, <position>")
+			}
+		}
+		return stepBirthdayNotify, nil
+
+	case stepBirthdayNotify:
+		switch strings.ToLower(input) {
+		case "yes", "y", "да":
+			d.BirthdayNotify = true
+		case "no", "n", "нет":
+			d.BirthdayNotify = false
+		default:
+			return step, inputError("Please answer Yes or No")
+		}
+		return stepConfirm, nil
+	}
+
+	return step, fmt.Errorf("unknown step %q", step)
+}
+
+// person builds the person to be inserted. The first contact of every method
+// becomes the primary one.
+func (d *personDraft) person() *person.Person {
+	p := &person.Person{
+		FirstName: d.FirstName,
+		LastName:  sql.NullString{String: d.LastName, Valid: d.LastName != ""},
+		Settings:  person.Settings{BirthdayNotify: d.BirthdayNotify},
+	}
+
+	if birthDate, err := time.Parse(time.DateOnly, d.BirthDate); err == nil {
+		p.BirthDate = sql.NullTime{Time: birthDate, Valid: true}
+	}
+
+	primary := make(map[person.ContactMethod]bool)
+	for _, c := range d.Contacts {
+		p.ContactInfos = append(p.ContactInfos, person.ContactInfo{Method: c.Method, Data: c.Data, Primary: !primary[c.Method]})
+		primary[c.Method] = true
+	}
+
+	if d.Company != "" {
+		p.JobInfos = []person.JobInfo{{Company: d.Company, Position: d.Position, Current: true}}
+	}
+
+	return p
+}
+
+// card renders the draft for the final confirmation.
+func (d *personDraft) card() string {
+	var sb strings.Builder
+
+	p := d.person()
+	sb.WriteString(p.FullName())
+
+	if p.BirthDate.Valid {
+		fmt.Fprintf(&sb, "\nBirthday: %s", p.BirthDate.Time.Format(birthDateLayout))
+	}
+
+	for _, c := range p.ContactInfos {
+		fmt.Fprintf(&sb, "\n%s: %s", c.Method, c.Data)
+	}
+
+	for _, j := range p.JobInfos {
+		fmt.Fprintf(&sb, "\nWorks at %s", j.Company)
+		if j.Position != "" {
+			fmt.Fprintf(&sb, " as %s", j.Position)
+		}
+	}
+
+	if p.Settings.BirthdayNotify {
+		sb.WriteString("\nBirthday reminders are on")
+	} else {
+		sb.WriteString("\nBirthday reminders are off")
+	}
+
+	return sb.String()
+}
+
+func (b *bot) handleCreatePerson(ctx telebot.Context) error {
+	c := &conversations.Conversation{ChatID: ctx.Chat().ID, Flow: createPersonFlow, Step: stepFirstName}
+	if err := b.conversationRepo.Save(ownerContext(ctx), c); err != nil {
+		return err
+	}
+
+	return b.sendPrompt(ctx, stepFirstName)
+}
+
+func (b *bot) handleCancel(ctx telebot.Context) error {
+	if err := b.conversationRepo.Delete(ownerContext(ctx), ctx.Chat().ID); err != nil {
+		return err
+	}
+
+	return ctx.Send("Cancelled", removeKeyboard)
+}
+
+// handleConversation routes a plain text message to the conversation running
+// in the chat, if there is one.
+func (b *bot) handleConversation(ctx telebot.Context) error {
+	c, err := b.conversationRepo.Get(ownerContext(ctx), ctx.Chat().ID)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	switch c.Flow {
+	case createPersonFlow:
+		return b.continueCreatePerson(ctx, c)
+	case importContactFlow:
+		return b.continueImport(ctx, c)
+	default:
+		b.log.ErrorLog.Printf("Unknown conversation flow %q in chat %d", c.Flow, c.ChatID)
+		return b.conversationRepo.Delete(ownerContext(ctx), c.ChatID)
+	}
+}
+
+func (b *bot) continueCreatePerson(ctx telebot.Context, c *conversations.Conversation) error {
+	var draft personDraft
+	if err := json.Unmarshal(c.Data, &draft); err != nil {
+		return err
+	}
+
+	if c.Step == stepConfirm {
+		return b.confirmCreatePerson(ctx, c, &draft)
+	}
+
+	next, err := draft.apply(c.Step, ctx.Text())
+	if err != nil {
+		var inputErr inputError
+		if errors.As(err, &inputErr) {
+			return ctx.Send(inputErr.Error())
+		}
+		return err
+	}
+
+	c.Step = next
+	if c.Data, err = json.Marshal(draft); err != nil {
+		return err
+	}
+
+	if err := b.conversationRepo.Save(ownerContext(ctx), c); err != nil {
+		return err
+	}
+
+	if next == stepConfirm {
+		return ctx.Send(draft.card()+"\n\nSave this person?", confirmKeyboard)
+	}
+
+	if next == stepContacts && len(draft.Contacts) > 0 {
+		return ctx.Send("Added. Send another contact or press Done", contactKeyboard)
+	}
+
+	return b.sendPrompt(ctx, next)
+}
+
+func (b *bot) confirmCreatePerson(ctx telebot.Context, c *conversations.Conversation, draft *personDraft) error {
+	switch strings.ToLower(strings.TrimSpace(ctx.Text())) {
+	case "save", "yes":
+	case "cancel", "no":
+		return b.handleCancel(ctx)
+	default:
+		return ctx.Send("Press Save or Cancel", confirmKeyboard)
+	}
+
+	// Ending the conversation only if it is unchanged keeps a repeated Save
+	// from inserting the person twice.
+	p := draft.person()
+	err := txcontext.Run(ownerContext(ctx), b.db, func(txCtx context.Context) error {
+		if err := b.personRepo.Insert(txCtx, p); err != nil {
+			return err
+		}
+		return b.conversationRepo.End(txCtx, c)
+	})
+	if errors.Is(err, conversations.ErrChanged) {
+		return ctx.Send("This person is already saved", removeKeyboard)
+	} else if err != nil {
+		return err
+	}
+
+	return ctx.Send(fmt.Sprintf("%s is saved with id %d", p.FullName(), p.ID), removeKeyboard)
+}
+
+func (b *bot) sendPrompt(ctx telebot.Context, step string) error {
+	prompt := stepPrompts[step]
+	return ctx.Send(prompt.text, prompt.keyboard)
+}
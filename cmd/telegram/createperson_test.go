@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersonDraftApply(t *testing.T) {
+	var d personDraft
+
+	answers := []struct {
+		step, input, next string
+	}{
+		{stepFirstName, "Anna", stepLastName},
+		{stepLastName, "Skip", stepBirthDate},
+		{stepBirthDate, "29.02.1992", stepContacts},
+		{stepContacts, "phone +7 999 123-45-67", stepContacts},
+		{stepContacts, "tg @Anna_K", stepContacts},
+		{stepContacts, "phone 00441234567890", stepContacts},
+		{stepContacts, "Done", stepJob},
+		{stepJob, "Acme, Designer", stepBirthdayNotify},
+		{stepBirthdayNotify, "yes", stepConfirm},
+	}
+
+	for _, a := range answers {
+		next, err := d.apply(a.step, a.input)
+		require.NoError(t, err, a.input)
+		assert.Equal(t, a.next, next, a.input)
+	}
+
+	p := d.person()
+	assert.Equal(t, "Anna", p.FullName())
+	assert.False(t, p.LastName.Valid)
+	assert.Equal(t, time.Date(1992, time.February, 29, 0, 0, 0, 0, time.UTC), p.BirthDate.Time)
+	assert.Equal(t, []person.ContactInfo{
+		{Method: person.Phone, Data: "+79991234567", Primary: true},
+		{Method: person.Telegram, Data: "anna_k", Primary: true},
+		{Method: person.Phone, Data: "+441234567890"},
+	}, p.ContactInfos)
+	assert.Equal(t, []person.JobInfo{{Company: "Acme", Position: "Designer", Current: true}}, p.JobInfos)
+	assert.True(t, p.Settings.BirthdayNotify)
+	assert.NoError(t, person.NormalizeContactInfos(p.ContactInfos))
+}
+
+func TestPersonDraftApplyInvalid(t *testing.T) {
+	invalid := []struct {
+		step, input string
+	}{
+		{stepFirstName, ""},
+		{stepFirstName, "-"},
+		{stepBirthDate, "1992-02-29"},
+		{stepBirthDate, "30.02.1992"},
+		{stepContacts, "pager 123"},
+		{stepContacts, "email not-an-email"},
+		{stepJob, ", Designer"},
+		{stepBirthdayNotify, "maybe"},
+	}
+
+	for _, i := range invalid {
+		var d personDraft
+		next, err := d.apply(i.step, i.input)
+		assert.ErrorAs(t, err, new(inputError), i.input)
+		assert.Equal(t, i.step, next, i.input)
+	}
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/conversations"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/vcard"
+	"gopkg.in/telebot.v3"
+)
+
+const importContactFlow = "import_contact"
+
+const (
+	stepPreview     = "preview"
+	stepMergeTarget = "merge_target"
+)
+
+const (
+	importSaveUnique      = "import_save"
+	importMergeUnique     = "import_merge"
+	importMergeIntoUnique = "import_merge_into"
+	importDiscardUnique   = "import_discard"
+)
+
+const (
+	maxVCardFileSize = 1 << 20
+	// similarLimit is the number of similar persons offered to merge into.
+	similarLimit = 5
+	// similarNameRank is the minimum search rank of a name for the persons
+	// to be considered similar.
+	similarNameRank = 0.6
+)
+
+// importDraft holds the persons of a shared contact or vCard file waiting to
+// be saved, merged or discarded, the first one being shown. They are kept as
+// a vCard, which converts to persons and back without losing anything.
+type importDraft struct {
+	Cards string `json:"cards"`
+}
+
+func newImportDraft(persons []person.Person) (*importDraft, error) {
+	cards := make([]vcard.Card, len(persons))
+	for i := range persons {
+		cards[i] = vcard.FromPerson(&persons[i], vcard.V4)
+	}
+
+	var buf bytes.Buffer
+	if err := vcard.Encode(&buf, cards); err != nil {
+		return nil, err
+	}
+
+	return &importDraft{Cards: buf.String()}, nil
+}
+
+func (d *importDraft) persons() ([]person.Person, error) {
+	if d.Cards == "" {
+		return nil, nil
+	}
+
+	cards, err := vcard.Decode(strings.NewReader(d.Cards))
+	if err != nil {
+		return nil, err
+	}
+
+	persons := make([]person.Person, len(cards))
+	for i := range cards {
+		if persons[i], err = vcard.ToPerson(&cards[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return persons, nil
+}
+
+// contactPerson builds a person from a contact shared in Telegram. Telegram
+// sends phone numbers with or without the leading plus.
+func contactPerson(c *telebot.Contact) person.Person {
+	p := person.Person{
+		FirstName: strings.TrimSpace(c.FirstName),
+		LastName:  sql.NullString{String: strings.TrimSpace(c.LastName), Valid: strings.TrimSpace(c.LastName) != ""},
+	}
+
+	phone := strings.TrimSpace(c.PhoneNumber)
+	if phone != "" && !strings.HasPrefix(phone, "+") {
+		phone = "+" + phone
+	}
+	if contact, err := (person.ContactInfo{Method: person.Phone, Data: phone}).Normalize(); err == nil {
+		contact.Primary = true
+		p.ContactInfos = append(p.ContactInfos, contact)
+	}
+
+	return p
+}
+
+func isVCardFile(doc *telebot.Document) bool {
+	switch strings.ToLower(doc.MIME) {
+	case "text/vcard", "text/x-vcard", "text/directory":
+		return true
+	}
+
+	switch strings.ToLower(filepath.Ext(doc.FileName)) {
+	case ".vcf", ".vcard":
+		return true
+	}
+
+	return false
+}
+
+func (b *bot) handleContact(ctx telebot.Context) error {
+	p := contactPerson(ctx.Message().Contact)
+	if p.FirstName == "" {
+		return ctx.Send("This contact has no name")
+	}
+
+	return b.startImport(ctx, []person.Person{p})
+}
+
+func (b *bot) handleDocument(ctx telebot.Context) error {
+	doc := ctx.Message().Document
+	if !isVCardFile(doc) {
+		return ctx.Send("Only vCard files (.vcf) can be imported")
+	}
+
+	if doc.FileSize > maxVCardFileSize {
+		return ctx.Send(fmt.Sprintf("The file is too large, the limit is %d KB", maxVCardFileSize>>10))
+	}
+
+	rc, err := b.File(&doc.File)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	cards, err := vcard.Decode(io.LimitReader(rc, maxVCardFileSize))
+	if err != nil {
+		b.log.InfoLog.Printf("Failed to read vCard file %q: %v", doc.FileName, err)
+		return ctx.Send("This file doesn't contain any contacts I can read")
+	}
+
+	var (
+		persons []person.Person
+		skipped int
+	)
+	for i := range cards {
+		p, err := vcard.ToPerson(&cards[i])
+		if err != nil {
+			skipped++
+			continue
+		}
+		persons = append(persons, p)
+	}
+
+	if len(persons) == 0 {
+		return ctx.Send("None of the contacts in this file has a name")
+	}
+
+	if skipped > 0 {
+		if err := ctx.Send(fmt.Sprintf("Skipped %d contacts without a name", skipped)); err != nil {
+			return err
+		}
+	}
+
+	return b.startImport(ctx, persons)
+}
+
+// startImport replaces the conversation in the chat with the import of the
+// persons and shows the first of them.
+func (b *bot) startImport(ctx telebot.Context, persons []person.Person) error {
+	draft, err := newImportDraft(persons)
+	if err != nil {
+		return err
+	}
+
+	c := &conversations.Conversation{ChatID: ctx.Chat().ID, Flow: importContactFlow, Step: stepPreview}
+	if err := b.saveImport(ctx, c, draft); err != nil {
+		return err
+	}
+
+	return b.sendImportPreview(ctx, c, persons)
+}
+
+func (b *bot) saveImport(ctx telebot.Context, c *conversations.Conversation, draft *importDraft) error {
+	var err error
+	if c.Data, err = json.Marshal(draft); err != nil {
+		return err
+	}
+
+	return b.conversationRepo.Save(ownerContext(ctx), c)
+}
+
+// updateImport stores the next state of the import read as c, failing with
+// conversations.ErrChanged if it moved on since.
+func (b *bot) updateImport(ctx context.Context, c *conversations.Conversation, draft *importDraft) error {
+	var err error
+	if c.Data, err = json.Marshal(draft); err != nil {
+		return err
+	}
+
+	return b.conversationRepo.Update(ctx, c)
+}
+
+// importKey identifies the state of the import the buttons were sent for, so
+// that buttons of an earlier preview are rejected.
+func importKey(c *conversations.Conversation) string {
+	return strconv.FormatInt(c.UpdatedAt.UnixMicro(), 36)
+}
+
+func (b *bot) sendImportPreview(ctx telebot.Context, c *conversations.Conversation, persons []person.Person) error {
+	p := &persons[0]
+
+	similar, err := b.similarPersons(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	if len(persons) > 1 {
+		fmt.Fprintf(&sb, "%d contacts left to import\n\n", len(persons))
+	}
+	sb.WriteString(formatPersonPreview(p))
+
+	if len(similar) > 0 {
+		sb.WriteString("\n\nSimilar people are already saved:")
+		for i := range similar {
+			sb.WriteString("\n" + formatPersonSummary(&similar[i]))
+		}
+	}
+
+	key := importKey(c)
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(
+		markup.Row(
+			markup.Data("Save", importSaveUnique, key),
+			markup.Data("Merge into existing…", importMergeUnique, key),
+			markup.Data("Discard", importDiscardUnique, key),
+		),
+	)
+
+	return ctx.Send(sb.String(), markup)
+}
+
+// similarPersons finds saved persons who could be the same as p: those with
+// a closely matching name or sharing a phone, email or Telegram handle.
+func (b *bot) similarPersons(ctx telebot.Context, p *person.Person) ([]person.Person, error) {
+	var similar []person.Person
+	seen := make(map[int]bool)
+
+	add := func(query string, match func(*person.SearchResult) bool) error {
+		results, err := b.personRepo.Search(ownerContext(ctx), query, similarLimit)
+		if err != nil {
+			return err
+		}
+		for i := range results {
+			r := &results[i]
+			if seen[r.Person.ID] || !match(r) || len(similar) == similarLimit {
+				continue
+			}
+			seen[r.Person.ID] = true
+			similar = append(similar, r.Person)
+		}
+		return nil
+	}
+
+	if err := add(p.FullName(), func(r *person.SearchResult) bool {
+		return r.Rank >= similarNameRank
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, c := range p.ContactInfos {
+		switch c.Method {
+		case person.Phone, person.WhatsApp, person.Email, person.Telegram:
+		default:
+			continue
+		}
+
+		if err := add(c.Data, func(r *person.SearchResult) bool {
+			return r.Person.SharesContact(c)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return similar, nil
+}
+
+// formatPersonPreview renders everything that will be saved about the
+// person.
+func formatPersonPreview(p *person.Person) string {
+	var sb strings.Builder
+
+	sb.WriteString(p.FullName())
+	if p.SecondName.Valid {
+		fmt.Fprintf(&sb, "\nSecond name: %s", p.SecondName.String)
+	}
+
+	if p.BirthDate.Valid {
+		fmt.Fprintf(&sb, "\nBirthday: %s", p.BirthDate.Time.Format(birthDateLayout))
+	}
+
+	for _, c := range p.ContactInfos {
+		fmt.Fprintf(&sb, "\n%s: %s", c.Method, c.Data)
+	}
+
+	for _, j := range p.JobInfos {
+		fmt.Fprintf(&sb, "\nWorks at %s", j.Company)
+		if j.Position != "" {
+			fmt.Fprintf(&sb, " as %s", j.Position)
+		}
+		if !j.Current {
+			sb.WriteString(" (former)")
+		}
+	}
+
+	return sb.String()
+}
+
+// loadImport returns the import in the chat if the button pressed belongs
+// to its current state, answering the callback otherwise.
+func (b *bot) loadImport(ctx telebot.Context, key string) (*conversations.Conversation, *importDraft, []person.Person, error) {
+	c, err := b.conversationRepo.Get(ownerContext(ctx), ctx.Chat().ID)
+	if err != nil && !errors.Is(err, models.ErrRecordNotFound) {
+		return nil, nil, nil, err
+	}
+
+	if c == nil || c.Flow != importContactFlow || importKey(c) != key {
+		return nil, nil, nil, ctx.Respond(&telebot.CallbackResponse{Text: "This button is no longer valid"})
+	}
+
+	var draft importDraft
+	if err := json.Unmarshal(c.Data, &draft); err != nil {
+		return nil, nil, nil, err
+	}
+
+	persons, err := draft.persons()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if len(persons) == 0 {
+		return nil, nil, nil, ctx.Respond(&telebot.CallbackResponse{Text: "This contact is already handled"})
+	}
+
+	return c, &draft, persons, nil
+}
+
+func (b *bot) handleImportSave(ctx telebot.Context) error {
+	c, _, persons, err := b.loadImport(ctx, ctx.Callback().Data)
+	if c == nil {
+		return err
+	}
+
+	return b.finishImportStep(ctx, c, persons, func(txCtx context.Context) (string, error) {
+		p := &persons[0]
+		if err := b.personRepo.Insert(txCtx, p); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s is saved with id %d", p.FullName(), p.ID), nil
+	})
+}
+
+func (b *bot) handleImportDiscard(ctx telebot.Context) error {
+	c, _, persons, err := b.loadImport(ctx, ctx.Callback().Data)
+	if c == nil {
+		return err
+	}
+
+	return b.finishImportStep(ctx, c, persons, func(context.Context) (string, error) {
+		return "Discarded", nil
+	})
+}
+
+// handleImportMerge asks which saved person the contact should be merged
+// into, offering the similar ones.
+func (b *bot) handleImportMerge(ctx telebot.Context) error {
+	c, draft, persons, err := b.loadImport(ctx, ctx.Callback().Data)
+	if c == nil {
+		return err
+	}
+
+	similar, err := b.similarPersons(ctx, &persons[0])
+	if err != nil {
+		return err
+	}
+
+	c.Step = stepMergeTarget
+	if err := b.updateImport(ownerContext(ctx), c, draft); err != nil {
+		if errors.Is(err, conversations.ErrChanged) {
+			return ctx.Respond(&telebot.CallbackResponse{Text: "This button is no longer valid"})
+		}
+		return err
+	}
+
+	if err := ctx.Respond(); err != nil {
+		return err
+	}
+
+	if len(similar) == 0 {
+		return ctx.Send("Send the id of the person to merge into, or /cancel")
+	}
+
+	key := importKey(c)
+	markup := &telebot.ReplyMarkup{}
+	rows := make([]telebot.Row, 0, len(similar))
+	for i := range similar {
+		s := &similar[i]
+		rows = append(rows, markup.Row(markup.Data(fmt.Sprintf("#%d %s", s.ID, s.FullName()), importMergeIntoUnique, key, strconv.Itoa(s.ID))))
+	}
+	markup.Inline(rows...)
+
+	return ctx.Send("Which person should it be merged into? Pick one or send their id", markup)
+}
+
+func (b *bot) handleImportMergeInto(ctx telebot.Context) error {
+	key, id, _ := strings.Cut(ctx.Callback().Data, "|")
+
+	c, _, persons, err := b.loadImport(ctx, key)
+	if c == nil {
+		return err
+	}
+
+	personID, err := strconv.Atoi(id)
+	if err != nil {
+		return ctx.Respond(&telebot.CallbackResponse{Text: "This button is not valid"})
+	}
+
+	err = b.finishImportStep(ctx, c, persons, func(txCtx context.Context) (string, error) {
+		return b.mergeImported(txCtx, personID, &persons[0])
+	})
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return ctx.Respond(&telebot.CallbackResponse{Text: "Person is not found"})
+	}
+
+	return err
+}
+
+// continueImport takes the id of the person to merge into, typed instead of
+// picked.
+func (b *bot) continueImport(ctx telebot.Context, c *conversations.Conversation) error {
+	if c.Step != stepMergeTarget {
+		return ctx.Send("Press Save, Merge into existing… or Discard above, or /cancel")
+	}
+
+	personID, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(ctx.Text()), "#"))
+	if err != nil {
+		return ctx.Send("Person id must be a number")
+	}
+
+	var draft importDraft
+	if err := json.Unmarshal(c.Data, &draft); err != nil {
+		return err
+	}
+
+	persons, err := draft.persons()
+	if err != nil {
+		return err
+	}
+
+	err = b.finishImportStep(ctx, c, persons, func(txCtx context.Context) (string, error) {
+		return b.mergeImported(txCtx, personID, &persons[0])
+	})
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return ctx.Send("Person is not found, send another id or /cancel")
+	}
+
+	return err
+}
+
+// mergeImported adds what the imported person knows to the saved one and
+// describes the outcome.
+func (b *bot) mergeImported(ctx context.Context, personID int, imported *person.Person) (string, error) {
+	p, err := b.personRepo.Get(ctx, personID)
+	if err != nil {
+		return "", err
+	}
+
+	p.Merge(imported)
+	if err := b.personRepo.Update(ctx, p); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Merged into #%d %s", p.ID, p.FullName()), nil
+}
+
+// finishImportStep applies what was chosen for the first person and moves
+// the import on to the next one in one transaction. The import must not
+// have changed since c was read, so pressing a button twice, or retrying
+// after a failed reply, never applies the choice twice. The outcome is
+// reported and the next person shown afterwards.
+func (b *bot) finishImportStep(ctx telebot.Context, c *conversations.Conversation, persons []person.Person, apply func(context.Context) (string, error)) error {
+	var result string
+	persons = persons[1:]
+
+	err := txcontext.Run(ownerContext(ctx), b.db, func(txCtx context.Context) error {
+		var err error
+		if result, err = apply(txCtx); err != nil {
+			return err
+		}
+
+		if len(persons) == 0 {
+			return b.conversationRepo.End(txCtx, c)
+		}
+
+		draft, err := newImportDraft(persons)
+		if err != nil {
+			return err
+		}
+
+		c.Step = stepPreview
+		return b.updateImport(txCtx, c, draft)
+	})
+	if errors.Is(err, conversations.ErrChanged) {
+		if ctx.Callback() != nil {
+			return ctx.Respond(&telebot.CallbackResponse{Text: "This contact is already handled"})
+		}
+		return ctx.Send("This contact is already handled")
+	} else if err != nil {
+		return err
+	}
+
+	// The step is done, so a failed reply only gets logged and the next
+	// person is still shown.
+	if cb := ctx.Callback(); cb != nil {
+		if err := ctx.Respond(&telebot.CallbackResponse{Text: result}); err != nil {
+			b.log.ErrorLog.Printf("Failed to answer import button: %v", err)
+		}
+		if err := ctx.Edit(ctx.Message().Text + "\n\n" + result); err != nil {
+			b.log.ErrorLog.Printf("Failed to update import preview: %v", err)
+		}
+	} else if err := ctx.Send(result); err != nil {
+		b.log.ErrorLog.Printf("Failed to report import step: %v", err)
+	}
+
+	if len(persons) == 0 {
+		return nil
+	}
+
+	return b.sendImportPreview(ctx, c, persons)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+)
+
+func TestContactPerson(t *testing.T) {
+	p := contactPerson(&telebot.Contact{PhoneNumber: "79161234567", FirstName: "Anna", LastName: " Shchukina "})
+
+	assert.Equal(t, "Anna", p.FirstName)
+	assert.Equal(t, sql.NullString{String: "Shchukina", Valid: true}, p.LastName)
+	assert.Equal(t, []person.ContactInfo{{Method: person.Phone, Data: "+79161234567", Primary: true}}, p.ContactInfos)
+
+	p = contactPerson(&telebot.Contact{PhoneNumber: "12", FirstName: "Boris"})
+	assert.False(t, p.LastName.Valid)
+	assert.Empty(t, p.ContactInfos)
+}
+
+func TestIsVCardFile(t *testing.T) {
+	assert.True(t, isVCardFile(&telebot.Document{FileName: "Contacts.VCF"}))
+	assert.True(t, isVCardFile(&telebot.Document{FileName: "anna", MIME: "text/x-vcard"}))
+	assert.False(t, isVCardFile(&telebot.Document{FileName: "photo.jpg", MIME: "image/jpeg"}))
+}
+
+func TestImportDraft(t *testing.T) {
+	persons := []person.Person{
+		{
+			FirstName:    "Anna",
+			LastName:     sql.NullString{String: "Shchukina", Valid: true},
+			BirthDate:    sql.NullTime{Time: time.Date(1990, time.May, 17, 0, 0, 0, 0, time.UTC), Valid: true},
+			ContactInfos: []person.ContactInfo{{Method: person.Telegram, Data: "anna_sh", Primary: true}},
+			JobInfos:     []person.JobInfo{{Company: "Acme", Position: "CEO", Current: true}},
+			VCardExtra:   "NOTE:Met in Kazan",
+		},
+		{FirstName: "Boris"},
+	}
+
+	draft, err := newImportDraft(persons)
+	require.NoError(t, err)
+
+	got, err := draft.persons()
+	require.NoError(t, err)
+	assert.Equal(t, persons, got)
+
+	got, err = (&importDraft{}).persons()
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestFormatPersonPreview(t *testing.T) {
+	p := &person.Person{
+		FirstName:    "Anna",
+		SecondName:   sql.NullString{String: "Petrovna", Valid: true},
+		BirthDate:    sql.NullTime{Time: time.Date(1990, time.May, 17, 0, 0, 0, 0, time.UTC), Valid: true},
+		ContactInfos: []person.ContactInfo{{Method: person.Phone, Data: "+79161234567"}},
+		JobInfos:     []person.JobInfo{{Company: "Acme", Position: "CEO", Current: true}, {Company: "Initech"}},
+	}
+
+	assert.Equal(t, "Anna\nSecond name: Petrovna\nBirthday: 17.05.1990\nphone: +79161234567\n"+
+		"Works at Acme as CEO\nWorks at Initech (former)", formatPersonPreview(p))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/interactions"
+	"gopkg.in/telebot.v3"
+)
+
+const timelineLength = 10
+
+const logUsage = "Usage: /log <person ids, comma separated> <call|meeting|message|email> [note] [| location]"
+
+func (b *bot) handleLog(ctx telebot.Context) error {
+	interaction, err := parseInteraction(ctx.Message().Payload, time.Now().UTC())
+	if err != nil {
+		return ctx.Send(logUsage)
+	}
+
+	if err := b.interactionRepo.Insert(ownerContext(ctx), interaction); err != nil {
+		return err
+	}
+
+	for _, personID := range interaction.PersonIDs {
+		if err := b.notifService.RescheduleKeepInTouch(ownerContext(ctx), personID); err != nil {
+			b.log.ErrorLog.Printf("Failed to reschedule keep in touch notification: personID %d: %v", personID, err)
+		}
+	}
+
+	return ctx.Send("Interaction is logged")
+}
+
+func (b *bot) handleTimeline(ctx telebot.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return ctx.Send("Usage: /timeline <person id>")
+	}
+
+	personID, err := strconv.Atoi(args[0])
+	if err != nil {
+		return ctx.Send("Person id must be a number")
+	}
+
+	p, err := b.personRepo.Get(ownerContext(ctx), personID)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			return ctx.Send("Person is not found")
+		}
+		return err
+	}
+
+	timeline, err := b.interactionRepo.ListByPerson(ownerContext(ctx), personID, timelineLength, 0)
+	if err != nil {
+		return err
+	}
+
+	if len(timeline) == 0 {
+		return ctx.Send(fmt.Sprintf("No interactions with %s yet", p.FullName()))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Interactions with %s:\n", p.FullName())
+	for _, i := range timeline {
+		sb.WriteString("\n" + formatInteraction(&i))
+	}
+
+	return ctx.Send(sb.String())
+}
+
+// parseInteraction parses the payload of the /log command.
+func parseInteraction(payload string, now time.Time) (*interactions.Interaction, error) {
+	text, location, _ := strings.Cut(payload, "|")
+
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return nil, errors.New("not enough arguments")
+	}
+
+	var personIDs []int
+	for _, s := range strings.Split(fields[0], ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		personIDs = append(personIDs, id)
+	}
+
+	kind, err := interactions.ParseKind(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	location = strings.TrimSpace(location)
+
+	return &interactions.Interaction{
+		Kind:       kind,
+		OccurredAt: now,
+		Note:       strings.Join(fields[2:], " "),
+		Location:   sql.NullString{String: location, Valid: location != ""},
+		PersonIDs:  personIDs,
+	}, nil
+}
+
+func formatInteraction(i *interactions.Interaction) string {
+	line := fmt.Sprintf("%s · %s", i.OccurredAt.Format("02 Jan 2006 15:04"), i.Kind)
+	if i.Note != "" {
+		line += " · " + i.Note
+	}
+	if i.Location.Valid {
+		line += " (at " + i.Location.String + ")"
+	}
+	return line
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+const linkCodeTTL = 10 * time.Minute
+
+// handleLink issues a one-time code the user enters in the web UI to link
+// their web account to this Telegram account.
+func (b *bot) handleLink(ctx telebot.Context) error {
+	if ctx.Chat().Type != telebot.ChatPrivate {
+		return ctx.Send("Send /link to me in a private chat")
+	}
+
+	code, err := b.userRepo.CreateLinkCode(context.Background(), ctx.Sender().ID, linkCodeTTL)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Send(fmt.Sprintf("Your link code is %s. Enter it on the Telegram page of the web UI within %d minutes.",
+		code, int(linkCodeTTL.Minutes())))
+}
@@ -2,15 +2,22 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"os/signal"
 	"syscall"
 
 	_ "github.com/lib/pq"
+	"github.com/lincentpega/personal-crm/internal/common/callback"
 	"github.com/lincentpega/personal-crm/internal/config"
 	"github.com/lincentpega/personal-crm/internal/db"
 	"github.com/lincentpega/personal-crm/internal/log"
+	"github.com/lincentpega/personal-crm/internal/models/conversations"
+	"github.com/lincentpega/personal-crm/internal/models/interactions"
+	"github.com/lincentpega/personal-crm/internal/models/merges"
 	"github.com/lincentpega/personal-crm/internal/models/notifications"
 	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/notifier"
 	"github.com/lincentpega/personal-crm/internal/services"
 )
 
@@ -34,13 +41,30 @@ func main() {
 
 	notificaitonRepo := notifications.NewRepository(database)
 	personRepo := person.NewRepository(database)
+	interactionRepo := interactions.NewRepository(database)
+	conversationRepo := conversations.NewRepository(database)
+	userRepo := users.NewRepository(database)
+	mergeRepo := merges.NewRepository(database, personRepo)
 
-	b, err := newBot(config.Token, log, personRepo, notificaitonRepo)
+	if err := userRepo.AdoptDefault(ctx, int64(config.UserID)); err != nil {
+		log.ErrorLog.Fatal(err)
+	}
+
+	b, err := newBot(config.Token, config.AllowedUserIDs, log, database, personRepo, notificaitonRepo, interactionRepo, conversationRepo, userRepo, mergeRepo)
+	if err != nil {
+		log.ErrorLog.Fatal(err)
+	}
+
+	n, err := newNotifier(config, b)
 	if err != nil {
 		log.ErrorLog.Fatal(err)
 	}
 
-	notificationService := services.NewNotificationService(b.Bot, notificaitonRepo, personRepo, log, config)
+	signerKey := sha256.Sum256([]byte("reminder-actions:" + config.Token))
+	signer := callback.NewSigner(signerKey[:])
+
+	notificationService := services.NewNotificationService(database, n, signer, notificaitonRepo, personRepo, interactionRepo, userRepo, log, config)
+	b.notifService = notificationService
 
 	startApplication(ctx, b, notificationService)
 
@@ -50,9 +74,30 @@ func main() {
 	b.Stop()
 }
 
+func newNotifier(config *config.AppConfig, b *bot) (*notifier.Notifier, error) {
+	channels := []notifier.Channel{notifier.NewTelegramChannel(b.Bot, int64(config.UserID))}
+
+	if config.SMTPAddr != "" {
+		channels = append(channels, notifier.NewEmailChannel(notifier.EmailConfig{
+			Addr:     config.SMTPAddr,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.SMTPFrom,
+			To:       config.SMTPTo,
+		}))
+	}
+
+	if config.WebhookURL != "" {
+		channels = append(channels, notifier.NewWebhookChannel(config.WebhookURL))
+	}
+
+	return notifier.New(config.NotifyChannels, channels...)
+}
+
 func startApplication(ctx context.Context, b *bot, ns *services.NotificationService) {
 	b.route()
 	b.logStart()
 	go b.Start()
 	go ns.ProcessNotifications(ctx)
+	go ns.ScheduleNotifications(ctx)
 }
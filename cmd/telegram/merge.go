@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/merges"
+	"gopkg.in/telebot.v3"
+)
+
+const (
+	mergeUnique     = "merge"
+	mergeUndoUnique = "merge_undo"
+)
+
+// duplicatesLimit is the number of likely duplicates listed at once, each
+// sent as its own message with buttons.
+const duplicatesLimit = 5
+
+// handleDuplicates lists the likely duplicates with buttons to choose which
+// of the two to keep.
+func (b *bot) handleDuplicates(ctx telebot.Context) error {
+	duplicates, err := b.mergeRepo.FindDuplicates(ownerContext(ctx), duplicatesLimit)
+	if err != nil {
+		return err
+	}
+
+	if len(duplicates) == 0 {
+		return ctx.Send("No duplicates found")
+	}
+
+	for i := range duplicates {
+		d := &duplicates[i]
+
+		markup := &telebot.ReplyMarkup{}
+		markup.Inline(markup.Row(
+			markup.Data(fmt.Sprintf("Keep #%d", d.A.ID), mergeUnique, strconv.Itoa(d.A.ID), strconv.Itoa(d.B.ID)),
+			markup.Data(fmt.Sprintf("Keep #%d", d.B.ID), mergeUnique, strconv.Itoa(d.B.ID), strconv.Itoa(d.A.ID)),
+		))
+
+		if err := ctx.Send(formatDuplicate(d), markup); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatDuplicate(d *merges.Duplicate) string {
+	return fmt.Sprintf("#%d %s\n#%d %s\n%.0f%% likely the same: %s",
+		d.A.ID, d.A.FullName(), d.B.ID, d.B.FullName(), d.Score*100, strings.Join(d.Reasons, ", "))
+}
+
+func (b *bot) handleMerge(ctx telebot.Context) error {
+	args := ctx.Args()
+	if len(args) != 2 {
+		return ctx.Send("Usage: /merge <id of the person to keep> <id of the person to merge into them>")
+	}
+
+	survivorID, err := strconv.Atoi(args[0])
+	if err != nil {
+		return ctx.Send("Person id must be a number")
+	}
+
+	mergedID, err := strconv.Atoi(args[1])
+	if err != nil {
+		return ctx.Send("Person id must be a number")
+	}
+
+	m, err := b.mergeRepo.Merge(ownerContext(ctx), survivorID, mergedID)
+	switch {
+	case errors.Is(err, models.ErrRecordNotFound):
+		return ctx.Send("Person is not found")
+	case errors.Is(err, merges.ErrSamePerson):
+		return ctx.Send("Those are the same person")
+	case err != nil:
+		return err
+	}
+
+	return ctx.Send(mergeResult(m), undoMarkup(m))
+}
+
+func (b *bot) handleMergeButton(ctx telebot.Context) error {
+	survivor, merged, _ := strings.Cut(ctx.Callback().Data, "|")
+
+	survivorID, err := strconv.Atoi(survivor)
+	if err != nil {
+		return ctx.Respond(&telebot.CallbackResponse{Text: "This button is not valid"})
+	}
+
+	mergedID, err := strconv.Atoi(merged)
+	if err != nil {
+		return ctx.Respond(&telebot.CallbackResponse{Text: "This button is not valid"})
+	}
+
+	m, err := b.mergeRepo.Merge(ownerContext(ctx), survivorID, mergedID)
+	switch {
+	case errors.Is(err, models.ErrRecordNotFound):
+		return ctx.Respond(&telebot.CallbackResponse{Text: "Person is not found, they may be merged already"})
+	case errors.Is(err, merges.ErrSamePerson):
+		return ctx.Respond(&telebot.CallbackResponse{Text: "This button is not valid"})
+	case err != nil:
+		return err
+	}
+
+	result := mergeResult(m)
+	if err := ctx.Respond(&telebot.CallbackResponse{Text: result}); err != nil {
+		return err
+	}
+
+	return ctx.Edit(ctx.Message().Text+"\n\n"+result, undoMarkup(m))
+}
+
+func (b *bot) handleMergeUndo(ctx telebot.Context) error {
+	id, err := strconv.Atoi(ctx.Callback().Data)
+	if err != nil {
+		return ctx.Respond(&telebot.CallbackResponse{Text: "This button is not valid"})
+	}
+
+	m, err := b.mergeRepo.Undo(ownerContext(ctx), id)
+	switch {
+	case errors.Is(err, models.ErrRecordNotFound):
+		return ctx.Respond(&telebot.CallbackResponse{Text: "This button is not valid"})
+	case errors.Is(err, merges.ErrAlreadyUndone), errors.Is(err, merges.ErrSurvivorDeleted):
+		return ctx.Respond(&telebot.CallbackResponse{Text: fmt.Sprintf("The merge can't be undone: %v", err)})
+	case err != nil:
+		return err
+	}
+
+	result := fmt.Sprintf("%s is restored with id %d", m.MergedName, m.MergedID)
+	if err := ctx.Respond(&telebot.CallbackResponse{Text: result}); err != nil {
+		return err
+	}
+
+	return ctx.Edit(ctx.Message().Text + "\n\n" + result)
+}
+
+func mergeResult(m *merges.Merge) string {
+	return fmt.Sprintf("#%d %s is merged into #%d", m.MergedID, m.MergedName, m.SurvivorID)
+}
+
+// undoMarkup offers to undo the merge just made.
+func undoMarkup(m *merges.Merge) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(markup.Row(markup.Data("Undo", mergeUndoUnique, strconv.Itoa(m.ID))))
+	return markup
+}
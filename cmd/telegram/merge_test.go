@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lincentpega/personal-crm/internal/models/merges"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDuplicate(t *testing.T) {
+	d := &merges.Duplicate{
+		A:       person.Person{ID: 3, FirstName: "Anna"},
+		B:       person.Person{ID: 7, FirstName: "Anya"},
+		Score:   0.95,
+		Reasons: []string{"names are 60% similar", "same phone +79161234567"},
+	}
+
+	assert.Equal(t, "#3 Anna\n#7 Anya\n95% likely the same: names are 60% similar, same phone +79161234567", formatDuplicate(d))
+}
@@ -1,60 +1,129 @@
 package main
 
 import (
-	"context"
-	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/lincentpega/personal-crm/internal/models/notifications"
+	"github.com/lincentpega/personal-crm/internal/common/callback"
+	"github.com/lincentpega/personal-crm/internal/models"
 	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/notifier"
+	"github.com/lincentpega/personal-crm/internal/services"
 	"gopkg.in/telebot.v3"
 )
 
 func (b *bot) route() {
 	base := b.Group()
+	base.Use(b.authorize)
 
-	base.Handle("/create-person", func(ctx telebot.Context) error {
-		firstName := "Igor"
-		lastName := "Krasnyukov"
-		err := b.personRepo.Insert(
-			context.Background(),
-			&person.Person{
-				FirstName: firstName,
-				LastName:  sql.NullString{String: lastName, Valid: true},
-			})
+	base.Handle("/create-person", b.handleCreatePerson)
+	base.Handle("/cancel", b.handleCancel)
+	base.Handle("/link", b.handleLink)
+
+	base.Handle("/keep-in-touch", func(ctx telebot.Context) error {
+		args := ctx.Args()
+		if len(args) < 2 {
+			return ctx.Send("Usage: /keep-in-touch <person id> <cadence, e.g. 2w, 3m, quarterly or off>")
+		}
+
+		personID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return ctx.Send("Person id must be a number")
+		}
+
+		var cadence person.Cadence
+		if cadenceArg := strings.Join(args[1:], " "); cadenceArg != "off" {
+			cadence, err = person.ParseCadence(cadenceArg)
+			if err != nil {
+				return ctx.Send("Cadence is not recognized, try 2w, 3m or quarterly")
+			}
+		}
+
+		p, err := b.personRepo.Get(ownerContext(ctx), personID)
 		if err != nil {
+			if errors.Is(err, models.ErrRecordNotFound) {
+				return ctx.Send("Person is not found")
+			}
+			return err
+		}
+
+		p.Settings.KeepInTouch = cadence
+		if err := b.personRepo.UpdateSettings(ownerContext(ctx), p.ID, p.Settings); err != nil {
+			return err
+		}
+
+		if cadence.IsZero() {
+			return ctx.Send(fmt.Sprintf("Keep in touch reminders for %s are off", p.FullName()))
+		}
+
+		if err := b.notifService.RescheduleKeepInTouch(ownerContext(ctx), p.ID); err != nil {
 			return err
 		}
-		return ctx.Send("Person is created")
+
+		return ctx.Send(fmt.Sprintf("Will remind you to contact %s every %s", p.FullName(), cadence))
 	})
 
-	base.Handle("/create-notification", func(ctx telebot.Context) error {
-		now := time.Now().UTC()
-		err := b.notifRepo.Insert(
-			context.Background(),
-			&notifications.Notification{
-				PersonID:         2,
-				NotificationTime: now,
-				Status:           notifications.Pending,
-				Type:             notifications.KeepInTouch,
-			})
+	base.Handle("/contacted", func(ctx telebot.Context) error {
+		args := ctx.Args()
+		if len(args) != 1 {
+			return ctx.Send("Usage: /contacted <person id>")
+		}
+
+		personID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return ctx.Send("Person id must be a number")
+		}
+
+		err = b.notifService.RecordContact(ownerContext(ctx), personID, time.Now())
 		if err != nil {
+			if errors.Is(err, models.ErrRecordNotFound) {
+				return ctx.Send("Person is not found")
+			}
 			return err
 		}
-		return ctx.Send("Notification scheduled")
-	})
 
-	base.Handle("/hello", func(ctx telebot.Context) error {
-		var kbd [][]telebot.InlineButton
-		btn1 := telebot.InlineButton{Text: "SOSAT", Data: "sosat"}
-		row1 := []telebot.InlineButton{btn1}
-		kbd = append(kbd, row1)
-		mrkp := &telebot.ReplyMarkup{InlineKeyboard: kbd}
-		return ctx.Send("Hello, world!", mrkp)
+		return ctx.Send("Contact is recorded")
 	})
 
-	base.Handle(telebot.OnCallback, func(ctx telebot.Context) error {
-		c := ctx.Callback()
-		return ctx.Send(c.Data)
-	})
+	base.Handle("/find", b.handleFind)
+	base.Handle("/log", b.handleLog)
+	base.Handle("/timeline", b.handleTimeline)
+	base.Handle("/duplicates", b.handleDuplicates)
+	base.Handle("/merge", b.handleMerge)
+
+	base.Handle(&telebot.InlineButton{Unique: notifier.ActionUnique}, b.handleReminderAction)
+	base.Handle(&telebot.InlineButton{Unique: importSaveUnique}, b.handleImportSave)
+	base.Handle(&telebot.InlineButton{Unique: importMergeUnique}, b.handleImportMerge)
+	base.Handle(&telebot.InlineButton{Unique: importMergeIntoUnique}, b.handleImportMergeInto)
+	base.Handle(&telebot.InlineButton{Unique: importDiscardUnique}, b.handleImportDiscard)
+	base.Handle(&telebot.InlineButton{Unique: mergeUnique}, b.handleMergeButton)
+	base.Handle(&telebot.InlineButton{Unique: mergeUndoUnique}, b.handleMergeUndo)
+
+	base.Handle(telebot.OnContact, b.handleContact)
+	base.Handle(telebot.OnDocument, b.handleDocument)
+
+	base.Handle(telebot.OnText, b.handleConversation)
+	base.Handle(telebot.OnQuery, b.handleInlineQuery)
+}
+
+func (b *bot) handleReminderAction(ctx telebot.Context) error {
+	result, err := b.notifService.HandleReminderAction(ownerContext(ctx), ctx.Callback().Data, ctx.Sender().ID)
+	switch {
+	case errors.Is(err, callback.ErrInvalid), errors.Is(err, services.ErrActionNotAllowed), errors.Is(err, models.ErrRecordNotFound):
+		b.log.InfoLog.Printf("Rejected reminder action from user %d", ctx.Sender().ID)
+		return ctx.Respond(&telebot.CallbackResponse{Text: "This button is not valid"})
+	case errors.Is(err, services.ErrAlreadyHandled):
+		return ctx.Respond(&telebot.CallbackResponse{Text: "This reminder is already handled"})
+	case err != nil:
+		return err
+	}
+
+	if err := ctx.Respond(&telebot.CallbackResponse{Text: result}); err != nil {
+		return err
+	}
+
+	return ctx.Edit(ctx.Message().Text + "\n\n" + result)
 }
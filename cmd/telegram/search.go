@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"gopkg.in/telebot.v3"
+)
+
+const (
+	findLimit   = 10
+	inlineLimit = 20
+)
+
+func (b *bot) handleFind(ctx telebot.Context) error {
+	query := strings.TrimSpace(ctx.Message().Payload)
+	if query == "" {
+		return ctx.Send("Usage: /find <name, contact or company>")
+	}
+
+	results, err := b.personRepo.Search(ownerContext(ctx), query, findLimit)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		return ctx.Send("Nobody is found")
+	}
+
+	var sb strings.Builder
+	for i, r := range results {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(formatPersonSummary(&r.Person))
+	}
+
+	return ctx.Send(sb.String())
+}
+
+// handleInlineQuery answers "@bot <query>" from any chat with matching
+// persons. The answer is never cached for anyone but the asking user.
+func (b *bot) handleInlineQuery(ctx telebot.Context) error {
+	query := ctx.Query()
+
+	response := &telebot.QueryResponse{Results: telebot.Results{}, IsPersonal: true}
+
+	results, err := b.personRepo.Search(ownerContext(ctx), query.Text, inlineLimit)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		response.Results = append(response.Results, inlineResult(&r.Person))
+	}
+
+	return ctx.Answer(response)
+}
+
+// inlineResult shares the person as a contact card when they have a phone
+// number, and as a text message with their handles otherwise.
+func inlineResult(p *person.Person) telebot.Result {
+	var result telebot.Result
+
+	if phone, ok := primaryContact(p, person.Phone); ok {
+		result = &telebot.ContactResult{
+			PhoneNumber: phone,
+			FirstName:   p.FirstName,
+			LastName:    p.LastName.String,
+		}
+	} else {
+		text := p.FullName()
+		if handle, ok := primaryContact(p, person.Telegram); ok {
+			text += "\nhttps://t.me/" + handle
+		}
+		if email, ok := primaryContact(p, person.Email); ok {
+			text += "\n" + email
+		}
+
+		result = &telebot.ArticleResult{
+			Title:       p.FullName(),
+			Description: strings.Join(personDetails(p), ", "),
+			Text:        text,
+		}
+	}
+
+	result.SetResultID(strconv.Itoa(p.ID))
+
+	return result
+}
+
+// primaryContact returns the primary contact of the method, falling back to
+// the first one.
+func primaryContact(p *person.Person, method person.ContactMethod) (string, bool) {
+	var data string
+	var found bool
+
+	for _, c := range p.ContactInfos {
+		if c.Method != method {
+			continue
+		}
+		if c.Primary {
+			return c.Data, true
+		}
+		if !found {
+			data, found = c.Data, true
+		}
+	}
+
+	return data, found
+}
+
+// formatPersonSummary renders a one-line description of the person with
+// their primary contacts and current job.
+func formatPersonSummary(p *person.Person) string {
+	summary := fmt.Sprintf("#%d %s", p.ID, p.FullName())
+
+	if details := personDetails(p); len(details) > 0 {
+		summary += " — " + strings.Join(details, ", ")
+	}
+
+	return summary
+}
+
+func personDetails(p *person.Person) []string {
+	var details []string
+	for _, c := range p.ContactInfos {
+		if c.Primary {
+			details = append(details, fmt.Sprintf("%s: %s", c.Method, c.Data))
+		}
+	}
+
+	for _, j := range p.JobInfos {
+		if j.Current {
+			details = append(details, j.Company)
+		}
+	}
+
+	return details
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v3"
+)
+
+func TestInlineResult(t *testing.T) {
+	anna := &person.Person{
+		ID:        7,
+		FirstName: "Anna",
+		LastName:  sql.NullString{String: "Shchukina", Valid: true},
+		ContactInfos: []person.ContactInfo{
+			{Method: person.Phone, Data: "+79990000000"},
+			{Method: person.Phone, Data: "+79991234567", Primary: true},
+			{Method: person.Telegram, Data: "anna_sh", Primary: true},
+		},
+	}
+
+	contact, ok := inlineResult(anna).(*telebot.ContactResult)
+	require.True(t, ok)
+	assert.Equal(t, "7", contact.ResultID())
+	assert.Equal(t, "+79991234567", contact.PhoneNumber)
+	assert.Equal(t, "Anna", contact.FirstName)
+	assert.Equal(t, "Shchukina", contact.LastName)
+
+	boris := &person.Person{
+		ID:           8,
+		FirstName:    "Boris",
+		ContactInfos: []person.ContactInfo{{Method: person.Telegram, Data: "boris_m"}},
+		JobInfos:     []person.JobInfo{{Company: "Acme", Current: true}},
+	}
+
+	article, ok := inlineResult(boris).(*telebot.ArticleResult)
+	require.True(t, ok)
+	assert.Equal(t, "8", article.ResultID())
+	assert.Equal(t, "Boris", article.Title)
+	assert.Equal(t, "Acme", article.Description)
+	assert.Equal(t, "Boris\nhttps://t.me/boris_m", article.Text)
+}
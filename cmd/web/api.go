@@ -0,0 +1,396 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/notifications"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/validator"
+)
+
+const (
+	apiDefaultPageSize = 20
+	apiMaxPageSize     = 100
+)
+
+// apiRoute is an endpoint of the JSON API.
+type apiRoute struct {
+	method  string
+	pattern string
+	handler http.HandlerFunc
+}
+
+func (app *application) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{http.MethodGet, "/api/v1/persons", app.apiListPersons},
+		{http.MethodPost, "/api/v1/persons", app.apiCreatePerson},
+		{http.MethodGet, "/api/v1/persons/search", app.apiSearchPersons},
+		{http.MethodGet, "/api/v1/persons/{id}", app.apiGetPerson},
+		{http.MethodPut, "/api/v1/persons/{id}", app.apiUpdatePerson},
+		{http.MethodDelete, "/api/v1/persons/{id}", app.apiDeletePerson},
+		{http.MethodGet, "/api/v1/notifications", app.apiListNotifications},
+		{http.MethodPost, "/api/v1/notifications", app.apiCreateNotification},
+		{http.MethodGet, "/api/v1/notifications/{id}", app.apiGetNotification},
+		{http.MethodPut, "/api/v1/notifications/{id}", app.apiUpdateNotification},
+		{http.MethodDelete, "/api/v1/notifications/{id}", app.apiDeleteNotification},
+	}
+}
+
+// apiHandler routes the API requests. It expects the request to be scoped
+// to an owner already.
+func (app *application) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	for _, route := range app.apiRoutes() {
+		mux.Handle(route.method+" "+route.pattern, route.handler)
+	}
+
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		app.apiNotFound(w)
+	})
+
+	return mux
+}
+
+// readPagination reads the page and page_size query parameters.
+func readPagination(r *http.Request, v *validator.Validator) (int, int) {
+	page, err := readIntQuery(r, "page", 1)
+	v.CheckField(err == nil && page >= 1, "page", "must be a positive integer")
+
+	pageSize, err := readIntQuery(r, "page_size", apiDefaultPageSize)
+	v.CheckField(err == nil && validator.Between(pageSize, 1, apiMaxPageSize), "page_size",
+		fmt.Sprintf("must be an integer from 1 to %d", apiMaxPageSize))
+
+	return page, pageSize
+}
+
+func (app *application) apiListPersons(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	opts := person.ListOptions{Sort: person.SortField(r.URL.Query().Get("sort")), Details: true}
+	opts.Page, opts.PageSize = readPagination(r, &v)
+
+	switch opts.Sort {
+	case "":
+		opts.Sort = person.SortByName
+	case person.SortByName, person.SortByBirthDate:
+	default:
+		v.AddFieldError("sort", "must be name or birth_date")
+	}
+
+	if desc := r.URL.Query().Get("desc"); desc != "" {
+		var err error
+		opts.Desc, err = strconv.ParseBool(desc)
+		v.CheckField(err == nil, "desc", "must be true or false")
+	}
+
+	if !v.Valid() {
+		app.apiValidationError(w, v.FieldErrors)
+		return
+	}
+
+	persons, total, err := app.personRepo.List(r.Context(), opts)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"persons":    newPersonsJSON(persons),
+		"pagination": newPaginationJSON(opts.Page, opts.PageSize, total),
+	}, nil)
+}
+
+func (app *application) apiSearchPersons(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	v.CheckField(validator.NotBlank(query), "q", "must be provided")
+
+	limit, err := readIntQuery(r, "limit", apiDefaultPageSize)
+	v.CheckField(err == nil && validator.Between(limit, 1, apiMaxPageSize), "limit",
+		fmt.Sprintf("must be an integer from 1 to %d", apiMaxPageSize))
+
+	if !v.Valid() {
+		app.apiValidationError(w, v.FieldErrors)
+		return
+	}
+
+	results, err := app.personRepo.Search(r.Context(), query, limit)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	resultsJSON := make([]searchResultJSON, 0, len(results))
+	for i := range results {
+		resultsJSON = append(resultsJSON, searchResultJSON{Person: newPersonJSON(&results[i].Person), Rank: results[i].Rank})
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"results": resultsJSON}, nil)
+}
+
+func (app *application) apiCreatePerson(w http.ResponseWriter, r *http.Request) {
+	var input personJSON
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	var p person.Person
+	if fields := input.person(&p); fields != nil {
+		app.apiValidationError(w, fields)
+		return
+	}
+
+	if err := app.personRepo.Insert(r.Context(), &p); err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	headers := http.Header{"Location": {fmt.Sprintf("/api/v1/persons/%d", p.ID)}}
+	app.writeJSON(w, http.StatusCreated, envelope{"person": newPersonJSON(&p)}, headers)
+}
+
+// apiGetPersonParam loads the person named by the id path value, responding
+// with an error if there is none.
+func (app *application) apiGetPersonParam(w http.ResponseWriter, r *http.Request) (*person.Person, bool) {
+	id, ok := readIDParam(r)
+	if !ok {
+		app.apiNotFound(w)
+		return nil, false
+	}
+
+	p, err := app.personRepo.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+
+	return p, true
+}
+
+func (app *application) apiGetPerson(w http.ResponseWriter, r *http.Request) {
+	p, ok := app.apiGetPersonParam(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"person": newPersonJSON(p)}, nil)
+}
+
+// apiUpdatePerson replaces the person, including the contacts, jobs and
+// settings, with the one in the body.
+func (app *application) apiUpdatePerson(w http.ResponseWriter, r *http.Request) {
+	p, ok := app.apiGetPersonParam(w, r)
+	if !ok {
+		return
+	}
+
+	var input personJSON
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	if fields := input.person(p); fields != nil {
+		app.apiValidationError(w, fields)
+		return
+	}
+
+	if err := app.personRepo.Update(r.Context(), p); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"person": newPersonJSON(p)}, nil)
+}
+
+func (app *application) apiDeletePerson(w http.ResponseWriter, r *http.Request) {
+	id, ok := readIDParam(r)
+	if !ok {
+		app.apiNotFound(w)
+		return
+	}
+
+	if err := app.personRepo.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiListNotifications(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	opts := notifications.ListOptions{Status: notifications.Status(r.URL.Query().Get("status"))}
+	opts.Page, opts.PageSize = readPagination(r, &v)
+
+	personID, err := readIntQuery(r, "person_id", 0)
+	v.CheckField(err == nil && personID >= 0, "person_id", "must be a positive integer")
+	opts.PersonID = personID
+
+	if opts.Status != "" && opts.Status != notifications.Processing {
+		v.CheckField(slices.Contains(notificationStatuses, opts.Status), "status", "is not a valid status")
+	}
+
+	if !v.Valid() {
+		app.apiValidationError(w, v.FieldErrors)
+		return
+	}
+
+	ns, total, err := app.notifRepo.List(r.Context(), opts)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"notifications": newNotificationsJSON(ns),
+		"pagination":    newPaginationJSON(opts.Page, opts.PageSize, total),
+	}, nil)
+}
+
+func (app *application) apiCreateNotification(w http.ResponseWriter, r *http.Request) {
+	var input notificationJSON
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	ownerID, err := owner.ID(r.Context())
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	n := notifications.Notification{Status: notifications.Pending}
+	if fields := input.notification(&n, ownerID); fields != nil {
+		app.apiValidationError(w, fields)
+		return
+	}
+
+	if err := app.notifRepo.Insert(r.Context(), &n); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.apiValidationError(w, map[string]string{"person_id": "does not exist"})
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	headers := http.Header{"Location": {fmt.Sprintf("/api/v1/notifications/%d", n.ID)}}
+	app.writeJSON(w, http.StatusCreated, envelope{"notification": newNotificationJSON(&n)}, headers)
+}
+
+// apiGetNotificationParam loads the notification named by the id path
+// value, responding with an error if there is none.
+func (app *application) apiGetNotificationParam(w http.ResponseWriter, r *http.Request) (*notifications.Notification, bool) {
+	id, ok := readIDParam(r)
+	if !ok {
+		app.apiNotFound(w)
+		return nil, false
+	}
+
+	n, err := app.notifRepo.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+
+	return n, true
+}
+
+func (app *application) apiGetNotification(w http.ResponseWriter, r *http.Request) {
+	n, ok := app.apiGetNotificationParam(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"notification": newNotificationJSON(n)}, nil)
+}
+
+// apiUpdateNotification replaces the notification with the one in the
+// body. The person may be left out but can't be changed.
+func (app *application) apiUpdateNotification(w http.ResponseWriter, r *http.Request) {
+	n, ok := app.apiGetNotificationParam(w, r)
+	if !ok {
+		return
+	}
+
+	var input notificationJSON
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	if input.PersonID == 0 {
+		input.PersonID = n.PersonID
+	}
+	if input.PersonID != n.PersonID {
+		app.apiValidationError(w, map[string]string{"person_id": "cannot be changed"})
+		return
+	}
+
+	if fields := input.notification(n, n.OwnerID); fields != nil {
+		app.apiValidationError(w, fields)
+		return
+	}
+
+	if err := app.notifRepo.Update(r.Context(), n); err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.apiNotFound(w)
+		case errors.Is(err, notifications.ErrProcessing):
+			app.apiError(w, http.StatusConflict, "conflict", "the notification is being sent, try again later")
+		default:
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"notification": newNotificationJSON(n)}, nil)
+}
+
+func (app *application) apiDeleteNotification(w http.ResponseWriter, r *http.Request) {
+	id, ok := readIDParam(r)
+	if !ok {
+		app.apiNotFound(w)
+		return
+	}
+
+	if err := app.notifRepo.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxRequestBodySize = 1 << 20
+
+// envelope is the top level object of every API response.
+type envelope map[string]any
+
+type apiErrorBody struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	for key, values := range headers {
+		w.Header()[key] = values
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+// readJSON decodes a single JSON object from the request body into dst.
+// The returned errors are meant to be shown to the client.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var (
+			syntaxError    *json.SyntaxError
+			typeError      *json.UnmarshalTypeError
+			maxBytesError  *http.MaxBytesError
+			invalidUnmarsh *json.InvalidUnmarshalError
+		)
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON at character %d", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &typeError):
+			if typeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", typeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type at character %d", typeError.Offset)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		case errors.As(err, &invalidUnmarsh):
+			panic(err)
+		default:
+			return err
+		}
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+func (app *application) apiError(w http.ResponseWriter, status int, code, message string) {
+	app.writeJSON(w, status, envelope{"error": apiErrorBody{Code: code, Message: message}}, nil)
+}
+
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	app.log.ErrorLog.Output(2, err.Error())
+	app.apiError(w, http.StatusInternalServerError, "internal_error", "the server encountered a problem and could not process the request")
+}
+
+func (app *application) apiNotFound(w http.ResponseWriter) {
+	app.apiError(w, http.StatusNotFound, "not_found", "the requested resource could not be found")
+}
+
+func (app *application) apiBadRequest(w http.ResponseWriter, err error) {
+	app.apiError(w, http.StatusBadRequest, "bad_request", err.Error())
+}
+
+// apiInvalidToken rejects a request whose API token is malformed, unknown,
+// revoked or expired.
+func (app *application) apiInvalidToken(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	app.apiError(w, http.StatusUnauthorized, "invalid_token", "the API token is invalid, revoked or expired")
+}
+
+func (app *application) apiValidationError(w http.ResponseWriter, fields map[string]string) {
+	app.writeJSON(w, http.StatusUnprocessableEntity, envelope{"error": apiErrorBody{
+		Code:    "validation_failed",
+		Message: "the request contains invalid fields",
+		Fields:  fields,
+	}}, nil)
+}
+
+// readIDParam returns the positive integer path value id.
+func readIDParam(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	return id, err == nil && id > 0
+}
+
+// readIntQuery returns the integer query parameter, or def if it's absent.
+func readIntQuery(r *http.Request, key string, def int) (int, error) {
+	s := r.URL.Query().Get(key)
+	if s == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("query parameter %s must be an integer", key)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models/notifications"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/models/tokens"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type apiTestSuite struct {
+	test.TestSuite
+	app *application
+	tx  *sql.Tx
+}
+
+func (suite *apiTestSuite) SetupSuite() {
+	suite.TestSuite.SetupSuite()
+
+	suite.app = newTestApplication()
+	suite.app.personRepo = person.NewRepository(suite.DB)
+	suite.app.notifRepo = notifications.NewRepository(suite.DB)
+	suite.app.userRepo = users.NewRepository(suite.DB)
+	suite.app.tokenRepo = tokens.NewRepository(suite.DB)
+}
+
+func (suite *apiTestSuite) SetupTest() {
+	var err error
+	suite.tx, err = suite.DB.BeginTx(suite.Ctx, nil)
+	suite.Require().NoError(err)
+}
+
+func (suite *apiTestSuite) TearDownTest() {
+	err := suite.tx.Rollback()
+	suite.Require().NoError(err)
+}
+
+// do sends the request to the API as the default user, within the test's
+// transaction, and decodes the response into dst unless it's nil.
+func (suite *apiTestSuite) do(method, target string, body any, dst any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		suite.Require().NoError(json.NewEncoder(&buf).Encode(body))
+	}
+
+	r := httptest.NewRequest(method, target, &buf)
+	ctx := txcontext.WithTx(owner.WithID(r.Context(), users.DefaultID), suite.tx)
+
+	rec := httptest.NewRecorder()
+	suite.app.apiHandler().ServeHTTP(rec, r.WithContext(ctx))
+
+	if dst != nil {
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), dst), rec.Body.String())
+	}
+
+	return rec
+}
+
+type apiErrorResponse struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type personResponse struct {
+	Person personJSON `json:"person"`
+}
+
+type notificationResponse struct {
+	Notification notificationJSON `json:"notification"`
+}
+
+func (suite *apiTestSuite) createPerson(p personJSON) personJSON {
+	var resp personResponse
+	rec := suite.do(http.MethodPost, "/api/v1/persons", p, &resp)
+	suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+	return resp.Person
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func (suite *apiTestSuite) TestPersonCRUD() {
+	created := suite.createPerson(personJSON{
+		FirstName: "Anna",
+		LastName:  stringPtr("Petrova"),
+		BirthDate: stringPtr("1990-05-17"),
+		Contacts:  []contactJSON{{Method: person.Email, Data: "Anna@Example.com", Primary: true}},
+		Jobs:      []jobJSON{{Company: "Acme", Position: "Engineer", Current: true}},
+		Settings:  settingsJSON{BirthdayNotify: true, KeepInTouch: "2w"},
+	})
+	suite.NotZero(created.ID)
+
+	var got personResponse
+	rec := suite.do(http.MethodGet, fmt.Sprintf("/api/v1/persons/%d", created.ID), nil, &got)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.Equal("Anna", got.Person.FirstName)
+	suite.Equal("1990-05-17", *got.Person.BirthDate)
+	suite.Require().Len(got.Person.Contacts, 1)
+	suite.Equal("anna@example.com", got.Person.Contacts[0].Data)
+	suite.Require().Len(got.Person.Jobs, 1)
+	suite.Equal("Acme", got.Person.Jobs[0].Company)
+	suite.True(got.Person.Settings.BirthdayNotify)
+	suite.Equal("2w", got.Person.Settings.KeepInTouch)
+
+	update := got.Person
+	update.LastName = nil
+	update.Contacts = nil
+	update.Settings = settingsJSON{}
+	rec = suite.do(http.MethodPut, fmt.Sprintf("/api/v1/persons/%d", created.ID), update, &got)
+	suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	suite.Nil(got.Person.LastName)
+	suite.Empty(got.Person.Contacts)
+	suite.Len(got.Person.Jobs, 1)
+
+	var list struct {
+		Persons    []personJSON   `json:"persons"`
+		Pagination paginationJSON `json:"pagination"`
+	}
+	rec = suite.do(http.MethodGet, "/api/v1/persons?page_size=1", nil, &list)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.Len(list.Persons, 1)
+	suite.Equal(1, list.Pagination.PageSize)
+	suite.Positive(list.Pagination.Total)
+
+	rec = suite.do(http.MethodDelete, fmt.Sprintf("/api/v1/persons/%d", created.ID), nil, nil)
+	suite.Equal(http.StatusNoContent, rec.Code)
+
+	var apiErr apiErrorResponse
+	rec = suite.do(http.MethodGet, fmt.Sprintf("/api/v1/persons/%d", created.ID), nil, &apiErr)
+	suite.Equal(http.StatusNotFound, rec.Code)
+	suite.Equal("not_found", apiErr.Error.Code)
+
+	rec = suite.do(http.MethodDelete, fmt.Sprintf("/api/v1/persons/%d", created.ID), nil, &apiErr)
+	suite.Equal(http.StatusNotFound, rec.Code)
+}
+
+func (suite *apiTestSuite) TestPersonValidation() {
+	var apiErr apiErrorResponse
+	rec := suite.do(http.MethodPost, "/api/v1/persons", personJSON{
+		BirthDate: stringPtr("17.05.1990"),
+		Contacts:  []contactJSON{{Method: "fax", Data: "123"}},
+		Jobs:      []jobJSON{{Position: "Engineer"}},
+		Settings:  settingsJSON{KeepInTouch: "sometimes"},
+	}, &apiErr)
+	suite.Equal(http.StatusUnprocessableEntity, rec.Code)
+	suite.Equal("validation_failed", apiErr.Error.Code)
+	suite.Contains(apiErr.Error.Fields, "first_name")
+	suite.Contains(apiErr.Error.Fields, "birth_date")
+	suite.Contains(apiErr.Error.Fields, "contacts.0")
+	suite.Contains(apiErr.Error.Fields, "jobs.0")
+	suite.Contains(apiErr.Error.Fields, "settings.keep_in_touch")
+
+	// Longer values than the database holds are validation errors, not
+	// server errors.
+	long := strings.Repeat("a", 257)
+	rec = suite.do(http.MethodPost, "/api/v1/persons", personJSON{
+		FirstName: "Anna",
+		Contacts:  []contactJSON{{Method: person.Other, Data: long}},
+		Jobs:      []jobJSON{{Company: "Acme", Position: long}},
+	}, &apiErr)
+	suite.Equal(http.StatusUnprocessableEntity, rec.Code)
+	suite.Equal("validation_failed", apiErr.Error.Code)
+	suite.Contains(apiErr.Error.Fields, "contacts.0")
+	suite.Contains(apiErr.Error.Fields, "jobs.0")
+
+	rec = suite.do(http.MethodGet, "/api/v1/persons?page=0&sort=age", nil, &apiErr)
+	suite.Equal(http.StatusUnprocessableEntity, rec.Code)
+	suite.Contains(apiErr.Error.Fields, "page")
+	suite.Contains(apiErr.Error.Fields, "sort")
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/persons", strings.NewReader(`{"first_name": "Anna", "age": 30}`))
+	rec = httptest.NewRecorder()
+	suite.app.apiHandler().ServeHTTP(rec, r.WithContext(txcontext.WithTx(owner.WithID(r.Context(), users.DefaultID), suite.tx)))
+	suite.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = suite.do(http.MethodGet, "/api/v1/nothing", nil, &apiErr)
+	suite.Equal(http.StatusNotFound, rec.Code)
+	suite.Equal("not_found", apiErr.Error.Code)
+}
+
+func (suite *apiTestSuite) TestSearchPersons() {
+	suite.createPerson(personJSON{FirstName: "Anna", LastName: stringPtr("Petrova")})
+	suite.createPerson(personJSON{FirstName: "Boris"})
+
+	var resp struct {
+		Results []searchResultJSON `json:"results"`
+	}
+	rec := suite.do(http.MethodGet, "/api/v1/persons/search?q=petrova", nil, &resp)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.Require().Len(resp.Results, 1)
+	suite.Equal("Anna", resp.Results[0].Person.FirstName)
+
+	rec = suite.do(http.MethodGet, "/api/v1/persons/search", nil, nil)
+	suite.Equal(http.StatusUnprocessableEntity, rec.Code)
+}
+
+func (suite *apiTestSuite) TestNotificationCRUD() {
+	p := suite.createPerson(personJSON{FirstName: "Anna"})
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	var created notificationResponse
+	rec := suite.do(http.MethodPost, "/api/v1/notifications", notificationJSON{
+		PersonID:         p.ID,
+		Type:             notifications.KeepInTouch,
+		NotificationTime: at,
+		Description:      "Call Anna",
+	}, &created)
+	suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+	suite.Equal(notifications.Pending, created.Notification.Status)
+	suite.Equal(fmt.Sprintf("/api/v1/notifications/%d", created.Notification.ID), rec.Header().Get("Location"))
+
+	var list struct {
+		Notifications []notificationJSON `json:"notifications"`
+		Pagination    paginationJSON     `json:"pagination"`
+	}
+	rec = suite.do(http.MethodGet, fmt.Sprintf("/api/v1/notifications?person_id=%d&status=pending", p.ID), nil, &list)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.Require().Len(list.Notifications, 1)
+	suite.Equal(1, list.Pagination.Total)
+
+	update := created.Notification
+	update.PersonID = 0
+	update.Status = notifications.Snoozed
+	update.Description = "Call Anna back"
+	var got notificationResponse
+	rec = suite.do(http.MethodPut, fmt.Sprintf("/api/v1/notifications/%d", update.ID), update, &got)
+	suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	suite.Equal(notifications.Snoozed, got.Notification.Status)
+	suite.Equal("Call Anna back", got.Notification.Description)
+	suite.Equal(p.ID, got.Notification.PersonID)
+
+	var apiErr apiErrorResponse
+	update.PersonID = p.ID + 1
+	rec = suite.do(http.MethodPut, fmt.Sprintf("/api/v1/notifications/%d", update.ID), update, &apiErr)
+	suite.Equal(http.StatusUnprocessableEntity, rec.Code)
+	suite.Contains(apiErr.Error.Fields, "person_id")
+
+	_, err := suite.tx.Exec(`UPDATE notifications SET status = 'processing', lease_expires_at = NOW() WHERE id = $1`, update.ID)
+	suite.Require().NoError(err)
+	update.PersonID = p.ID
+	rec = suite.do(http.MethodPut, fmt.Sprintf("/api/v1/notifications/%d", update.ID), update, &apiErr)
+	suite.Equal(http.StatusConflict, rec.Code)
+	suite.Equal("conflict", apiErr.Error.Code)
+
+	rec = suite.do(http.MethodDelete, fmt.Sprintf("/api/v1/notifications/%d", update.ID), nil, nil)
+	suite.Equal(http.StatusNoContent, rec.Code)
+
+	rec = suite.do(http.MethodGet, fmt.Sprintf("/api/v1/notifications/%d", update.ID), nil, &apiErr)
+	suite.Equal(http.StatusNotFound, rec.Code)
+}
+
+func (suite *apiTestSuite) TestNotificationValidation() {
+	var apiErr apiErrorResponse
+	rec := suite.do(http.MethodPost, "/api/v1/notifications", notificationJSON{
+		PersonID:         -1,
+		Type:             "anniversary",
+		NotificationTime: time.Now(),
+		Channels:         []string{"pigeon"},
+	}, &apiErr)
+	suite.Equal(http.StatusUnprocessableEntity, rec.Code)
+	suite.Contains(apiErr.Error.Fields, "type")
+	suite.Contains(apiErr.Error.Fields, "channels")
+
+	rec = suite.do(http.MethodPost, "/api/v1/notifications", notificationJSON{
+		PersonID:         1 << 30,
+		Type:             notifications.Birthday,
+		NotificationTime: time.Now(),
+	}, &apiErr)
+	suite.Equal(http.StatusUnprocessableEntity, rec.Code)
+	suite.Equal("does not exist", apiErr.Error.Fields["person_id"])
+
+	rec = suite.do(http.MethodGet, "/api/v1/notifications?status=lost", nil, &apiErr)
+	suite.Equal(http.StatusUnprocessableEntity, rec.Code)
+	suite.Contains(apiErr.Error.Fields, "status")
+}
+
+func (suite *apiTestSuite) TestBearerToken() {
+	ctx := txcontext.WithTx(owner.WithID(suite.Ctx, users.DefaultID), suite.tx)
+
+	_, readSecret, err := suite.app.tokenRepo.Insert(ctx, "Read", tokens.ScopeRead, time.Time{})
+	suite.Require().NoError(err)
+
+	_, writeSecret, err := suite.app.tokenRepo.Insert(ctx, "Write", tokens.ScopeReadWrite, time.Time{})
+	suite.Require().NoError(err)
+
+	_, expiredSecret, err := suite.app.tokenRepo.Insert(ctx, "Expired", tokens.ScopeReadWrite, time.Now().Add(-time.Hour))
+	suite.Require().NoError(err)
+
+	// send goes through the whole API chain, without an owner, so only the
+	// token can authenticate the request.
+	send := func(method, authorization, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/v1/persons", strings.NewReader(body))
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+
+		rec := httptest.NewRecorder()
+		suite.app.api().ServeHTTP(rec, r.WithContext(txcontext.WithTx(r.Context(), suite.tx)))
+		return rec
+	}
+
+	person := `{"first_name": "Anna"}`
+
+	rec := send(http.MethodGet, "", "")
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	suite.Equal("Bearer", rec.Header().Get("WWW-Authenticate"))
+
+	suite.Equal(http.StatusOK, send(http.MethodGet, "Bearer "+readSecret, "").Code)
+	suite.Equal(http.StatusForbidden, send(http.MethodPost, "Bearer "+readSecret, person).Code)
+	suite.Equal(http.StatusCreated, send(http.MethodPost, "Bearer "+writeSecret, person).Code)
+	suite.Equal(http.StatusUnauthorized, send(http.MethodGet, "Bearer "+expiredSecret, "").Code)
+	suite.Equal(http.StatusUnauthorized, send(http.MethodGet, "Bearer pcrm_unknown", "").Code)
+	suite.Equal(http.StatusUnauthorized, send(http.MethodGet, "Basic "+readSecret, "").Code)
+
+	ts, err := suite.app.tokenRepo.List(ctx)
+	suite.Require().NoError(err)
+	for _, t := range ts {
+		suite.Equal(t.Name != "Expired", t.LastUsedAt.Valid, t.Name)
+	}
+}
+
+func TestNotificationJSONSharedChannels(t *testing.T) {
+	nj := notificationJSON{
+		PersonID:         1,
+		Type:             notifications.Birthday,
+		NotificationTime: time.Now(),
+		Channels:         []string{"telegram", "email"},
+	}
+
+	var n notifications.Notification
+	assert.Empty(t, nj.notification(&n, users.DefaultID))
+	assert.Equal(t, []string{"telegram", "email"}, n.Channels)
+
+	// Other users would have their reminders sent to the deployment's
+	// mailbox and webhook.
+	fields := nj.notification(&n, users.DefaultID+1)
+	assert.Equal(t, map[string]string{"channels": "must be telegram"}, fields)
+
+	nj.Channels = []string{"webhook"}
+	assert.Contains(t, nj.notification(&n, users.DefaultID+1), "channels")
+
+	nj.Channels = []string{"telegram"}
+	assert.Empty(t, nj.notification(&n, users.DefaultID+1))
+}
+
+func TestAPITestSuite(t *testing.T) {
+	suite.Run(t, new(apiTestSuite))
+}
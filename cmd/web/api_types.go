@@ -0,0 +1,258 @@
+package main
+
+import (
+	"database/sql"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/notifications"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/notifier"
+	"github.com/lincentpega/personal-crm/internal/validator"
+)
+
+// personJSON is a person as the API exchanges it. On input id and
+// last_contacted_at are read-only and ignored.
+type personJSON struct {
+	ID              int           `json:"id"`
+	FirstName       string        `json:"first_name"`
+	LastName        *string       `json:"last_name"`
+	SecondName      *string       `json:"second_name"`
+	BirthDate       *string       `json:"birth_date"`
+	LastContactedAt *time.Time    `json:"last_contacted_at"`
+	Contacts        []contactJSON `json:"contacts"`
+	Jobs            []jobJSON     `json:"jobs"`
+	Settings        settingsJSON  `json:"settings"`
+}
+
+type contactJSON struct {
+	Method  person.ContactMethod `json:"method"`
+	Data    string               `json:"data"`
+	Primary bool                 `json:"primary"`
+}
+
+type jobJSON struct {
+	Company  string `json:"company"`
+	Position string `json:"position"`
+	Current  bool   `json:"current"`
+}
+
+type settingsJSON struct {
+	BirthdayNotify           bool `json:"birthday_notify"`
+	BirthdayNotifyDaysBefore int  `json:"birthday_notify_days_before"`
+	// KeepInTouch is a cadence such as "2w". Empty means off.
+	KeepInTouch string `json:"keep_in_touch"`
+}
+
+type searchResultJSON struct {
+	Person personJSON `json:"person"`
+	Rank   float64    `json:"rank"`
+}
+
+// notificationJSON is a notification as the API exchanges it. On input id,
+// attempts and last_error are read-only and ignored.
+type notificationJSON struct {
+	ID               int                  `json:"id"`
+	PersonID         int                  `json:"person_id"`
+	Type             notifications.Type   `json:"type"`
+	Status           notifications.Status `json:"status"`
+	NotificationTime time.Time            `json:"notification_time"`
+	Description      string               `json:"description"`
+	Channels         []string             `json:"channels"`
+	Attempts         int                  `json:"attempts"`
+	LastError        *string              `json:"last_error"`
+}
+
+type paginationJSON struct {
+	Page       int `json:"page"`
+	PageSize   int `json:"page_size"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+func newPaginationJSON(page, pageSize, total int) paginationJSON {
+	return paginationJSON{
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+		TotalPages: (total + pageSize - 1) / pageSize,
+	}
+}
+
+func nullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+func newPersonJSON(p *person.Person) personJSON {
+	pj := personJSON{
+		ID:         p.ID,
+		FirstName:  p.FirstName,
+		LastName:   nullString(p.LastName),
+		SecondName: nullString(p.SecondName),
+		Contacts:   []contactJSON{},
+		Jobs:       []jobJSON{},
+		Settings: settingsJSON{
+			BirthdayNotify:           p.Settings.BirthdayNotify,
+			BirthdayNotifyDaysBefore: p.Settings.BirthdayNotifyDaysBefore,
+			KeepInTouch:              p.Settings.KeepInTouch.String(),
+		},
+	}
+
+	if p.BirthDate.Valid {
+		birthDate := p.BirthDate.Time.Format(time.DateOnly)
+		pj.BirthDate = &birthDate
+	}
+
+	if p.LastContactedAt.Valid {
+		pj.LastContactedAt = &p.LastContactedAt.Time
+	}
+
+	for _, c := range p.ContactInfos {
+		pj.Contacts = append(pj.Contacts, contactJSON{Method: c.Method, Data: c.Data, Primary: c.Primary})
+	}
+
+	for _, j := range p.JobInfos {
+		pj.Jobs = append(pj.Jobs, jobJSON{Company: j.Company, Position: j.Position, Current: j.Current})
+	}
+
+	return pj
+}
+
+func newPersonsJSON(ps []person.Person) []personJSON {
+	pjs := make([]personJSON, 0, len(ps))
+	for i := range ps {
+		pjs = append(pjs, newPersonJSON(&ps[i]))
+	}
+	return pjs
+}
+
+// apiPersonFields names the fields of the person form the way the API
+// calls them.
+var apiPersonFields = map[string]string{
+	"birthday_notify_days_before": "settings.birthday_notify_days_before",
+	"keep_in_touch":               "settings.keep_in_touch",
+}
+
+// person validates the person and fills p with it. It applies the rules of
+// the web form, reporting the errors under the names of the JSON fields.
+func (pj personJSON) person(p *person.Person) map[string]string {
+	f := &personForm{
+		FirstName:      strings.TrimSpace(pj.FirstName),
+		BirthdayNotify: pj.Settings.BirthdayNotify,
+		KeepInTouch:    strings.TrimSpace(pj.Settings.KeepInTouch),
+	}
+
+	if pj.LastName != nil {
+		f.LastName = strings.TrimSpace(*pj.LastName)
+	}
+	if pj.SecondName != nil {
+		f.SecondName = strings.TrimSpace(*pj.SecondName)
+	}
+	if pj.BirthDate != nil {
+		f.BirthDate = strings.TrimSpace(*pj.BirthDate)
+	}
+	if pj.Settings.BirthdayNotifyDaysBefore != 0 {
+		f.BirthdayNotifyDaysBefore = strconv.Itoa(pj.Settings.BirthdayNotifyDaysBefore)
+	}
+
+	for _, c := range pj.Contacts {
+		f.Contacts = append(f.Contacts, contactRow{Method: c.Method, Data: strings.TrimSpace(c.Data), Primary: c.Primary})
+	}
+	for _, j := range pj.Jobs {
+		f.Jobs = append(f.Jobs, jobRow{Company: strings.TrimSpace(j.Company), Position: strings.TrimSpace(j.Position), Current: j.Current})
+	}
+
+	f.person(p)
+	if f.Valid() {
+		return nil
+	}
+
+	fields := make(map[string]string, len(f.FieldErrors))
+	for key, message := range f.FieldErrors {
+		switch {
+		case apiPersonFields[key] != "":
+			key = apiPersonFields[key]
+		case strings.HasPrefix(key, "contact."):
+			key = "contacts." + strings.TrimPrefix(key, "contact.")
+		case strings.HasPrefix(key, "job."):
+			key = "jobs." + strings.TrimPrefix(key, "job.")
+		}
+		fields[key] = message
+	}
+
+	return fields
+}
+
+func newNotificationJSON(n *notifications.Notification) notificationJSON {
+	channels := n.Channels
+	if channels == nil {
+		channels = []string{}
+	}
+
+	return notificationJSON{
+		ID:               n.ID,
+		PersonID:         n.PersonID,
+		Type:             n.Type,
+		Status:           n.Status,
+		NotificationTime: n.NotificationTime,
+		Description:      n.Description,
+		Channels:         channels,
+		Attempts:         n.Attempts,
+		LastError:        nullString(n.LastError),
+	}
+}
+
+func newNotificationsJSON(ns []notifications.Notification) []notificationJSON {
+	njs := make([]notificationJSON, 0, len(ns))
+	for i := range ns {
+		njs = append(njs, newNotificationJSON(&ns[i]))
+	}
+	return njs
+}
+
+var (
+	notificationTypes = []notifications.Type{notifications.KeepInTouch, notifications.Birthday}
+	// notificationStatuses leaves out processing, which only the sender
+	// sets while it holds a lease.
+	notificationStatuses = []notifications.Status{
+		notifications.Pending, notifications.Raised, notifications.Failed,
+		notifications.Acknowledged, notifications.Snoozed, notifications.Skipped,
+	}
+	notificationChannels = []string{"telegram", "email", "webhook"}
+)
+
+// notification validates the notification of the owner and fills n with
+// it. A missing status is left as it is in n.
+func (nj notificationJSON) notification(n *notifications.Notification, ownerID int) map[string]string {
+	var v validator.Validator
+
+	v.CheckField(nj.PersonID > 0, "person_id", "must be provided")
+	v.CheckField(slices.Contains(notificationTypes, nj.Type), "type", "must be keep_in_touch or birthday")
+	if nj.Status != "" {
+		v.CheckField(slices.Contains(notificationStatuses, nj.Status), "status", "is not a valid status")
+	}
+	v.CheckField(!nj.NotificationTime.IsZero(), "notification_time", "must be provided")
+	v.CheckField(validator.MaxChars(nj.Description, 500), "description", "must not be more than 500 characters long")
+	for _, c := range nj.Channels {
+		v.CheckField(slices.Contains(notificationChannels, c), "channels", "must be telegram, email or webhook")
+		// The mailbox and the webhook are set for the whole deployment.
+		v.CheckField(!notifier.SharedRecipient(c) || ownerID == users.DefaultID, "channels", "must be telegram")
+	}
+
+	n.PersonID = nj.PersonID
+	n.Type = nj.Type
+	if nj.Status != "" {
+		n.Status = nj.Status
+	}
+	n.NotificationTime = nj.NotificationTime.UTC()
+	n.Description = nj.Description
+	n.Channels = nj.Channels
+
+	return v.FieldErrors
+}
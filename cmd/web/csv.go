@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/personcsv"
+	"github.com/lincentpega/personal-crm/internal/validator"
+)
+
+const (
+	// importFileKey is the session key holding the uploaded CSV file
+	// between the mapping and import steps.
+	importFileKey = "importCSV"
+	maxImportSize = 1 << 20
+)
+
+// importForm is the column mapping step of a CSV import. Result is the
+// outcome of the last dry run or of a failed import.
+type importForm struct {
+	Columns []importColumn
+	Fields  []personcsv.Field
+	Result  *personcsv.Result
+	// Mapped reports whether the columns were mapped by the user rather
+	// than guessed.
+	Mapped bool
+	validator.Validator
+}
+
+type importColumn struct {
+	Name   string
+	Sample string
+	Field  personcsv.Field
+	Index  int
+}
+
+func (app *application) personExport(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := personcsv.Export(r.Context(), &buf, app.personRepo); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("persons-%s.csv", time.Now().Format(time.DateOnly))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	buf.WriteTo(w)
+}
+
+func (app *application) personImport(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = &importForm{}
+
+	app.render(w, http.StatusOK, "import.html", data)
+}
+
+// personImportPost takes the uploaded file and asks how its columns map to
+// the fields of a person, guessing from the header.
+func (app *application) personImportPost(w http.ResponseWriter, r *http.Request) {
+	form := &importForm{}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		form.AddFieldError("file", "Choose a CSV file")
+	} else {
+		defer file.Close()
+	}
+
+	var content []byte
+	if form.Valid() {
+		content, err = io.ReadAll(io.LimitReader(file, maxImportSize+1))
+		switch {
+		case err != nil:
+			app.serverError(w, err)
+			return
+		case len(content) > maxImportSize:
+			form.AddFieldError("file", "The file cannot be larger than 1 MB")
+		}
+	}
+
+	if form.Valid() {
+		if err := form.readColumns(content, nil); err != nil {
+			form.AddFieldError("file", fileError(err))
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	if !form.Valid() {
+		app.render(w, http.StatusUnprocessableEntity, "import.html", data)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), importFileKey, string(content))
+
+	app.render(w, http.StatusOK, "import.html", data)
+}
+
+// personImportRunPost previews the import of the uploaded file with the
+// chosen mapping or, if asked to, applies it.
+func (app *application) personImportRunPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	content := app.sessionManager.GetString(r.Context(), importFileKey)
+	if content == "" {
+		app.sessionManager.Put(r.Context(), "flash", "Upload the file again, the previous upload has expired")
+		http.Redirect(w, r, "/persons/import", http.StatusSeeOther)
+		return
+	}
+
+	form := &importForm{Mapped: true}
+	if err := form.readColumns([]byte(content), r.PostForm); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	m := form.mapping()
+	if _, ok := m[personcsv.FirstName]; !ok {
+		form.AddNonFieldError("Map a column to first_name")
+	}
+	for _, c := range form.Columns {
+		if c.Field != "" && m[c.Field] != c.Index {
+			form.AddNonFieldError(fmt.Sprintf("Only one column can be mapped to %s", c.Field))
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	if !form.Valid() {
+		app.render(w, http.StatusUnprocessableEntity, "import.html", data)
+		return
+	}
+
+	dryRun := r.PostForm.Get("action") != "import"
+
+	result, err := app.importer.Import(r.Context(), strings.NewReader(content), m, dryRun)
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		// Only the first rows are read on upload, so a malformed line
+		// further down shows up here.
+		form.AddNonFieldError(fileError(err))
+		app.render(w, http.StatusUnprocessableEntity, "import.html", data)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if result.Applied {
+		app.sessionManager.Remove(r.Context(), importFileKey)
+		app.sessionManager.Put(r.Context(), "flash",
+			fmt.Sprintf("Imported %d people, skipped %d duplicates", result.New(), result.Duplicates()))
+		http.Redirect(w, r, "/persons", http.StatusSeeOther)
+		return
+	}
+
+	form.Result = result
+
+	status := http.StatusOK
+	if result.Invalid() > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+
+	app.render(w, status, "import.html", data)
+}
+
+// readColumns reads the header and first row of the file. The columns are
+// mapped as submitted in the col_<index> fields, or guessed if values is
+// nil.
+func (f *importForm) readColumns(content []byte, values map[string][]string) error {
+	header, sample, err := personcsv.ReadHeader(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	guessed := personcsv.GuessMapping(header)
+
+	f.Fields = personcsv.Fields
+	f.Columns = make([]importColumn, len(header))
+	for i, name := range header {
+		c := importColumn{Name: name, Index: i}
+		if i < len(sample) {
+			c.Sample = sample[i]
+		}
+
+		if values == nil {
+			for field, index := range guessed {
+				if index == i {
+					c.Field = field
+				}
+			}
+		} else if v := values[fmt.Sprintf("col_%d", i)]; len(v) > 0 && v[0] != "" {
+			if c.Field, err = personcsv.ParseField(v[0]); err != nil {
+				f.AddNonFieldError(fmt.Sprintf("Column %s is mapped to an unknown field", name))
+			}
+		}
+
+		f.Columns[i] = c
+	}
+
+	return nil
+}
+
+func (f *importForm) mapping() personcsv.Mapping {
+	m := make(personcsv.Mapping)
+	for _, c := range f.Columns {
+		if _, taken := m[c.Field]; c.Field != "" && !taken {
+			m[c.Field] = c.Index
+		}
+	}
+	return m
+}
+
+// fileError describes why the uploaded file can't be read.
+func fileError(err error) string {
+	var parseErr *csv.ParseError
+	switch {
+	case errors.Is(err, personcsv.ErrEmpty):
+		return "The file is empty"
+	case errors.As(err, &parseErr):
+		return fmt.Sprintf("This doesn't look like a CSV file: %v", parseErr)
+	default:
+		return "The file could not be read"
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/lincentpega/personal-crm/internal/personcsv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testImportFile = "Given Name,Nickname,Mobile\nAnna,Annie,+79161234567\n"
+
+func TestImportFormGuessedColumns(t *testing.T) {
+	form := &importForm{}
+	require.NoError(t, form.readColumns([]byte(testImportFile), nil))
+
+	assert.Equal(t, []importColumn{
+		{Name: "Given Name", Sample: "Anna", Field: personcsv.FirstName, Index: 0},
+		{Name: "Nickname", Sample: "Annie", Index: 1},
+		{Name: "Mobile", Sample: "+79161234567", Field: personcsv.Phone, Index: 2},
+	}, form.Columns)
+	assert.Equal(t, personcsv.Mapping{personcsv.FirstName: 0, personcsv.Phone: 2}, form.mapping())
+}
+
+func TestImportFormSubmittedColumns(t *testing.T) {
+	form := &importForm{}
+	values := url.Values{"col_0": {"first_name"}, "col_1": {"other"}, "col_2": {""}}
+	require.NoError(t, form.readColumns([]byte(testImportFile), values))
+
+	assert.True(t, form.Valid())
+	assert.Equal(t, personcsv.Mapping{personcsv.FirstName: 0, personcsv.Other: 1}, form.mapping())
+
+	form = &importForm{}
+	require.NoError(t, form.readColumns([]byte(testImportFile), url.Values{"col_1": {"nickname"}}))
+	assert.False(t, form.Valid())
+
+	assert.Error(t, (&importForm{}).readColumns(nil, nil))
+}
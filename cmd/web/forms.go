@@ -0,0 +1,240 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/validator"
+)
+
+// blankRows is the number of empty contact and job rows offered by the
+// person form for adding new ones.
+const blankRows = 2
+
+// personForm is the create and edit form of a person. Contacts and jobs are
+// submitted as parallel lists of fields, one entry per row; rows left blank
+// are ignored.
+type personForm struct {
+	FirstName                string
+	LastName                 string
+	SecondName               string
+	BirthDate                string
+	BirthdayNotifyDaysBefore string
+	KeepInTouch              string
+	Contacts                 []contactRow
+	Jobs                     []jobRow
+	BirthdayNotify           bool
+	validator.Validator
+}
+
+type contactRow struct {
+	Method  person.ContactMethod
+	Data    string
+	Primary bool
+}
+
+type jobRow struct {
+	Company  string
+	Position string
+	Current  bool
+}
+
+func newPersonForm(p *person.Person) *personForm {
+	f := &personForm{
+		FirstName:      p.FirstName,
+		LastName:       p.LastName.String,
+		SecondName:     p.SecondName.String,
+		BirthdayNotify: p.Settings.BirthdayNotify,
+		KeepInTouch:    p.Settings.KeepInTouch.String(),
+	}
+
+	if p.BirthDate.Valid {
+		f.BirthDate = p.BirthDate.Time.Format(time.DateOnly)
+	}
+
+	if p.Settings.BirthdayNotifyDaysBefore > 0 {
+		f.BirthdayNotifyDaysBefore = strconv.Itoa(p.Settings.BirthdayNotifyDaysBefore)
+	}
+
+	for _, c := range p.ContactInfos {
+		f.Contacts = append(f.Contacts, contactRow{Method: c.Method, Data: c.Data, Primary: c.Primary})
+	}
+
+	for _, j := range p.JobInfos {
+		f.Jobs = append(f.Jobs, jobRow{Company: j.Company, Position: j.Position, Current: j.Current})
+	}
+
+	return f
+}
+
+// parsePersonForm reads the submitted person form. Checkbox rows are
+// identified by the index of the row they belong to.
+func parsePersonForm(r *http.Request) (*personForm, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	form := r.PostForm
+
+	f := &personForm{
+		FirstName:                strings.TrimSpace(form.Get("first_name")),
+		LastName:                 strings.TrimSpace(form.Get("last_name")),
+		SecondName:               strings.TrimSpace(form.Get("second_name")),
+		BirthDate:                strings.TrimSpace(form.Get("birth_date")),
+		BirthdayNotify:           form.Get("birthday_notify") != "",
+		BirthdayNotifyDaysBefore: strings.TrimSpace(form.Get("birthday_notify_days_before")),
+		KeepInTouch:              strings.TrimSpace(form.Get("keep_in_touch")),
+	}
+
+	methods := form["contact_method"]
+	primary := indexSet(form["contact_primary"])
+	for i, data := range form["contact_data"] {
+		data = strings.TrimSpace(data)
+		if data == "" {
+			continue
+		}
+
+		row := contactRow{Data: data, Primary: primary[i]}
+		if i < len(methods) {
+			row.Method = person.ContactMethod(methods[i])
+		}
+		f.Contacts = append(f.Contacts, row)
+	}
+
+	positions := form["job_position"]
+	current := indexSet(form["job_current"])
+	for i, company := range form["job_company"] {
+		row := jobRow{Company: strings.TrimSpace(company), Current: current[i]}
+		if i < len(positions) {
+			row.Position = strings.TrimSpace(positions[i])
+		}
+		if row.Company == "" && row.Position == "" {
+			continue
+		}
+		f.Jobs = append(f.Jobs, row)
+	}
+
+	return f, nil
+}
+
+func indexSet(values []string) map[int]bool {
+	set := make(map[int]bool, len(values))
+	for _, v := range values {
+		if i, err := strconv.Atoi(v); err == nil {
+			set[i] = true
+		}
+	}
+	return set
+}
+
+// withBlankRows returns the form with empty contact and job rows appended,
+// ready to be rendered.
+func (f *personForm) withBlankRows() *personForm {
+	for range blankRows {
+		f.Contacts = append(f.Contacts, contactRow{Method: person.Phone})
+		f.Jobs = append(f.Jobs, jobRow{})
+	}
+	return f
+}
+
+// person validates the form and fills p with its values. The form reports
+// whether it is valid; p must not be stored otherwise.
+func (f *personForm) person(p *person.Person) {
+	f.CheckField(validator.NotBlank(f.FirstName), "first_name", "This field cannot be blank")
+	f.CheckField(validator.MaxChars(f.FirstName, 100), "first_name", "This field cannot be more than 100 characters long")
+	f.CheckField(validator.MaxChars(f.LastName, 100), "last_name", "This field cannot be more than 100 characters long")
+	f.CheckField(validator.MaxChars(f.SecondName, 100), "second_name", "This field cannot be more than 100 characters long")
+
+	p.FirstName = f.FirstName
+	p.LastName = sql.NullString{String: f.LastName, Valid: f.LastName != ""}
+	p.SecondName = sql.NullString{String: f.SecondName, Valid: f.SecondName != ""}
+	p.BirthDate = sql.NullTime{}
+	if f.BirthDate != "" {
+		birthDate, err := time.Parse(time.DateOnly, f.BirthDate)
+		switch {
+		case err != nil:
+			f.AddFieldError("birth_date", "Enter a date as YYYY-MM-DD")
+		case birthDate.After(time.Now()):
+			f.AddFieldError("birth_date", "Birth date cannot be in the future")
+		default:
+			p.BirthDate = sql.NullTime{Time: birthDate, Valid: true}
+		}
+	}
+
+	p.Settings = person.Settings{BirthdayNotify: f.BirthdayNotify}
+	if f.BirthdayNotifyDaysBefore != "" {
+		days, err := strconv.Atoi(f.BirthdayNotifyDaysBefore)
+		f.CheckField(err == nil && validator.Between(days, 0, 365), "birthday_notify_days_before",
+			"Enter a number of days from 0 to 365")
+		p.Settings.BirthdayNotifyDaysBefore = days
+	}
+	if f.BirthdayNotify || p.Settings.BirthdayNotifyDaysBefore > 0 {
+		f.CheckField(f.BirthDate != "", "birth_date", "Birthday reminders need a birth date")
+	}
+
+	if f.KeepInTouch != "" {
+		cadence, err := person.ParseCadence(f.KeepInTouch)
+		f.CheckField(err == nil, "keep_in_touch", "Enter a cadence such as 2w, 3 months or monthly")
+		p.Settings.KeepInTouch = cadence
+	}
+
+	p.ContactInfos = nil
+	primary := make(map[person.ContactMethod]bool)
+	for i, row := range f.Contacts {
+		key := fmt.Sprintf("contact.%d", i)
+
+		method, err := person.ParseContactMethod(string(row.Method))
+		if err != nil {
+			f.AddFieldError(key, "Choose a contact method")
+			continue
+		}
+
+		c, err := person.ContactInfo{Method: method, Data: row.Data, Primary: row.Primary}.Normalize()
+		if err != nil {
+			f.AddFieldError(key, fmt.Sprintf("This doesn't look like a valid %s", method))
+			continue
+		}
+		f.CheckField(validator.MaxChars(c.Data, 256), key, "This contact cannot be more than 256 characters long")
+
+		if c.Primary {
+			f.CheckField(!primary[c.Method], key, fmt.Sprintf("Only one %s can be primary", c.Method))
+			primary[c.Method] = true
+		}
+
+		p.ContactInfos = append(p.ContactInfos, c)
+	}
+
+	p.JobInfos = nil
+	for i, row := range f.Jobs {
+		key := fmt.Sprintf("job.%d", i)
+		f.CheckField(validator.NotBlank(row.Company), key, "Company cannot be blank")
+		f.CheckField(validator.MaxChars(row.Company, 256), key, "Company cannot be more than 256 characters long")
+		f.CheckField(validator.MaxChars(row.Position, 256), key, "Position cannot be more than 256 characters long")
+		p.JobInfos = append(p.JobInfos, person.JobInfo{Company: row.Company, Position: row.Position, Current: row.Current})
+	}
+}
+
+type loginForm struct {
+	Email    string
+	Password string
+	validator.Validator
+}
+
+type linkForm struct {
+	Code string
+	validator.Validator
+}
+
+type tokenForm struct {
+	Name  string
+	Scope string
+	// ExpiresIn is the number of days the token is valid for. Empty means
+	// it never expires.
+	ExpiresIn string
+	validator.Validator
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFormRequest(t *testing.T, values url.Values) *personForm {
+	t.Helper()
+
+	r := httptest.NewRequest("POST", "/persons", strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	f, err := parsePersonForm(r)
+	require.NoError(t, err)
+
+	return f
+}
+
+func TestPersonFormValid(t *testing.T) {
+	f := newFormRequest(t, url.Values{
+		"first_name":                  {" Anna "},
+		"last_name":                   {"Karenina"},
+		"birth_date":                  {"1992-02-29"},
+		"birthday_notify":             {"1"},
+		"birthday_notify_days_before": {"3"},
+		"keep_in_touch":               {"monthly"},
+		"contact_method":              {"phone", "email", "phone"},
+		"contact_data":                {"+7 999 123-45-67", "", "+44 1234 567890"},
+		"contact_primary":             {"2"},
+		"job_company":                 {"Acme", ""},
+		"job_position":                {"Designer", ""},
+		"job_current":                 {"0"},
+	})
+
+	var p person.Person
+	f.person(&p)
+
+	require.True(t, f.Valid(), f.FieldErrors)
+	assert.Equal(t, "Anna Karenina", p.FullName())
+	assert.Equal(t, time.Date(1992, time.February, 29, 0, 0, 0, 0, time.UTC), p.BirthDate.Time)
+	assert.Equal(t, []person.ContactInfo{
+		{Method: person.Phone, Data: "+79991234567"},
+		{Method: person.Phone, Data: "+441234567890", Primary: true},
+	}, p.ContactInfos)
+	assert.Equal(t, []person.JobInfo{{Company: "Acme", Position: "Designer", Current: true}}, p.JobInfos)
+	assert.Equal(t, person.Settings{
+		BirthdayNotify:           true,
+		BirthdayNotifyDaysBefore: 3,
+		KeepInTouch:              person.Cadence{Count: 1, Unit: person.Month},
+	}, p.Settings)
+}
+
+func TestPersonFormErrors(t *testing.T) {
+	f := newFormRequest(t, url.Values{
+		"first_name":                  {"  "},
+		"birthday_notify":             {"1"},
+		"birthday_notify_days_before": {"400"},
+		"keep_in_touch":               {"sometimes"},
+		"contact_method":              {"email", "phone", "phone"},
+		"contact_data":                {"not an email", "+79991234567", "+79997654321"},
+		"contact_primary":             {"1", "2"},
+		"job_company":                 {""},
+		"job_position":                {"Designer"},
+	})
+
+	var p person.Person
+	f.person(&p)
+
+	assert.False(t, f.Valid())
+	assert.Equal(t, map[string]string{
+		"first_name":                  "This field cannot be blank",
+		"birth_date":                  "Birthday reminders need a birth date",
+		"birthday_notify_days_before": "Enter a number of days from 0 to 365",
+		"keep_in_touch":               "Enter a cadence such as 2w, 3 months or monthly",
+		"contact.0":                   "This doesn't look like a valid email",
+		"contact.2":                   "Only one phone can be primary",
+		"job.0":                       "Company cannot be blank",
+	}, f.FieldErrors)
+}
+
+func TestPersonFormLengths(t *testing.T) {
+	long := strings.Repeat("я", 257)
+	f := newFormRequest(t, url.Values{
+		"first_name":     {"Anna"},
+		"contact_method": {"other", "address"},
+		"contact_data":   {long, long[:256*2]},
+		"job_company":    {long, "Acme"},
+		"job_position":   {"", long},
+	})
+
+	var p person.Person
+	f.person(&p)
+
+	assert.Equal(t, map[string]string{
+		"contact.0": "This contact cannot be more than 256 characters long",
+		"job.0":     "Company cannot be more than 256 characters long",
+		"job.1":     "Position cannot be more than 256 characters long",
+	}, f.FieldErrors)
+}
+
+func TestNewPersonFormRoundTrip(t *testing.T) {
+	p := &person.Person{
+		FirstName:    "Boris",
+		ContactInfos: []person.ContactInfo{{Method: person.Telegram, Data: "boris_b", Primary: true}},
+		Settings:     person.Settings{KeepInTouch: person.Cadence{Count: 2, Unit: person.Week}},
+	}
+
+	f := newPersonForm(p)
+
+	var got person.Person
+	f.person(&got)
+
+	require.True(t, f.Valid(), f.FieldErrors)
+	assert.Equal(t, p, &got)
+}
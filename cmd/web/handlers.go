@@ -1,9 +1,353 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/validator"
+)
+
+const (
+	timelinePageSize = 50
+	searchLimit      = 20
+	personsPageSize  = 20
 )
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	app.render(w, "home.html", nil)
+	app.render(w, http.StatusOK, "home.html", app.newTemplateData(r))
+}
+
+// getPerson loads the person named by the id path value, responding with
+// an error if there is none.
+func (app *application) getPerson(w http.ResponseWriter, r *http.Request) (*person.Person, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	p, err := app.personRepo.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	return p, true
+}
+
+func (app *application) personInteractions(w http.ResponseWriter, r *http.Request) {
+	p, ok := app.getPerson(w, r)
+	if !ok {
+		return
+	}
+
+	timeline, err := app.interactionRepo.ListByPerson(r.Context(), p.ID, timelinePageSize, 0)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Person = p
+	data.Interactions = timeline
+
+	app.render(w, http.StatusOK, "interactions.html", data)
+}
+
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	results, err := app.personRepo.Search(r.Context(), query, searchLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Query = query
+	data.SearchResults = results
+
+	app.render(w, http.StatusOK, "search.html", data)
+}
+
+// personList shows a page of persons, or the best matches when the q query
+// parameter is set. Requests made by htmx get only the list itself.
+func (app *application) personList(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	data := app.newTemplateData(r)
+	data.Query = strings.TrimSpace(params.Get("q"))
+
+	if data.Query != "" {
+		results, err := app.personRepo.Search(r.Context(), data.Query, searchLimit)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		for _, res := range results {
+			data.Persons = append(data.Persons, res.Person)
+		}
+	} else {
+		opts := person.ListOptions{
+			Sort:     person.SortByName,
+			Desc:     params.Get("desc") != "",
+			PageSize: personsPageSize,
+		}
+		if person.SortField(params.Get("sort")) == person.SortByBirthDate {
+			opts.Sort = person.SortByBirthDate
+		}
+		if page, err := strconv.Atoi(params.Get("page")); err == nil && page > 1 {
+			opts.Page = page
+		} else {
+			opts.Page = 1
+		}
+
+		persons, total, err := app.personRepo.List(r.Context(), opts)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data.Persons = persons
+		data.Pagination = pagination{
+			Sort:       opts.Sort,
+			Desc:       opts.Desc,
+			Page:       opts.Page,
+			TotalPages: (total + personsPageSize - 1) / personsPageSize,
+		}
+	}
+
+	if isHTMX(r) {
+		app.renderPartial(w, http.StatusOK, "persons.html", "people", data)
+		return
+	}
+
+	app.render(w, http.StatusOK, "persons.html", data)
+}
+
+func (app *application) personView(w http.ResponseWriter, r *http.Request) {
+	p, ok := app.getPerson(w, r)
+	if !ok {
+		return
+	}
+
+	upcoming, err := app.notifRepo.ListUpcoming(r.Context(), p.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Person = p
+	data.Notifications = upcoming
+
+	app.render(w, http.StatusOK, "person.html", data)
+}
+
+func (app *application) personCreate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = (&personForm{}).withBlankRows()
+
+	app.render(w, http.StatusOK, "person_create.html", data)
+}
+
+func (app *application) personCreatePost(w http.ResponseWriter, r *http.Request) {
+	form, err := parsePersonForm(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var p person.Person
+	form.person(&p)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form.withBlankRows()
+		app.render(w, http.StatusUnprocessableEntity, "person_create.html", data)
+		return
+	}
+
+	if err := app.personRepo.Insert(r.Context(), &p); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s is added", p.FullName()))
+
+	http.Redirect(w, r, fmt.Sprintf("/persons/%d", p.ID), http.StatusSeeOther)
+}
+
+func (app *application) personEdit(w http.ResponseWriter, r *http.Request) {
+	p, ok := app.getPerson(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Person = p
+	data.Form = newPersonForm(p).withBlankRows()
+
+	app.render(w, http.StatusOK, "person_edit.html", data)
+}
+
+func (app *application) personEditPost(w http.ResponseWriter, r *http.Request) {
+	p, ok := app.getPerson(w, r)
+	if !ok {
+		return
+	}
+
+	form, err := parsePersonForm(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	original := *p
+	form.person(p)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Person = &original
+		data.Form = form.withBlankRows()
+		app.render(w, http.StatusUnprocessableEntity, "person_edit.html", data)
+		return
+	}
+
+	if err := app.personRepo.Update(r.Context(), p); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Changes are saved")
+
+	http.Redirect(w, r, fmt.Sprintf("/persons/%d", p.ID), http.StatusSeeOther)
+}
+
+func (app *application) personDelete(w http.ResponseWriter, r *http.Request) {
+	p, ok := app.getPerson(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Person = p
+
+	app.render(w, http.StatusOK, "person_delete.html", data)
+}
+
+func (app *application) personDeletePost(w http.ResponseWriter, r *http.Request) {
+	p, ok := app.getPerson(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.personRepo.Delete(r.Context(), p.ID); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s is deleted", p.FullName()))
+
+	http.Redirect(w, r, "/persons", http.StatusSeeOther)
+}
+
+func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = &loginForm{}
+
+	app.render(w, http.StatusOK, "login.html", data)
+}
+
+// userLoginPost logs the user in. Failed attempts are counted per client
+// address and per email, and both are blocked for a while after too many.
+func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := &loginForm{
+		Email:    strings.TrimSpace(r.PostForm.Get("email")),
+		Password: r.PostForm.Get("password"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	if !form.Valid() {
+		app.render(w, http.StatusUnprocessableEntity, "login.html", data)
+		return
+	}
+
+	limiterKeys := []string{"ip:" + clientIP(r), "email:" + strings.ToLower(form.Email)}
+	if !app.loginLimiter.Allow(limiterKeys...) {
+		form.AddNonFieldError("Too many failed attempts, try again later")
+		app.render(w, http.StatusTooManyRequests, "login.html", data)
+		return
+	}
+
+	id, err := app.userRepo.Authenticate(r.Context(), form.Email, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.loginLimiter.Fail(limiterKeys...)
+			form.AddNonFieldError("Email or password is incorrect")
+			app.render(w, http.StatusUnprocessableEntity, "login.html", data)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.loginLimiter.Reset(limiterKeys...)
+
+	if err := app.sessionManager.RenewToken(r.Context()); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The session is now a different one, so it gets a new CSRF token.
+	app.sessionManager.Remove(r.Context(), csrfTokenKey)
+	app.sessionManager.Put(r.Context(), authenticatedUserIDKey, id)
+
+	path := app.sessionManager.PopString(r.Context(), redirectAfterLoginKey)
+	if path == "" {
+		path = "/persons"
+	}
+
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
+	if err := app.sessionManager.RenewToken(r.Context()); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), csrfTokenKey)
+	app.sessionManager.Remove(r.Context(), authenticatedUserIDKey)
+	app.sessionManager.Put(r.Context(), "flash", "You've been logged out")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
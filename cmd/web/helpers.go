@@ -2,8 +2,11 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"runtime/debug"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
 )
 
 func (app *application) serverError(w http.ResponseWriter, err error) {
@@ -20,3 +23,32 @@ func (app *application) clientError(w http.ResponseWriter, status int) {
 func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
+
+// isHTMX reports whether the request was made by htmx to swap a part of the
+// page. History restoration requests need the whole page.
+func isHTMX(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true" && r.Header.Get("HX-History-Restore-Request") != "true"
+}
+
+func (app *application) isAuthenticated(r *http.Request) bool {
+	_, err := owner.ID(r.Context())
+	return err == nil
+}
+
+// clientIP returns the address of the client without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// isSafeMethod reports whether requests with the method only read data.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -10,15 +10,38 @@ import (
 
 	"github.com/alexedwards/scs/postgresstore"
 	"github.com/alexedwards/scs/v2"
+	"github.com/lincentpega/personal-crm/internal/common/tglogin"
 	"github.com/lincentpega/personal-crm/internal/config"
 	"github.com/lincentpega/personal-crm/internal/db"
 	"github.com/lincentpega/personal-crm/internal/log"
+	"github.com/lincentpega/personal-crm/internal/models/interactions"
+	"github.com/lincentpega/personal-crm/internal/models/merges"
+	"github.com/lincentpega/personal-crm/internal/models/notifications"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/models/tokens"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/personcsv"
+)
+
+const (
+	loginMaxFailures = 5
+	loginWindow      = 15 * time.Minute
 )
 
 type application struct {
-	log            *log.Logger
-	templates      map[string]*template.Template
-	sessionManager *scs.SessionManager
+	log             *log.Logger
+	templates       map[string]*template.Template
+	sessionManager  *scs.SessionManager
+	personRepo      *person.PersonRepository
+	interactionRepo *interactions.InteractionRepository
+	notifRepo       *notifications.NotificationRepository
+	userRepo        *users.UserRepository
+	tokenRepo       *tokens.TokenRepository
+	importer        *personcsv.Importer
+	mergeRepo       *merges.MergeRepository
+	loginLimiter    *loginLimiter
+	telegramLogin   *tglogin.Verifier
+	botUsername     string
 }
 
 func main() {
@@ -41,9 +64,21 @@ func main() {
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
 
+	personRepo := person.NewRepository(database)
+
 	app := &application{
-		log:            log,
-		sessionManager: sessionManager,
+		log:             log,
+		sessionManager:  sessionManager,
+		personRepo:      personRepo,
+		interactionRepo: interactions.NewRepository(database),
+		notifRepo:       notifications.NewRepository(database),
+		userRepo:        users.NewRepository(database),
+		tokenRepo:       tokens.NewRepository(database),
+		importer:        personcsv.NewImporter(database, personRepo),
+		mergeRepo:       merges.NewRepository(database, personRepo),
+		loginLimiter:    newLoginLimiter(loginMaxFailures, loginWindow),
+		telegramLogin:   tglogin.NewVerifier(config.Token),
+		botUsername:     config.BotUsername,
 	}
 
 	if err := app.loadTemplates(); err != nil {
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/merges"
+)
+
+const (
+	duplicatesLimit = 50
+	recentMerges    = 10
+)
+
+// personDuplicates lists the likely duplicates to merge and the latest
+// merges to undo.
+func (app *application) personDuplicates(w http.ResponseWriter, r *http.Request) {
+	duplicates, err := app.mergeRepo.FindDuplicates(r.Context(), duplicatesLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	recent, err := app.mergeRepo.List(r.Context(), recentMerges)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Duplicates = duplicates
+	data.Merges = recent
+
+	app.render(w, http.StatusOK, "duplicates.html", data)
+}
+
+func (app *application) personMergePost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	survivorID, err := strconv.Atoi(r.PostForm.Get("survivor_id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	mergedID, err := strconv.Atoi(r.PostForm.Get("merged_id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	m, err := app.mergeRepo.Merge(r.Context(), survivorID, mergedID)
+	switch {
+	case errors.Is(err, models.ErrRecordNotFound):
+		app.notFound(w)
+		return
+	case errors.Is(err, merges.ErrSamePerson):
+		app.clientError(w, http.StatusBadRequest)
+		return
+	case err != nil:
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash",
+		fmt.Sprintf("%s is merged into this person. You can undo it on the duplicates page", m.MergedName))
+
+	http.Redirect(w, r, fmt.Sprintf("/persons/%d", m.SurvivorID), http.StatusSeeOther)
+}
+
+func (app *application) personMergeUndoPost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	m, err := app.mergeRepo.Undo(r.Context(), id)
+	switch {
+	case errors.Is(err, models.ErrRecordNotFound):
+		app.notFound(w)
+		return
+	case errors.Is(err, merges.ErrAlreadyUndone), errors.Is(err, merges.ErrSurvivorDeleted):
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("The merge can't be undone: %v", err))
+		http.Redirect(w, r, "/persons/duplicates", http.StatusSeeOther)
+		return
+	case err != nil:
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s is restored", m.MergedName))
+
+	http.Redirect(w, r, fmt.Sprintf("/persons/%d", m.MergedID), http.StatusSeeOther)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/tokens"
+)
+
+const (
+	authenticatedUserIDKey = "authenticatedUserID"
+	redirectAfterLoginKey  = "redirectPathAfterLogin"
+)
+
+type contextKey string
+
+const apiTokenKey = contextKey("apiToken")
+
+// authenticate scopes the request to the user logged in to the session, if
+// any. A session of a user that no longer exists is logged out.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := app.sessionManager.GetInt(r.Context(), authenticatedUserIDKey)
+		if id == 0 || apiToken(r) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		_, err := app.userRepo.Get(r.Context(), id)
+		if err != nil {
+			if !errors.Is(err, models.ErrRecordNotFound) {
+				app.serverError(w, err)
+				return
+			}
+
+			app.sessionManager.Remove(r.Context(), authenticatedUserIDKey)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(owner.WithID(r.Context(), id)))
+	})
+}
+
+// requireAuthentication sends anonymous requests to the login page, which
+// brings the user back to the page they asked for.
+func (app *application) requireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			if r.Method == http.MethodGet && !isHTMX(r) {
+				app.sessionManager.Put(r.Context(), redirectAfterLoginKey, r.URL.RequestURI())
+			}
+
+			if isHTMX(r) {
+				w.Header().Set("HX-Redirect", "/user/login")
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticateToken scopes the request to the owner of the API token in the
+// Authorization header, if there is one. A read-only token is refused for
+// requests that change data.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		secret, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			app.apiInvalidToken(w)
+			return
+		}
+
+		token, err := app.tokenRepo.Authenticate(r.Context(), strings.TrimSpace(secret))
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				app.apiInvalidToken(w)
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+
+		if !isSafeMethod(r.Method) && !token.CanWrite() {
+			app.apiError(w, http.StatusForbidden, "insufficient_scope", "the token only allows reading")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiTokenKey, token)
+		next.ServeHTTP(w, r.WithContext(owner.WithID(ctx, token.UserID)))
+	})
+}
+
+// apiToken returns the API token the request is authenticated with, if any.
+func apiToken(r *http.Request) *tokens.Token {
+	token, _ := r.Context().Value(apiTokenKey).(*tokens.Token)
+	return token
+}
+
+// apiRequireAuthentication rejects anonymous API requests with a JSON
+// error instead of redirecting them.
+func (app *application) apiRequireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Cache-Control", "no-store")
+
+		if !app.isAuthenticated(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.apiError(w, http.StatusUnauthorized, "unauthenticated", "you must be authenticated to access this resource")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxRequestBody bounds the body of every request, which leaves room for
+// the largest CSV file that can be imported.
+const maxRequestBody = 2 << 20
+
+func limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// contentSecurityPolicy allows htmx from unpkg and the Telegram Login
+// Widget, which is a script from telegram.org that opens a frame of
+// oauth.telegram.org.
+const contentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' https://unpkg.com https://telegram.org; " +
+	"frame-src https://oauth.telegram.org; " +
+	"style-src 'self'; img-src 'self' data:; " +
+	"form-action 'self'; frame-ancestors 'none'; base-uri 'self'"
+
+func secureHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("X-Frame-Options", "deny")
+		w.Header().Set("X-XSS-Protection", "0")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The query is left out as it can carry secrets, such as the state and
+		// the signed payload of the Telegram login callback.
+		app.log.InfoLog.Printf("%s - %s %s %s", clientIP(r), r.Proto, r.Method, r.URL.Path)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recoverPanic turns a panic in a handler into a logged 500 response and
+// closes the connection, which may be left in an unknown state.
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				w.Header().Set("Connection", "close")
+				app.serverError(w, fmt.Errorf("%s", err))
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/lincentpega/personal-crm/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestApplication() *application {
+	return &application{
+		log:            &log.Logger{ErrorLog: discardLog(), InfoLog: discardLog()},
+		sessionManager: scs.New(),
+	}
+}
+
+func discardLog() *stdlog.Logger {
+	return stdlog.New(io.Discard, "", 0)
+}
+
+func TestVerifyCSRF(t *testing.T) {
+	app := newTestApplication()
+
+	handler := app.sessionManager.LoadAndSave(app.verifyCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := app.csrfToken(r.Context())
+		require.NoError(t, err)
+		io.WriteString(w, token)
+	})))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	token := rec.Body.String()
+	cookies := rec.Result().Cookies()
+	require.NotEmpty(t, token)
+
+	post := func(form url.Values, header string) int {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			r.Header.Set(csrfHeader, header)
+		}
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, post(nil, ""))
+	assert.Equal(t, http.StatusBadRequest, post(url.Values{csrfFormField: {"forged"}}, ""))
+	assert.Equal(t, http.StatusOK, post(url.Values{csrfFormField: {token}}, ""))
+	assert.Equal(t, http.StatusOK, post(nil, token))
+}
+
+func TestSecureHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+	secureHeaders(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "script-src 'self' https://unpkg.com")
+	assert.Equal(t, "origin-when-cross-origin", rec.Header().Get("Referrer-Policy"))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "deny", rec.Header().Get("X-Frame-Options"))
+}
+
+func TestRecoverPanic(t *testing.T) {
+	app := newTestApplication()
+
+	rec := httptest.NewRecorder()
+	app.recoverPanic(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "close", rec.Header().Get("Connection"))
+}
+
+func TestLogRequestOmitsQuery(t *testing.T) {
+	app := newTestApplication()
+
+	var buf strings.Builder
+	app.log.InfoLog = stdlog.New(&buf, "", 0)
+
+	rec := httptest.NewRecorder()
+	app.logRequest(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/user/telegram/callback?state=secret&hash=abc", nil))
+
+	assert.Contains(t, buf.String(), "GET /user/telegram/callback")
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestAuthenticateTokenMalformed(t *testing.T) {
+	app := newTestApplication()
+
+	handler := app.authenticateToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, apiToken(r))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/persons", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/persons", nil)
+	r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "invalid_token")
+	assert.Contains(t, rec.Body.String(), `"invalid_token"`)
+}
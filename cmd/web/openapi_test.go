@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lincentpega/personal-crm/internal/models/notifications"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type openAPIDocument struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+			Enum       []string                   `json:"enum"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPI(t *testing.T) *openAPIDocument {
+	t.Helper()
+
+	var doc openAPIDocument
+	require.NoError(t, json.Unmarshal(openAPISpec, &doc))
+	require.True(t, strings.HasPrefix(doc.OpenAPI, "3."))
+
+	return &doc
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := loadOpenAPI(t)
+	app := newTestApplication()
+
+	registered := make(map[string]bool)
+	for _, route := range app.apiRoutes() {
+		method := strings.ToLower(route.method)
+		registered[method+" "+route.pattern] = true
+
+		_, ok := doc.Paths[route.pattern][method]
+		assert.True(t, ok, "%s %s is missing from the spec", route.method, route.pattern)
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			assert.True(t, registered[method+" "+path], "%s %s is in the spec but not registered", method, path)
+		}
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	doc := loadOpenAPI(t)
+
+	types := map[string]any{
+		"Person":       personJSON{},
+		"ContactInfo":  contactJSON{},
+		"JobInfo":      jobJSON{},
+		"Settings":     settingsJSON{},
+		"SearchResult": searchResultJSON{},
+		"Notification": notificationJSON{},
+		"Pagination":   paginationJSON{},
+	}
+
+	for name, v := range types {
+		schema, ok := doc.Components.Schemas[name]
+		if !assert.True(t, ok, "schema %s is missing", name) {
+			continue
+		}
+
+		var fields []string
+		rt := reflect.TypeOf(v)
+		for i := range rt.NumField() {
+			fields = append(fields, strings.Split(rt.Field(i).Tag.Get("json"), ",")[0])
+		}
+
+		var properties []string
+		for property := range schema.Properties {
+			properties = append(properties, property)
+		}
+
+		assert.ElementsMatch(t, fields, properties, "properties of schema %s", name)
+	}
+
+	enums := map[string][]string{
+		"ContactMethod":      stringsOf(person.ContactMethods),
+		"NotificationType":   stringsOf(notificationTypes),
+		"NotificationStatus": stringsOf(append(slices.Clone(notificationStatuses), notifications.Processing)),
+	}
+
+	for name, values := range enums {
+		assert.ElementsMatch(t, values, doc.Components.Schemas[name].Enum, "values of schema %s", name)
+	}
+}
+
+func stringsOf[S ~string](values []S) []string {
+	ss := make([]string, 0, len(values))
+	for _, v := range values {
+		ss = append(ss, string(v))
+	}
+	return ss
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	app := newTestApplication()
+
+	rec := httptest.NewRecorder()
+	app.route().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, string(openAPISpec), rec.Body.String())
+}
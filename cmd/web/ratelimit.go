@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// loginLimiter blocks a key, such as a client address or an email, after
+// too many failed logins within a window. The window starts with the first
+// failure and a successful login clears the key.
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+	max      int
+	window   time.Duration
+	now      func() time.Time
+}
+
+type loginFailures struct {
+	since time.Time
+	count int
+}
+
+func newLoginLimiter(max int, window time.Duration) *loginLimiter {
+	return &loginLimiter{
+		failures: make(map[string]*loginFailures),
+		max:      max,
+		window:   window,
+		now:      time.Now,
+	}
+}
+
+// Allow reports whether none of the keys is blocked.
+func (l *loginLimiter) Allow(keys ...string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for _, key := range keys {
+		f, ok := l.failures[key]
+		if ok && now.Sub(f.since) < l.window && f.count >= l.max {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Fail records a failed login for every key.
+func (l *loginLimiter) Fail(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, f := range l.failures {
+		if now.Sub(f.since) >= l.window {
+			delete(l.failures, key)
+		}
+	}
+
+	for _, key := range keys {
+		f, ok := l.failures[key]
+		if !ok {
+			f = &loginFailures{since: now}
+			l.failures[key] = f
+		}
+		f.count++
+	}
+}
+
+// Reset forgets the failed logins of the keys.
+func (l *loginLimiter) Reset(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		delete(l.failures, key)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	l := newLoginLimiter(3, 15*time.Minute)
+	l.now = func() time.Time { return now }
+
+	for range 3 {
+		assert.True(t, l.Allow("ip:10.0.0.1", "email:anna@example.com"))
+		l.Fail("ip:10.0.0.1", "email:anna@example.com")
+	}
+
+	assert.False(t, l.Allow("ip:10.0.0.1"))
+	assert.False(t, l.Allow("ip:10.0.0.2", "email:anna@example.com"))
+	assert.True(t, l.Allow("ip:10.0.0.2", "email:boris@example.com"))
+
+	now = now.Add(15 * time.Minute)
+	assert.True(t, l.Allow("ip:10.0.0.1", "email:anna@example.com"))
+
+	l.Fail("ip:10.0.0.1")
+	l.Fail("ip:10.0.0.1")
+	l.Reset("ip:10.0.0.1")
+	l.Fail("ip:10.0.0.1")
+	assert.True(t, l.Allow("ip:10.0.0.1"))
+}
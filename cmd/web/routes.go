@@ -12,9 +12,54 @@ func (app *application) route() http.Handler {
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	mux.Handle("/static/", http.StripPrefix("/static", fileServer))
 
-	dynamic := alice.New(app.sessionManager.LoadAndSave)
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.verifyCSRF, app.authenticate)
 
-	mux.Handle("GET /", dynamic.ThenFunc(app.home))
+	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
+	mux.Handle("GET /user/login", dynamic.ThenFunc(app.userLogin))
+	mux.Handle("POST /user/login", dynamic.ThenFunc(app.userLoginPost))
 
-	return dynamic.Then(mux)
+	protected := dynamic.Append(app.requireAuthentication)
+
+	mux.Handle("POST /user/logout", protected.ThenFunc(app.userLogoutPost))
+	mux.Handle("GET /user/telegram", protected.ThenFunc(app.userTelegram))
+	mux.Handle("GET /user/telegram/callback", protected.ThenFunc(app.userTelegramCallback))
+	mux.Handle("POST /user/telegram/link", protected.ThenFunc(app.userTelegramLinkPost))
+	mux.Handle("GET /search", protected.ThenFunc(app.search))
+	mux.Handle("GET /persons", protected.ThenFunc(app.personList))
+	mux.Handle("GET /persons/new", protected.ThenFunc(app.personCreate))
+	mux.Handle("GET /persons/export", protected.ThenFunc(app.personExport))
+	mux.Handle("GET /persons/import", protected.ThenFunc(app.personImport))
+	mux.Handle("POST /persons/import", protected.ThenFunc(app.personImportPost))
+	mux.Handle("POST /persons/import/run", protected.ThenFunc(app.personImportRunPost))
+	mux.Handle("GET /persons/duplicates", protected.ThenFunc(app.personDuplicates))
+	mux.Handle("POST /persons/merge", protected.ThenFunc(app.personMergePost))
+	mux.Handle("POST /persons/merges/{id}/undo", protected.ThenFunc(app.personMergeUndoPost))
+	mux.Handle("POST /persons", protected.ThenFunc(app.personCreatePost))
+	mux.Handle("GET /persons/{id}", protected.ThenFunc(app.personView))
+	mux.Handle("GET /persons/{id}/vcard", protected.ThenFunc(app.personVCard))
+	mux.Handle("GET /persons/{id}/edit", protected.ThenFunc(app.personEdit))
+	mux.Handle("POST /persons/{id}", protected.ThenFunc(app.personEditPost))
+	mux.Handle("GET /persons/{id}/delete", protected.ThenFunc(app.personDelete))
+	mux.Handle("POST /persons/{id}/delete", protected.ThenFunc(app.personDeletePost))
+	mux.Handle("GET /persons/{id}/interactions", protected.ThenFunc(app.personInteractions))
+
+	mux.Handle("GET /user/tokens", protected.ThenFunc(app.userTokens))
+	mux.Handle("POST /user/tokens", protected.ThenFunc(app.userTokensPost))
+	mux.Handle("POST /user/tokens/{id}/revoke", protected.ThenFunc(app.userTokenRevokePost))
+
+	mux.HandleFunc("GET /api/openapi.json", app.openAPI)
+	mux.Handle("/api/", app.api())
+
+	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders, limitRequestBody)
+
+	return standard.Then(mux)
+}
+
+// api serves the JSON API to the user of the session or of the API token
+// the request carries.
+func (app *application) api() http.Handler {
+	chain := alice.New(app.sessionManager.LoadAndSave, app.authenticateToken, app.verifyCSRF, app.authenticate,
+		app.apiRequireAuthentication)
+
+	return chain.Then(app.apiHandler())
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/validator"
+)
+
+// userTelegram shows the Telegram account linked to the user and the ways
+// to link one: the Telegram Login Widget and a code issued by the bot.
+func (app *application) userTelegram(w http.ResponseWriter, r *http.Request) {
+	data, ok := app.newTelegramData(w, r)
+	if !ok {
+		return
+	}
+	data.Form = &linkForm{}
+
+	app.render(w, http.StatusOK, "telegram.html", data)
+}
+
+// userTelegramCallback is the auth URL of the Telegram Login Widget. The
+// URL carries the CSRF token as state, so that the login data of someone
+// else's Telegram account can't be planted in a link to this page.
+func (app *application) userTelegramCallback(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	if !app.validCSRFToken(r.Context(), values.Get("state")) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	values.Del("state")
+
+	tgUser, err := app.telegramLogin.Verify(values)
+	if err != nil {
+		app.log.InfoLog.Printf("Rejected Telegram login data: %v", err)
+		app.sessionManager.Put(r.Context(), "flash", "Telegram login could not be verified, please try again")
+		http.Redirect(w, r, "/user/telegram", http.StatusSeeOther)
+		return
+	}
+
+	app.linkTelegram(w, r, tgUser.ID)
+}
+
+func (app *application) userTelegramLinkPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := &linkForm{Code: strings.TrimSpace(r.PostForm.Get("code"))}
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	data, ok := app.newTelegramData(w, r)
+	if !ok {
+		return
+	}
+	data.Form = form
+
+	if !form.Valid() {
+		app.render(w, http.StatusUnprocessableEntity, "telegram.html", data)
+		return
+	}
+
+	limiterKey := fmt.Sprintf("link:%d", data.User.ID)
+	if !app.loginLimiter.Allow(limiterKey) {
+		form.AddFieldError("code", "Too many failed attempts, try again later")
+		app.render(w, http.StatusTooManyRequests, "telegram.html", data)
+		return
+	}
+
+	telegramID, err := app.userRepo.ConsumeLinkCode(r.Context(), form.Code)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.loginLimiter.Fail(limiterKey)
+			form.AddFieldError("code", "The code is wrong or has expired, send /link to the bot for a new one")
+			app.render(w, http.StatusUnprocessableEntity, "telegram.html", data)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.loginLimiter.Reset(limiterKey)
+
+	app.linkTelegram(w, r, telegramID)
+}
+
+func (app *application) linkTelegram(w http.ResponseWriter, r *http.Request, telegramID int64) {
+	id, err := owner.ID(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.userRepo.LinkTelegram(r.Context(), id, telegramID)
+	switch {
+	case errors.Is(err, models.ErrTelegramTaken):
+		app.sessionManager.Put(r.Context(), "flash", "This Telegram account is linked to another user")
+	case err != nil:
+		app.serverError(w, err)
+		return
+	default:
+		app.sessionManager.Put(r.Context(), "flash", "Your Telegram account is linked")
+	}
+
+	http.Redirect(w, r, "/user/telegram", http.StatusSeeOther)
+}
+
+func (app *application) newTelegramData(w http.ResponseWriter, r *http.Request) (*templateData, bool) {
+	id, err := owner.ID(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+
+	user, err := app.userRepo.Get(r.Context(), id)
+	if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.BotUsername = app.botUsername
+
+	return data, true
+}
@@ -6,8 +6,102 @@ import (
 	"html/template"
 	"net/http"
 	"path/filepath"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/interactions"
+	"github.com/lincentpega/personal-crm/internal/models/merges"
+	"github.com/lincentpega/personal-crm/internal/models/notifications"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/models/tokens"
+	"github.com/lincentpega/personal-crm/internal/models/users"
 )
 
+type templateData struct {
+	Person         *person.Person
+	Persons        []person.Person
+	Interactions   []interactions.Interaction
+	Notifications  []notifications.Notification
+	Query          string
+	SearchResults  []person.SearchResult
+	Pagination     pagination
+	Form           any
+	ContactMethods []person.ContactMethod
+	User           *users.User
+	BotUsername    string
+	Tokens         []tokens.Token
+	Duplicates     []merges.Duplicate
+	Merges         []merges.Merge
+	// NewToken is the secret of a token just created, shown only once.
+	NewToken string
+	// Flash is a one-off message left in the session by the previous
+	// request, such as a confirmation after a redirect.
+	Flash           string
+	IsAuthenticated bool
+	// CSRFToken must be submitted with every form that changes state.
+	CSRFToken string
+}
+
+// pagination describes the page of a list being shown along with the
+// sorting the page links must keep.
+type pagination struct {
+	Sort       person.SortField
+	Page       int
+	TotalPages int
+	Desc       bool
+}
+
+func (p pagination) HasPrev() bool {
+	return p.Page > 1
+}
+
+func (p pagination) HasNext() bool {
+	return p.Page < p.TotalPages
+}
+
+func (p pagination) Prev() int {
+	return p.Page - 1
+}
+
+func (p pagination) Next() int {
+	return p.Page + 1
+}
+
+func (app *application) newTemplateData(r *http.Request) *templateData {
+	token, err := app.csrfToken(r.Context())
+	if err != nil {
+		app.log.ErrorLog.Printf("Failed to create a CSRF token: %v", err)
+	}
+
+	return &templateData{
+		ContactMethods:  person.ContactMethods,
+		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated: app.isAuthenticated(r),
+		CSRFToken:       token,
+	}
+}
+
+func humanDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("02 Jan 2006 at 15:04")
+}
+
+func isoDate(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
+
+// percent formats a fraction between 0 and 1 as a whole percentage.
+func percent(f float64) string {
+	return fmt.Sprintf("%.0f%%", f*100)
+}
+
+var functions = template.FuncMap{
+	"humanDate": humanDate,
+	"isoDate":   isoDate,
+	"percent":   percent,
+}
+
 func (app *application) loadTemplates() error {
 	app.templates = make(map[string]*template.Template)
 
@@ -19,7 +113,7 @@ func (app *application) loadTemplates() error {
 	for _, page := range pages {
 		name := filepath.Base(page)
 
-		ts := template.New(name)
+		ts := template.New(name).Funcs(functions)
 		ts, err := ts.ParseFiles("./ui/html/base.html")
 		if err != nil {
 			return err
@@ -41,20 +135,31 @@ func (app *application) loadTemplates() error {
 	return nil
 }
 
-func (app *application) render(w http.ResponseWriter, name string, data interface{}) {
-	ts, ok := app.templates[name]
+func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+	app.renderTemplate(w, status, page, "base", data)
+}
+
+// renderPartial renders a single named template of the page without the
+// layout, for responses to htmx requests.
+func (app *application) renderPartial(w http.ResponseWriter, status int, page, partial string, data *templateData) {
+	app.renderTemplate(w, status, page, partial, data)
+}
+
+func (app *application) renderTemplate(w http.ResponseWriter, status int, page, name string, data *templateData) {
+	ts, ok := app.templates[page]
 	if !ok {
-		app.serverError(w, fmt.Errorf("the template %s does not exist", name))
+		app.serverError(w, fmt.Errorf("the template %s does not exist", page))
 		return
 	}
 
 	buf := new(bytes.Buffer)
 
-	err := ts.ExecuteTemplate(buf, "base", data)
+	err := ts.ExecuteTemplate(buf, name, data)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	w.WriteHeader(status)
 	buf.WriteTo(w)
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/tokens"
+	"github.com/lincentpega/personal-crm/internal/validator"
+)
+
+// tokenExpiryDays are the lifetimes offered for new API tokens.
+var tokenExpiryDays = []string{"30", "90", "365"}
+
+// userTokens lists the API tokens of the user along with a form to create
+// one.
+func (app *application) userTokens(w http.ResponseWriter, r *http.Request) {
+	data, ok := app.newTokensData(w, r)
+	if !ok {
+		return
+	}
+	data.Form = &tokenForm{Scope: string(tokens.ScopeRead), ExpiresIn: tokenExpiryDays[1]}
+
+	app.render(w, http.StatusOK, "tokens.html", data)
+}
+
+// userTokensPost creates an API token and shows its secret, which is never
+// shown again.
+func (app *application) userTokensPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := &tokenForm{
+		Name:      strings.TrimSpace(r.PostForm.Get("name")),
+		Scope:     r.PostForm.Get("scope"),
+		ExpiresIn: r.PostForm.Get("expires_in"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(slices.Contains(tokens.Scopes, tokens.Scope(form.Scope)), "scope", "Choose what the token may do")
+	form.CheckField(form.ExpiresIn == "" || slices.Contains(tokenExpiryDays, form.ExpiresIn), "expires_in",
+		"Choose when the token expires")
+
+	if !form.Valid() {
+		data, ok := app.newTokensData(w, r)
+		if !ok {
+			return
+		}
+		data.Form = form
+
+		app.render(w, http.StatusUnprocessableEntity, "tokens.html", data)
+		return
+	}
+
+	var expiresAt time.Time
+	if form.ExpiresIn != "" {
+		days, _ := strconv.Atoi(form.ExpiresIn)
+		expiresAt = time.Now().AddDate(0, 0, days)
+	}
+
+	_, secret, err := app.tokenRepo.Insert(r.Context(), form.Name, tokens.Scope(form.Scope), expiresAt)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data, ok := app.newTokensData(w, r)
+	if !ok {
+		return
+	}
+	data.Form = &tokenForm{Scope: string(tokens.ScopeRead), ExpiresIn: tokenExpiryDays[1]}
+	data.NewToken = secret
+
+	app.render(w, http.StatusOK, "tokens.html", data)
+}
+
+func (app *application) userTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	if err := app.tokenRepo.Revoke(r.Context(), id); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The token has been revoked")
+
+	http.Redirect(w, r, "/user/tokens", http.StatusSeeOther)
+}
+
+func (app *application) newTokensData(w http.ResponseWriter, r *http.Request) (*templateData, bool) {
+	ts, err := app.tokenRepo.List(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+
+	data := app.newTemplateData(r)
+	data.Tokens = ts
+
+	return data, true
+}
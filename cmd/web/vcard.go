@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/lincentpega/personal-crm/internal/vcard"
+)
+
+// personVCard downloads the person as a vCard, version 3.0 unless
+// ?version=4.0 asks for the newer one.
+func (app *application) personVCard(w http.ResponseWriter, r *http.Request) {
+	p, ok := app.getPerson(w, r)
+	if !ok {
+		return
+	}
+
+	version := vcard.V3
+	switch v := vcard.Version(r.URL.Query().Get("version")); v {
+	case "", vcard.V3:
+	case vcard.V4:
+		version = v
+	default:
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := vcard.Encode(&buf, []vcard.Card{vcard.FromPerson(p, version)}); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"person-%d.vcf\"", p.ID))
+	buf.WriteTo(w)
+}
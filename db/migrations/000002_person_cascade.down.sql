@@ -0,0 +1,17 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_job_infos_person_id;
+DROP INDEX IF EXISTS idx_contact_infos_person_id;
+ALTER TABLE notifications DROP CONSTRAINT fk_notifications_persons;
+ALTER TABLE notifications ADD CONSTRAINT fk_notifications_persons
+    FOREIGN KEY (person_id) REFERENCES persons (id);
+ALTER TABLE person_settings DROP CONSTRAINT uq_person_settings_person_id;
+ALTER TABLE person_settings DROP CONSTRAINT fk_person_settings_persons;
+ALTER TABLE person_settings ADD CONSTRAINT fk_person_settings_persons
+    FOREIGN KEY (person_id) REFERENCES persons (id);
+ALTER TABLE job_infos DROP CONSTRAINT fk_job_infos_persons;
+ALTER TABLE job_infos ADD CONSTRAINT fk_job_infos_persons
+    FOREIGN KEY (person_id) REFERENCES persons (id);
+ALTER TABLE contact_infos DROP CONSTRAINT fk_contact_infos_persons;
+ALTER TABLE contact_infos ADD CONSTRAINT fk_contact_infos_persons
+    FOREIGN KEY (person_id) REFERENCES persons (id);
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+ALTER TABLE contact_infos DROP CONSTRAINT fk_contact_infos_persons;
+ALTER TABLE contact_infos ADD CONSTRAINT fk_contact_infos_persons
+    FOREIGN KEY (person_id) REFERENCES persons (id) ON DELETE CASCADE;
+ALTER TABLE job_infos DROP CONSTRAINT fk_job_infos_persons;
+ALTER TABLE job_infos ADD CONSTRAINT fk_job_infos_persons
+    FOREIGN KEY (person_id) REFERENCES persons (id) ON DELETE CASCADE;
+ALTER TABLE person_settings DROP CONSTRAINT fk_person_settings_persons;
+ALTER TABLE person_settings ADD CONSTRAINT fk_person_settings_persons
+    FOREIGN KEY (person_id) REFERENCES persons (id) ON DELETE CASCADE;
+ALTER TABLE person_settings ADD CONSTRAINT uq_person_settings_person_id UNIQUE (person_id);
+ALTER TABLE notifications DROP CONSTRAINT fk_notifications_persons;
+ALTER TABLE notifications ADD CONSTRAINT fk_notifications_persons
+    FOREIGN KEY (person_id) REFERENCES persons (id) ON DELETE CASCADE;
+CREATE INDEX idx_contact_infos_person_id ON contact_infos (person_id);
+CREATE INDEX idx_job_infos_person_id ON job_infos (person_id);
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+DROP INDEX IF EXISTS uq_contact_infos_primary;
+ALTER TABLE contact_infos DROP COLUMN is_primary;
+ALTER TABLE contact_infos ADD COLUMN method_name VARCHAR(256);
+UPDATE contact_infos SET method_name = method::text;
+ALTER TABLE contact_infos ALTER COLUMN method_name SET NOT NULL;
+ALTER TABLE contact_infos DROP COLUMN method;
+DROP TYPE IF EXISTS contact_method;
+COMMIT;
@@ -0,0 +1,34 @@
+BEGIN;
+CREATE TYPE contact_method AS ENUM ('phone', 'email', 'telegram', 'whatsapp', 'url', 'address', 'other');
+ALTER TABLE contact_infos ADD COLUMN method contact_method;
+UPDATE contact_infos SET method = (CASE
+    WHEN lower(trim(method_name)) IN ('phone', 'tel', 'mobile', 'cell', 'телефон') THEN 'phone'
+    WHEN lower(trim(method_name)) IN ('email', 'e-mail', 'mail', 'почта') THEN 'email'
+    WHEN lower(trim(method_name)) IN ('telegram', 'tg', 'телеграм') THEN 'telegram'
+    WHEN lower(trim(method_name)) IN ('whatsapp', 'wa') THEN 'whatsapp'
+    WHEN lower(trim(method_name)) IN ('url', 'link', 'site', 'website', 'web') THEN 'url'
+    WHEN lower(trim(method_name)) IN ('address', 'addr', 'адрес') THEN 'address'
+    ELSE 'other'
+END)::contact_method;
+UPDATE contact_infos SET contact_data = regexp_replace(regexp_replace(contact_data, '[^0-9+]', '', 'g'), '^00', '+')
+    WHERE method IN ('phone', 'whatsapp');
+UPDATE contact_infos SET contact_data = lower(trim(contact_data))
+    WHERE method = 'email';
+UPDATE contact_infos SET contact_data = lower(regexp_replace(trim(contact_data), '^(https?://)?(www\.)?(t\.me/|telegram\.me/)?@?', '', 'i'))
+    WHERE method = 'telegram';
+-- Keep the original free-form method name of unrecognised rows as a label,
+-- cut to the length of the column.
+UPDATE contact_infos SET contact_data = left(trim(method_name) || ': ' || contact_data, 256)
+    WHERE method = 'other' AND lower(trim(method_name)) <> 'other';
+ALTER TABLE contact_infos ALTER COLUMN method SET NOT NULL;
+ALTER TABLE contact_infos DROP COLUMN method_name;
+ALTER TABLE contact_infos ADD COLUMN is_primary BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE contact_infos c SET is_primary = TRUE
+    FROM (
+        SELECT DISTINCT ON (person_id, method) ctid AS row_id
+        FROM contact_infos
+        ORDER BY person_id, method, ctid
+    ) f
+    WHERE c.ctid = f.row_id;
+CREATE UNIQUE INDEX uq_contact_infos_primary ON contact_infos (person_id, method) WHERE is_primary;
+COMMIT;
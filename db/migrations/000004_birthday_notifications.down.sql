@@ -0,0 +1,10 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_notification_person_id_type;
+ALTER TABLE person_settings DROP CONSTRAINT chk_person_settings_birthday_notify_days_before;
+ALTER TABLE person_settings DROP COLUMN birthday_notify_days_before;
+DELETE FROM notifications WHERE type = 'birthday';
+ALTER TYPE notification_type RENAME TO notification_type_old;
+CREATE TYPE notification_type AS ENUM ('keep_in_touch');
+ALTER TABLE notifications ALTER COLUMN type TYPE notification_type USING type::text::notification_type;
+DROP TYPE notification_type_old;
+COMMIT;
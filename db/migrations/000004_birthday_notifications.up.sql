@@ -0,0 +1,7 @@
+BEGIN;
+ALTER TYPE notification_type ADD VALUE IF NOT EXISTS 'birthday';
+ALTER TABLE person_settings ADD COLUMN birthday_notify_days_before INT NOT NULL DEFAULT 0;
+ALTER TABLE person_settings ADD CONSTRAINT chk_person_settings_birthday_notify_days_before
+    CHECK (birthday_notify_days_before BETWEEN 0 AND 365);
+CREATE INDEX idx_notification_person_id_type ON public.notifications (person_id, type);
+COMMIT;
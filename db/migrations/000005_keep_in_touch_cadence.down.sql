@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE persons DROP COLUMN last_contacted_at;
+ALTER TABLE person_settings DROP COLUMN keep_in_touch_cadence;
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE person_settings ADD COLUMN keep_in_touch_cadence VARCHAR(16);
+ALTER TABLE persons ADD COLUMN last_contacted_at TIMESTAMP;
+COMMIT;
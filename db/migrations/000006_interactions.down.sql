@@ -0,0 +1,5 @@
+BEGIN;
+DROP TABLE IF EXISTS interaction_persons;
+DROP TABLE IF EXISTS interactions;
+DROP TYPE IF EXISTS interaction_kind;
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+CREATE TYPE interaction_kind AS ENUM ('call', 'meeting', 'message', 'email');
+CREATE TABLE IF NOT EXISTS public.interactions (
+    id SERIAL,
+    kind interaction_kind NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    location VARCHAR(256),
+    CONSTRAINT pk_interactions PRIMARY KEY (id)
+);
+CREATE TABLE IF NOT EXISTS public.interaction_persons (
+    interaction_id INT NOT NULL,
+    person_id INT NOT NULL,
+    CONSTRAINT pk_interaction_persons PRIMARY KEY (interaction_id, person_id),
+    CONSTRAINT fk_interaction_persons_interactions FOREIGN KEY (interaction_id) REFERENCES interactions (id) ON DELETE CASCADE,
+    CONSTRAINT fk_interaction_persons_persons FOREIGN KEY (person_id) REFERENCES persons (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_interaction_persons_person_id ON public.interaction_persons (person_id);
+CREATE INDEX idx_interactions_occurred_at ON public.interactions (occurred_at);
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_notification_status_time;
+ALTER TABLE notifications DROP COLUMN lease_expires_at;
+UPDATE notifications SET status = 'pending' WHERE status = 'processing';
+ALTER TYPE notification_status RENAME TO notification_status_old;
+CREATE TYPE notification_status AS ENUM ('pending', 'raised', 'failed');
+ALTER TABLE notifications ALTER COLUMN status TYPE notification_status USING status::text::notification_status;
+DROP TYPE notification_status_old;
+COMMIT;
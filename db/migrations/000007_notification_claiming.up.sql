@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TYPE notification_status ADD VALUE IF NOT EXISTS 'processing';
+ALTER TABLE notifications ADD COLUMN lease_expires_at TIMESTAMP;
+CREATE INDEX idx_notification_status_time ON public.notifications (status, notification_time);
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE notifications DROP COLUMN next_attempt_at;
+ALTER TABLE notifications DROP COLUMN last_error;
+ALTER TABLE notifications DROP COLUMN attempts;
+COMMIT;
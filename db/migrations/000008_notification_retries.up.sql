@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE notifications ADD COLUMN attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE notifications ADD COLUMN last_error TEXT;
+ALTER TABLE notifications ADD COLUMN next_attempt_at TIMESTAMP;
+COMMIT;
@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE notifications DROP COLUMN channels;
+COMMIT;
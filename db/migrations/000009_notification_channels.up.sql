@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE notifications ADD COLUMN channels TEXT[] NOT NULL DEFAULT '{}';
+COMMIT;
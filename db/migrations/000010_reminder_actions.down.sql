@@ -0,0 +1,7 @@
+BEGIN;
+UPDATE notifications SET status = 'raised' WHERE status IN ('acknowledged', 'snoozed', 'skipped');
+ALTER TYPE notification_status RENAME TO notification_status_old;
+CREATE TYPE notification_status AS ENUM ('pending', 'raised', 'failed', 'processing');
+ALTER TABLE notifications ALTER COLUMN status TYPE notification_status USING status::text::notification_status;
+DROP TYPE notification_status_old;
+COMMIT;
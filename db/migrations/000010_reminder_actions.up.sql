@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TYPE notification_status ADD VALUE IF NOT EXISTS 'acknowledged';
+ALTER TYPE notification_status ADD VALUE IF NOT EXISTS 'snoozed';
+ALTER TYPE notification_status ADD VALUE IF NOT EXISTS 'skipped';
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS public.conversations (
+    chat_id BIGINT NOT NULL,
+    flow VARCHAR(32) NOT NULL,
+    step VARCHAR(32) NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_conversations PRIMARY KEY (chat_id)
+);
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+DROP FUNCTION IF EXISTS public.crm_fold(TEXT);
+DROP EXTENSION IF EXISTS unaccent;
+DROP EXTENSION IF EXISTS pg_trgm;
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+-- crm_fold brings text to a lower-case, accent-free Latin form, so that
+-- "Щукина", "Shchukina" and "shchúkina" all compare equal.
+CREATE OR REPLACE FUNCTION public.crm_fold(value TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+SELECT translate(
+    replace(replace(replace(replace(replace(replace(replace(replace(replace(
+        translate(lower(public.unaccent('public.unaccent'::regdictionary, coalesce(value, ''))),
+            'АБВГДЕЁЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯ', 'абвгдеёжзийклмнопрстуфхцчшщъыьэюя'),
+        'щ', 'shch'), 'ж', 'zh'), 'ч', 'ch'), 'ш', 'sh'), 'ц', 'ts'), 'ю', 'yu'), 'я', 'ya'), 'х', 'kh'), 'ё', 'e'),
+    'абвгдезийклмнопрстуфыэъь', 'abvgdeziiklmnoprstufye')
+$$;
+COMMIT;
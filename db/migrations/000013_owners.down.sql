@@ -0,0 +1,6 @@
+BEGIN;
+ALTER TABLE interactions DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE notifications DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE persons DROP COLUMN IF EXISTS owner_id;
+DROP TABLE IF EXISTS public.users;
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS public.users (
+    id SERIAL,
+    telegram_id BIGINT,
+    name VARCHAR(256) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_users PRIMARY KEY (id),
+    CONSTRAINT uq_users_telegram_id UNIQUE (telegram_id)
+);
+-- Everything created before there were users belongs to the first user.
+-- The bot attaches its configured Telegram user to it on start.
+INSERT INTO users (id) VALUES (1);
+SELECT setval(pg_get_serial_sequence('users', 'id'), 1);
+ALTER TABLE persons ADD COLUMN owner_id INT NOT NULL DEFAULT 1;
+ALTER TABLE persons ALTER COLUMN owner_id DROP DEFAULT;
+ALTER TABLE persons ADD CONSTRAINT fk_persons_users
+    FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE;
+ALTER TABLE notifications ADD COLUMN owner_id INT NOT NULL DEFAULT 1;
+ALTER TABLE notifications ALTER COLUMN owner_id DROP DEFAULT;
+ALTER TABLE notifications ADD CONSTRAINT fk_notifications_users
+    FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE;
+ALTER TABLE interactions ADD COLUMN owner_id INT NOT NULL DEFAULT 1;
+ALTER TABLE interactions ALTER COLUMN owner_id DROP DEFAULT;
+ALTER TABLE interactions ADD CONSTRAINT fk_interactions_users
+    FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE;
+CREATE INDEX idx_persons_owner_id ON persons (owner_id);
+CREATE INDEX idx_notifications_owner_id ON notifications (owner_id);
+CREATE INDEX idx_interactions_owner_id ON interactions (owner_id);
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS email;
+COMMIT;
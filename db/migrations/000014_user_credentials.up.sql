@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE users ADD COLUMN email VARCHAR(256);
+ALTER TABLE users ADD COLUMN password_hash CHAR(60);
+ALTER TABLE users ADD CONSTRAINT uq_users_email UNIQUE (email);
+COMMIT;
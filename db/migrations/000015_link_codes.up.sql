@@ -0,0 +1,9 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS public.link_codes (
+    code_hash BYTEA,
+    telegram_id BIGINT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    CONSTRAINT pk_link_codes PRIMARY KEY (code_hash)
+);
+CREATE INDEX idx_link_codes_telegram_id ON link_codes (telegram_id);
+COMMIT;
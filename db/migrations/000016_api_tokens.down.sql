@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE IF EXISTS public.api_tokens;
+DROP TYPE IF EXISTS api_token_scope;
+COMMIT;
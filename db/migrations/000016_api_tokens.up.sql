@@ -0,0 +1,17 @@
+BEGIN;
+CREATE TYPE api_token_scope AS ENUM ('read', 'read-write');
+CREATE TABLE IF NOT EXISTS public.api_tokens (
+    id SERIAL,
+    user_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash BYTEA NOT NULL,
+    scope api_token_scope NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    CONSTRAINT pk_api_tokens PRIMARY KEY (id),
+    CONSTRAINT uq_api_tokens_token_hash UNIQUE (token_hash),
+    CONSTRAINT fk_api_tokens_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_api_tokens_user_id ON api_tokens (user_id);
+COMMIT;
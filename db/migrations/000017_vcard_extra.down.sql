@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE persons DROP COLUMN IF EXISTS vcard_extra;
+COMMIT;
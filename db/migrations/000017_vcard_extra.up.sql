@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE persons ADD COLUMN vcard_extra TEXT NOT NULL DEFAULT '';
+COMMIT;
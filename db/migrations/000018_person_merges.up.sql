@@ -0,0 +1,17 @@
+BEGIN;
+-- survivor_id has no foreign key, so that the record of a merge outlives the
+-- surviving person and undoing it can tell why it is no longer possible.
+CREATE TABLE IF NOT EXISTS public.person_merges (
+    id SERIAL,
+    owner_id INT NOT NULL,
+    survivor_id INT NOT NULL,
+    merged_id INT NOT NULL,
+    merged_name VARCHAR(513) NOT NULL,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    undone_at TIMESTAMP,
+    CONSTRAINT pk_person_merges PRIMARY KEY (id),
+    CONSTRAINT fk_person_merges_users FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_person_merges_owner_id ON person_merges (owner_id, created_at);
+COMMIT;
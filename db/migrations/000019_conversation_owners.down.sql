@@ -0,0 +1,6 @@
+BEGIN;
+DELETE FROM conversations c
+WHERE owner_id <> (SELECT MIN(owner_id) FROM conversations WHERE chat_id = c.chat_id);
+ALTER TABLE conversations DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE conversations ADD CONSTRAINT pk_conversations PRIMARY KEY (chat_id);
+COMMIT;
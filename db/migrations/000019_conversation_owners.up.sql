@@ -0,0 +1,10 @@
+BEGIN;
+-- A conversation belongs to the user having it, so that in a group chat every
+-- member has their own and can't continue someone else's.
+ALTER TABLE conversations ADD COLUMN owner_id INT NOT NULL DEFAULT 1;
+ALTER TABLE conversations ALTER COLUMN owner_id DROP DEFAULT;
+ALTER TABLE conversations ADD CONSTRAINT fk_conversations_users
+    FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE;
+ALTER TABLE conversations DROP CONSTRAINT pk_conversations;
+ALTER TABLE conversations ADD CONSTRAINT pk_conversations PRIMARY KEY (chat_id, owner_id);
+COMMIT;
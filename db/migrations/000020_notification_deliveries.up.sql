@@ -0,0 +1,5 @@
+BEGIN;
+-- Channels that already delivered a notification are skipped when it is
+-- retried after another channel failed.
+ALTER TABLE notifications ADD COLUMN delivered_channels TEXT[] NOT NULL DEFAULT '{}';
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+-- Normalized contact data can't be turned back into what it was, and the
+-- schema is unchanged, so there is nothing to undo.
+COMMIT;
@@ -0,0 +1,33 @@
+BEGIN;
+-- Contacts converted from free-form rows by 000003 were only partly
+-- normalized, and the application validates every contact of a person when
+-- saving them. Bring the rows to the canonical form of person.Normalize, and
+-- keep the ones that can't be as labelled 'other' contacts, like 000003 does
+-- for unknown methods.
+DELETE FROM contact_infos WHERE trim(regexp_replace(contact_data, '\s+', ' ', 'g')) = '';
+-- Domestic Russian numbers are the only ones written without a country code
+-- whose country is known.
+UPDATE contact_infos SET contact_data = '+7' || substr(contact_data, 2)
+    WHERE method IN ('phone', 'whatsapp') AND contact_data ~ '^8[0-9]{10}$';
+UPDATE contact_infos SET contact_data = '+' || contact_data
+    WHERE method IN ('phone', 'whatsapp') AND contact_data ~ '^7[0-9]{10}$';
+UPDATE contact_infos SET contact_data = trim(contact_data)
+    WHERE method = 'url';
+UPDATE contact_infos SET contact_data = left('https://' || contact_data, 256)
+    WHERE method = 'url' AND contact_data NOT LIKE '%://%';
+UPDATE contact_infos SET contact_data = trim(regexp_replace(contact_data, '\s+', ' ', 'g'))
+    WHERE method IN ('address', 'other');
+-- The patterns accept a subset of what the application does, so a row they
+-- reject becomes 'other' but a row they keep is always valid. Prefixes are cut
+-- to the length of the column.
+UPDATE contact_infos
+SET method = 'other', contact_data = left(method::text || ': ' || contact_data, 256), is_primary = FALSE
+WHERE NOT CASE
+    WHEN method IN ('phone', 'whatsapp') THEN contact_data ~ '^\+[1-9][0-9]{6,14}$'
+    WHEN method = 'email' THEN
+        contact_data ~ '^[a-z0-9!#$%&''*+/=?^_`{|}~-]+(\.[a-z0-9!#$%&''*+/=?^_`{|}~-]+)*@[a-z0-9-]+(\.[a-z0-9-]+)*$'
+    WHEN method = 'telegram' THEN contact_data ~ '^[a-z0-9_]{5,32}$'
+    WHEN method = 'url' THEN contact_data ~* '^https?://[a-z0-9.-]+(:[0-9]+)?([/?][a-z0-9._~:/?@!$&''()*+,;=-]*)?(#[a-z0-9._~:/?@!$&''()*+,;=-]*)?$'
+    ELSE TRUE
+END;
+COMMIT;
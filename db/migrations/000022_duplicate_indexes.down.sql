@@ -0,0 +1,4 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_contact_infos_contact_data;
+DROP INDEX IF EXISTS idx_persons_folded_name;
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+-- The expression must match the one FindDuplicates compares names by for the
+-- trigram index to be used. concat_ws is not immutable, hence the coalesce.
+CREATE INDEX idx_persons_folded_name ON persons
+    USING GIN (public.crm_fold(first_name || ' ' || coalesce(last_name, '')) gin_trgm_ops);
+CREATE INDEX idx_contact_infos_contact_data ON contact_infos (contact_data);
+COMMIT;
@@ -0,0 +1,92 @@
+package callback
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Action string
+
+const (
+	Done     Action = "d"
+	Snooze1d Action = "s1d"
+	Snooze1w Action = "s1w"
+	Skip     Action = "k"
+)
+
+var ErrInvalid = errors.New("invalid callback data")
+
+// Payload identifies what to do with which notification on behalf of which
+// Telegram user.
+type Payload struct {
+	Action         Action
+	NotificationID int
+	UserID         int64
+}
+
+// signatureSize keeps the callback data within Telegram's 64 byte limit.
+const signatureSize = 12
+
+// Signer encodes reminder actions into Telegram callback data and signs
+// them, so that a forged or tampered callback is rejected.
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// Encode returns the payload together with its signature as
+// "action.notification.user.signature".
+func (s *Signer) Encode(p Payload) string {
+	data := fmt.Sprintf("%s.%d.%d", p.Action, p.NotificationID, p.UserID)
+	return data + "." + s.sign(data)
+}
+
+func (s *Signer) Decode(data string) (Payload, error) {
+	i := strings.LastIndexByte(data, '.')
+	if i < 0 {
+		return Payload{}, ErrInvalid
+	}
+
+	body, sig := data[:i], data[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(s.sign(body))) {
+		return Payload{}, ErrInvalid
+	}
+
+	parts := strings.Split(body, ".")
+	if len(parts) != 3 {
+		return Payload{}, ErrInvalid
+	}
+
+	notificationID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Payload{}, ErrInvalid
+	}
+
+	userID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return Payload{}, ErrInvalid
+	}
+
+	action := Action(parts[0])
+	switch action {
+	case Done, Snooze1d, Snooze1w, Skip:
+	default:
+		return Payload{}, ErrInvalid
+	}
+
+	return Payload{Action: action, NotificationID: notificationID, UserID: userID}, nil
+}
+
+func (s *Signer) sign(data string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureSize])
+}
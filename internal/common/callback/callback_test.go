@@ -0,0 +1,42 @@
+package callback
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	s := NewSigner([]byte("secret"))
+
+	p := Payload{Action: Snooze1w, NotificationID: 2147483647, UserID: 9007199254740991}
+	data := s.Encode(p)
+	assert.LessOrEqual(t, len(data)+len("\freminder|"), 64, "callback data must fit Telegram limits")
+
+	decoded, err := s.Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, p, decoded)
+}
+
+func TestDecodeRejectsForgery(t *testing.T) {
+	s := NewSigner([]byte("secret"))
+	data := s.Encode(Payload{Action: Done, NotificationID: 42, UserID: 1001})
+
+	tampered := strings.Replace(data, ".42.", ".43.", 1)
+	_, err := s.Decode(tampered)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	otherUser := strings.Replace(data, ".1001.", ".1002.", 1)
+	_, err = s.Decode(otherUser)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = NewSigner([]byte("other secret")).Decode(data)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	for _, data := range []string{"", "sosat", "d.42.1001", "x.42.1001." + s.sign("x.42.1001")} {
+		_, err = s.Decode(data)
+		assert.ErrorIs(t, err, ErrInvalid, data)
+	}
+}
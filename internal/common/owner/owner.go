@@ -0,0 +1,28 @@
+package owner
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrMissing is returned by repositories when the context carries no owner.
+// Data is never read or written without one.
+var ErrMissing = errors.New("owner is missing from context")
+
+type ctxKey int
+
+const ownerKey ctxKey = 0
+
+// WithID returns a context acting on behalf of the user with the given id.
+func WithID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, ownerKey, id)
+}
+
+// ID returns the id of the user the context acts for.
+func ID(ctx context.Context) (int, error) {
+	id, ok := ctx.Value(ownerKey).(int)
+	if !ok {
+		return 0, ErrMissing
+	}
+	return id, nil
+}
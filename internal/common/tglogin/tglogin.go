@@ -0,0 +1,103 @@
+package tglogin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidHash = errors.New("telegram login data has an invalid hash")
+	ErrExpired     = errors.New("telegram login data has expired")
+	ErrMalformed   = errors.New("telegram login data is malformed")
+)
+
+// MaxAge is how long after the user authorized the login data is accepted.
+const MaxAge = 24 * time.Hour
+
+// User is a Telegram user as reported by the Telegram Login Widget.
+type User struct {
+	AuthDate  time.Time
+	FirstName string
+	LastName  string
+	Username  string
+	PhotoURL  string
+	ID        int64
+}
+
+// Verifier checks the data the Telegram Login Widget passes to the auth URL
+// as described at https://core.telegram.org/widgets/login: the hash is the
+// HMAC-SHA256 of the other fields keyed with the SHA-256 of the bot token.
+type Verifier struct {
+	key []byte
+	now func() time.Time
+}
+
+func NewVerifier(botToken string) *Verifier {
+	key := sha256.Sum256([]byte(botToken))
+	return &Verifier{key: key[:], now: time.Now}
+}
+
+func (v *Verifier) Verify(values url.Values) (*User, error) {
+	hash := values.Get("hash")
+	if hash == "" {
+		return nil, ErrMalformed
+	}
+
+	if !hmac.Equal([]byte(hash), []byte(v.sign(values))) {
+		return nil, ErrInvalidHash
+	}
+
+	id, err := strconv.ParseInt(values.Get("id"), 10, 64)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	u := &User{
+		ID:        id,
+		FirstName: values.Get("first_name"),
+		LastName:  values.Get("last_name"),
+		Username:  values.Get("username"),
+		PhotoURL:  values.Get("photo_url"),
+		AuthDate:  time.Unix(authDate, 0),
+	}
+
+	if v.now().Sub(u.AuthDate) > MaxAge {
+		return nil, ErrExpired
+	}
+
+	return u, nil
+}
+
+// sign returns the hex encoded hash of the data check string: every field
+// but the hash as key=value, sorted by key and separated by new lines.
+func (v *Verifier) sign(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if key != "hash" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + values.Get(key)
+	}
+
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,81 @@
+package tglogin
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBotToken = "123456:TEST-bot-token"
+
+// The fixtures are signed with testBotToken the way the Telegram Login
+// Widget signs them.
+var (
+	signedFull = url.Values{
+		"id":         {"419672615"},
+		"first_name": {"Pavel"},
+		"last_name":  {"Durov"},
+		"username":   {"durov"},
+		"photo_url":  {"https://t.me/i/userpic/320/durov.jpg"},
+		"auth_date":  {"1709294400"},
+		"hash":       {"7234dc33ccd676a489080930db2b6e814239d150f76cc87d80900a5725abcc02"},
+	}
+	signedMinimal = url.Values{
+		"id":         {"419672615"},
+		"first_name": {"Pavel"},
+		"auth_date":  {"1709294400"},
+		"hash":       {"12d4f1d8be7db8063f52b3ff86aa1e0ba7787f9bd9cdd6a0d8f0a2ba3cc00f56"},
+	}
+	authDate = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func newTestVerifier(now time.Time) *Verifier {
+	v := NewVerifier(testBotToken)
+	v.now = func() time.Time { return now }
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	v := newTestVerifier(authDate.Add(time.Hour))
+
+	u, err := v.Verify(signedFull)
+	require.NoError(t, err)
+	assert.Equal(t, &User{
+		ID:        419672615,
+		FirstName: "Pavel",
+		LastName:  "Durov",
+		Username:  "durov",
+		PhotoURL:  "https://t.me/i/userpic/320/durov.jpg",
+		AuthDate:  time.Unix(1709294400, 0),
+	}, u)
+
+	u, err = v.Verify(signedMinimal)
+	require.NoError(t, err)
+	assert.Equal(t, int64(419672615), u.ID)
+	assert.Empty(t, u.Username)
+}
+
+func TestVerifyRejects(t *testing.T) {
+	v := newTestVerifier(authDate.Add(time.Hour))
+
+	tampered := url.Values{}
+	for k, vs := range signedFull {
+		tampered[k] = vs
+	}
+	tampered.Set("id", "1")
+
+	_, err := v.Verify(tampered)
+	assert.ErrorIs(t, err, ErrInvalidHash)
+
+	_, err = NewVerifier("654321:other-token").Verify(signedFull)
+	assert.ErrorIs(t, err, ErrInvalidHash)
+
+	_, err = v.Verify(url.Values{"id": {"419672615"}})
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	_, err = newTestVerifier(authDate.Add(MaxAge + time.Second)).Verify(signedFull)
+	assert.ErrorIs(t, err, ErrExpired)
+}
@@ -17,3 +17,24 @@ func GetTx(ctx context.Context) (*sql.Tx, bool) {
 	tx, ok := ctx.Value(txKey).(*sql.Tx)
 	return tx, ok
 }
+
+// Run executes fn inside a transaction carried by the context passed to fn.
+// If ctx already holds a transaction, fn joins it and committing is left to
+// whoever started it.
+func Run(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	if _, ok := GetTx(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(WithTx(ctx, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -1,25 +1,99 @@
 package config
 
-import "flag"
+import (
+	"flag"
+	"strconv"
+	"strings"
+)
 
 type AppConfig struct {
-	Token  string
-	DSN    string
-	UserID int
-	Addr   string
+	Token             string
+	DSN               string
+	UserID            int
+	Addr              string
+	NotifyMaxAttempts int
+	// AllowedUserIDs are the Telegram users the bot answers to.
+	AllowedUserIDs []int64
+	// NotifyChannels are the delivery channels used by notifications that
+	// do not name their own.
+	NotifyChannels []string
+	SMTPAddr       string
+	SMTPUsername   string
+	SMTPPassword   string
+	SMTPFrom       string
+	SMTPTo         []string
+	WebhookURL     string
+	// BotUsername enables the Telegram Login Widget of the web UI.
+	BotUsername string
 }
 
+// DefaultDSN is the datasource name of the local development database.
+const DefaultDSN = "host=localhost port=5433 user=postgres password=mysecretpassword dbname=postgres sslmode=disable"
+
 func Load() *AppConfig {
 	token := flag.String("token", "empty_token", "telegram bot token")
-	dsn := flag.String("dsn", "host=localhost port=5433 user=postgres password=mysecretpassword dbname=postgres sslmode=disable", "PostgreSQL datasource name")
+	dsn := flag.String("dsn", DefaultDSN, "PostgreSQL datasource name")
 	userID := flag.Int("id", 419672615, "Telegram user id")
 	addr := flag.String("addr", ":8080", "HTTP network address")
+	notifyMaxAttempts := flag.Int("notify-max-attempts", 5, "Delivery attempts before a notification is failed")
+	notifyChannels := flag.String("notify-channels", "telegram", "Comma separated default notification channels: telegram, email, webhook")
+	smtpAddr := flag.String("smtp-addr", "", "SMTP server host:port, enables the email channel")
+	smtpUsername := flag.String("smtp-username", "", "SMTP username")
+	smtpPassword := flag.String("smtp-password", "", "SMTP password")
+	smtpFrom := flag.String("smtp-from", "", "Sender address of notification emails")
+	smtpTo := flag.String("smtp-to", "", "Comma separated recipients of notification emails")
+	webhookURL := flag.String("webhook-url", "", "URL notifications are POSTed to, enables the webhook channel")
+	botUsername := flag.String("bot-username", "", "Username of the bot, enables Telegram login in the web UI once its domain is set with BotFather")
+
+	var allowedUserIDs []int64
+	flag.Func("allowed-ids", "Comma separated Telegram user ids allowed to use the bot (default is -id)", func(s string) error {
+		ids, err := parseIDs(s)
+		allowedUserIDs = ids
+		return err
+	})
+
 	flag.Parse()
 
+	if len(allowedUserIDs) == 0 {
+		allowedUserIDs = []int64{int64(*userID)}
+	}
+
 	return &AppConfig{
-		Token:  *token,
-		DSN:    *dsn,
-		UserID: *userID,
-		Addr:   *addr,
+		Token:             *token,
+		DSN:               *dsn,
+		UserID:            *userID,
+		AllowedUserIDs:    allowedUserIDs,
+		Addr:              *addr,
+		NotifyMaxAttempts: *notifyMaxAttempts,
+		NotifyChannels:    splitList(*notifyChannels),
+		SMTPAddr:          *smtpAddr,
+		SMTPUsername:      *smtpUsername,
+		SMTPPassword:      *smtpPassword,
+		SMTPFrom:          *smtpFrom,
+		SMTPTo:            splitList(*smtpTo),
+		WebhookURL:        *webhookURL,
+		BotUsername:       *botUsername,
+	}
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseIDs(s string) ([]int64, error) {
+	var ids []int64
+	for _, item := range splitList(s) {
+		id, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
 	}
+	return ids, nil
 }
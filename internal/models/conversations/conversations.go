@@ -0,0 +1,18 @@
+package conversations
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Conversation is the state of a multi-step bot dialog of a user in a chat.
+// A user has at most one conversation per chat at a time.
+type Conversation struct {
+	UpdatedAt time.Time
+	Flow      string
+	Step      string
+	// Data is the flow-specific draft collected so far.
+	Data    json.RawMessage
+	ChatID  int64
+	OwnerID int
+}
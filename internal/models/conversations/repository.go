@@ -0,0 +1,160 @@
+package conversations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+)
+
+// ErrChanged is returned when a conversation changed since it was read, such
+// as when the same bot button is pressed twice.
+var ErrChanged = errors.New("conversation changed since it was read")
+
+type ConversationRepository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *ConversationRepository {
+	return &ConversationRepository{db: db}
+}
+
+type DB interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *ConversationRepository) getDB(ctx context.Context) DB {
+	if tx, ok := txcontext.GetTx(ctx); ok {
+		return tx
+	}
+	return r.db
+}
+
+// Get returns the conversation the owner from the context has in the chat.
+func (r *ConversationRepository) Get(ctx context.Context, chatID int64) (*Conversation, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const stmt = `SELECT chat_id, owner_id, flow, step, data, updated_at
+	FROM conversations
+	WHERE chat_id = $1 AND owner_id = $2`
+
+	var c Conversation
+	var data []byte
+
+	err = r.getDB(ctx).QueryRowContext(ctx, stmt, chatID, ownerID).Scan(&c.ChatID, &c.OwnerID, &c.Flow, &c.Step, &data, &c.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	c.Data = data
+
+	return &c, nil
+}
+
+// Save stores the conversation for the owner from the context, replacing any
+// other conversation they have in the chat.
+func (r *ConversationRepository) Save(ctx context.Context, c *Conversation) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `INSERT INTO conversations (chat_id, owner_id, flow, step, data, updated_at)
+	VALUES($1, $2, $3, $4, COALESCE($5::jsonb, '{}'), NOW())
+	ON CONFLICT (chat_id, owner_id) DO UPDATE
+	SET flow = EXCLUDED.flow, step = EXCLUDED.step, data = EXCLUDED.data, updated_at = EXCLUDED.updated_at
+	RETURNING owner_id, updated_at`
+
+	var data any
+	if len(c.Data) > 0 {
+		data = []byte(c.Data)
+	}
+
+	return r.getDB(ctx).QueryRowContext(ctx, stmt, c.ChatID, ownerID, c.Flow, c.Step, data).Scan(&c.OwnerID, &c.UpdatedAt)
+}
+
+// Update stores the next state of a conversation read earlier, unless it
+// changed or ended since: c.UpdatedAt must still be the stored one, otherwise
+// ErrChanged is returned.
+func (r *ConversationRepository) Update(ctx context.Context, c *Conversation) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `UPDATE conversations
+	SET flow = $1, step = $2, data = COALESCE($3::jsonb, '{}'), updated_at = NOW()
+	WHERE chat_id = $4 AND owner_id = $5 AND updated_at = $6
+	RETURNING updated_at`
+
+	var data any
+	if len(c.Data) > 0 {
+		data = []byte(c.Data)
+	}
+
+	var updatedAt time.Time
+	err = r.getDB(ctx).QueryRowContext(ctx, stmt, c.Flow, c.Step, data, c.ChatID, ownerID, c.UpdatedAt).Scan(&updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrChanged
+		}
+		return err
+	}
+
+	c.UpdatedAt = updatedAt
+
+	return nil
+}
+
+// End ends a conversation read earlier, unless it changed or ended since:
+// c.UpdatedAt must still be the stored one, otherwise ErrChanged is returned.
+func (r *ConversationRepository) End(ctx context.Context, c *Conversation) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `DELETE FROM conversations WHERE chat_id = $1 AND owner_id = $2 AND updated_at = $3`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, c.ChatID, ownerID, c.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrChanged
+	}
+
+	return nil
+}
+
+// Delete ends the conversation the owner from the context has in the chat.
+// Deleting a conversation that doesn't exist is not an error.
+func (r *ConversationRepository) Delete(ctx context.Context, chatID int64) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `DELETE FROM conversations WHERE chat_id = $1 AND owner_id = $2`
+
+	_, err = r.getDB(ctx).ExecContext(ctx, stmt, chatID, ownerID)
+	return err
+}
@@ -0,0 +1,137 @@
+package conversations
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/test"
+	"github.com/stretchr/testify/suite"
+)
+
+const testChatID int64 = 123456789
+
+type conversationRepoTestSuite struct {
+	test.TestSuite
+	repo *ConversationRepository
+	tx   *sql.Tx
+}
+
+func (suite *conversationRepoTestSuite) SetupSuite() {
+	suite.TestSuite.SetupSuite()
+	suite.Ctx = owner.WithID(suite.Ctx, users.DefaultID)
+
+	suite.repo = NewRepository(suite.DB)
+}
+
+func (suite *conversationRepoTestSuite) SetupTest() {
+	var err error
+	suite.tx, err = suite.DB.BeginTx(suite.Ctx, nil)
+	suite.Require().NoError(err)
+}
+
+func (suite *conversationRepoTestSuite) TearDownTest() {
+	err := suite.tx.Rollback()
+	suite.Require().NoError(err)
+}
+
+func (suite *conversationRepoTestSuite) TestSaveAndGet() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	_, err := suite.repo.Get(ctx, testChatID)
+	suite.Require().ErrorIs(err, models.ErrRecordNotFound)
+
+	c := Conversation{ChatID: testChatID, Flow: "create_person", Step: "first_name"}
+	suite.Require().NoError(suite.repo.Save(ctx, &c))
+	suite.False(c.UpdatedAt.IsZero())
+
+	got, err := suite.repo.Get(ctx, testChatID)
+	suite.Require().NoError(err)
+	suite.Equal("create_person", got.Flow)
+	suite.Equal("first_name", got.Step)
+	suite.JSONEq(`{}`, string(got.Data))
+
+	c.Step = "last_name"
+	c.Data = json.RawMessage(`{"first_name": "Anna"}`)
+	suite.Require().NoError(suite.repo.Save(ctx, &c))
+
+	got, err = suite.repo.Get(ctx, testChatID)
+	suite.Require().NoError(err)
+	suite.Equal("last_name", got.Step)
+	suite.JSONEq(`{"first_name": "Anna"}`, string(got.Data))
+}
+
+func (suite *conversationRepoTestSuite) TestDelete() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	c := Conversation{ChatID: testChatID, Flow: "create_person", Step: "first_name"}
+	suite.Require().NoError(suite.repo.Save(ctx, &c))
+
+	suite.Require().NoError(suite.repo.Delete(ctx, testChatID))
+	suite.Require().NoError(suite.repo.Delete(ctx, testChatID))
+
+	_, err := suite.repo.Get(ctx, testChatID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+}
+
+func (suite *conversationRepoTestSuite) TestUpdateAndEnd() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	c := Conversation{ChatID: testChatID, Flow: "import_contact", Step: "preview"}
+	suite.Require().NoError(suite.repo.Save(ctx, &c))
+	read := c
+
+	// NOW() doesn't move within the test transaction, so a change made in
+	// between is simulated by moving updated_at.
+	_, err := suite.tx.ExecContext(ctx, `UPDATE conversations SET updated_at = updated_at + INTERVAL '1 second' WHERE chat_id = $1`, testChatID)
+	suite.Require().NoError(err)
+
+	read.Step = "merge_target"
+	suite.ErrorIs(suite.repo.Update(ctx, &read), ErrChanged)
+	suite.ErrorIs(suite.repo.End(ctx, &read), ErrChanged)
+
+	current, err := suite.repo.Get(ctx, testChatID)
+	suite.Require().NoError(err)
+	suite.Equal("preview", current.Step)
+
+	current.Step = "merge_target"
+	suite.Require().NoError(suite.repo.Update(ctx, current))
+
+	got, err := suite.repo.Get(ctx, testChatID)
+	suite.Require().NoError(err)
+	suite.Equal("merge_target", got.Step)
+
+	suite.Require().NoError(suite.repo.End(ctx, got))
+	suite.ErrorIs(suite.repo.End(ctx, got), ErrChanged)
+}
+
+func (suite *conversationRepoTestSuite) TestOwnerIsolation() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	other, err := users.NewRepository(suite.DB).EnsureTelegramUser(ctx, 1001, "Other")
+	suite.Require().NoError(err)
+	otherCtx := owner.WithID(ctx, other.ID)
+
+	c := Conversation{ChatID: testChatID, Flow: "create_person", Step: "first_name"}
+	suite.Require().NoError(suite.repo.Save(ctx, &c))
+	suite.Equal(users.DefaultID, c.OwnerID)
+
+	_, err = suite.repo.Get(otherCtx, testChatID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	otherConversation := Conversation{ChatID: testChatID, Flow: "import_contact", Step: "preview"}
+	suite.Require().NoError(suite.repo.Save(otherCtx, &otherConversation))
+	suite.Require().NoError(suite.repo.Delete(otherCtx, testChatID))
+
+	got, err := suite.repo.Get(ctx, testChatID)
+	suite.Require().NoError(err)
+	suite.Equal("create_person", got.Flow)
+}
+
+func TestConversationRepoTestSuite(t *testing.T) {
+	suite.Run(t, new(conversationRepoTestSuite))
+}
@@ -2,4 +2,13 @@ package models
 
 import "errors"
 
-var ErrRecordNotFound = errors.New("record not found")
+var (
+	ErrRecordNotFound = errors.New("record not found")
+	// ErrInvalidCredentials is returned when a login email and password
+	// don't match a user.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrDuplicateEmail     = errors.New("duplicate email")
+	// ErrTelegramTaken is returned when a Telegram account is already
+	// linked to another web user.
+	ErrTelegramTaken = errors.New("telegram account is linked to another user")
+)
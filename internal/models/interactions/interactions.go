@@ -0,0 +1,47 @@
+package interactions
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	Call    Kind = "call"
+	Meeting Kind = "meeting"
+	Message Kind = "message"
+	Email   Kind = "email"
+)
+
+var Kinds = []Kind{Call, Meeting, Message, Email}
+
+var (
+	ErrUnknownKind = errors.New("unknown interaction kind")
+	ErrNoPersons   = errors.New("interaction has no persons")
+)
+
+func ParseKind(s string) (Kind, error) {
+	k := Kind(strings.ToLower(strings.TrimSpace(s)))
+	for _, known := range Kinds {
+		if k == known {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownKind, s)
+}
+
+// Interaction is a single logged contact, such as a call or a meeting, with
+// one or more persons.
+type Interaction struct {
+	OccurredAt time.Time
+	Location   sql.NullString
+	Note       string
+	Kind       Kind
+	PersonIDs  []int
+	OwnerID    int
+	ID         int
+}
@@ -0,0 +1,207 @@
+package interactions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+)
+
+type InteractionRepository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *InteractionRepository {
+	return &InteractionRepository{db: db}
+}
+
+type DB interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *InteractionRepository) getDB(ctx context.Context) DB {
+	if tx, ok := txcontext.GetTx(ctx); ok {
+		return tx
+	}
+	return r.db
+}
+
+// Insert stores the interaction and links it to its persons. The last
+// contact time of every linked person is moved forward to the interaction
+// time, so keep-in-touch reminders count from it. All persons must belong to
+// the owner from the context, otherwise ErrRecordNotFound is returned.
+func (r *InteractionRepository) Insert(ctx context.Context, i *Interaction) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := ParseKind(string(i.Kind)); err != nil {
+		return err
+	}
+
+	if len(i.PersonIDs) == 0 {
+		return ErrNoPersons
+	}
+
+	return txcontext.Run(ctx, r.db, func(ctx context.Context) error {
+		const insertStmt = `INSERT INTO interactions (owner_id, kind, occurred_at, note, location)
+		VALUES($1, $2, $3, $4, $5)
+		RETURNING id, owner_id`
+
+		err := r.getDB(ctx).QueryRowContext(ctx, insertStmt, ownerID, i.Kind, i.OccurredAt, i.Note, i.Location).Scan(&i.ID, &i.OwnerID)
+		if err != nil {
+			return err
+		}
+
+		const linkStmt = `INSERT INTO interaction_persons (interaction_id, person_id)
+		SELECT $1, id FROM persons
+		WHERE id = ANY($2::int[]) AND owner_id = $3`
+
+		res, err := r.getDB(ctx).ExecContext(ctx, linkStmt, i.ID, personIDsArray(i.PersonIDs), ownerID)
+		if err != nil {
+			return err
+		}
+
+		linked, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if int(linked) != countDistinct(i.PersonIDs) {
+			return models.ErrRecordNotFound
+		}
+
+		const touchStmt = `UPDATE persons
+		SET last_contacted_at = GREATEST(last_contacted_at, $1)
+		WHERE id = ANY($2::int[]) AND owner_id = $3`
+
+		_, err = r.getDB(ctx).ExecContext(ctx, touchStmt, i.OccurredAt, personIDsArray(i.PersonIDs), ownerID)
+		return err
+	})
+}
+
+func (r *InteractionRepository) Get(ctx context.Context, id int) (*Interaction, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const stmt = `SELECT i.id, i.owner_id, i.kind, i.occurred_at, i.note, i.location,
+		COALESCE(array_agg(ip.person_id ORDER BY ip.person_id) FILTER (WHERE ip.person_id IS NOT NULL), '{}')
+	FROM interactions i
+	LEFT JOIN interaction_persons ip ON ip.interaction_id = i.id
+	WHERE i.id = $1 AND i.owner_id = $2
+	GROUP BY i.id`
+
+	i, err := scanInteraction(r.getDB(ctx).QueryRowContext(ctx, stmt, id, ownerID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return i, nil
+}
+
+func (r *InteractionRepository) Delete(ctx context.Context, id int) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `DELETE FROM interactions WHERE id = $1 AND owner_id = $2`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, id, ownerID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return models.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ListByPerson returns the timeline of the person, most recent interactions
+// first.
+func (r *InteractionRepository) ListByPerson(ctx context.Context, personID, limit, offset int) ([]Interaction, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const stmt = `SELECT i.id, i.owner_id, i.kind, i.occurred_at, i.note, i.location,
+		array_agg(ip.person_id ORDER BY ip.person_id)
+	FROM interactions i
+	JOIN interaction_persons ip ON ip.interaction_id = i.id
+	WHERE i.owner_id = $4 AND i.id IN (SELECT interaction_id FROM interaction_persons WHERE person_id = $1)
+	GROUP BY i.id
+	ORDER BY i.occurred_at DESC, i.id DESC
+	LIMIT $2 OFFSET $3`
+
+	rows, err := r.getDB(ctx).QueryContext(ctx, stmt, personID, limit, offset, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var is []Interaction
+	for rows.Next() {
+		i, err := scanInteraction(rows)
+		if err != nil {
+			return nil, err
+		}
+		is = append(is, *i)
+	}
+
+	return is, rows.Err()
+}
+
+func personIDsArray(ids []int) pq.Int64Array {
+	a := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		a[i] = int64(id)
+	}
+	return a
+}
+
+func countDistinct(ids []int) int {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	return len(seen)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanInteraction(s scanner) (*Interaction, error) {
+	var i Interaction
+	var personIDs pq.Int64Array
+
+	if err := s.Scan(&i.ID, &i.OwnerID, &i.Kind, &i.OccurredAt, &i.Note, &i.Location, &personIDs); err != nil {
+		return nil, err
+	}
+
+	for _, id := range personIDs {
+		i.PersonIDs = append(i.PersonIDs, int(id))
+	}
+
+	return &i, nil
+}
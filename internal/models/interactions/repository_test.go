@@ -0,0 +1,177 @@
+package interactions
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/test"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	testNote     = "Talked about the trip to Lisbon"
+	testLocation = "Coffee Point"
+)
+
+type interactionRepoTestSuite struct {
+	test.TestSuite
+	repo       *InteractionRepository
+	personRepo *person.PersonRepository
+	tx         *sql.Tx
+}
+
+func (suite *interactionRepoTestSuite) SetupSuite() {
+	suite.TestSuite.SetupSuite()
+	suite.Ctx = owner.WithID(suite.Ctx, users.DefaultID)
+
+	suite.repo = NewRepository(suite.DB)
+	suite.personRepo = person.NewRepository(suite.DB)
+}
+
+func (suite *interactionRepoTestSuite) SetupTest() {
+	var err error
+	suite.tx, err = suite.DB.BeginTx(suite.Ctx, nil)
+	suite.Require().NoError(err)
+}
+
+func (suite *interactionRepoTestSuite) TearDownTest() {
+	err := suite.tx.Rollback()
+	suite.Require().NoError(err)
+}
+
+func (suite *interactionRepoTestSuite) TestInsertAndGet() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	p1 := suite.createTestPerson(ctx, "Anna")
+	p2 := suite.createTestPerson(ctx, "Boris")
+
+	occurredAt := time.Date(2024, time.June, 10, 18, 30, 0, 0, time.UTC)
+	interaction := Interaction{
+		Kind:       Meeting,
+		OccurredAt: occurredAt,
+		Note:       testNote,
+		Location:   sql.NullString{String: testLocation, Valid: true},
+		PersonIDs:  []int{p1.ID, p2.ID},
+	}
+	suite.Require().NoError(suite.repo.Insert(ctx, &interaction))
+	suite.Require().NotZero(interaction.ID)
+
+	inserted, err := suite.repo.Get(ctx, interaction.ID)
+	suite.Require().NoError(err)
+	suite.Equal(Meeting, inserted.Kind)
+	suite.Equal(occurredAt, inserted.OccurredAt.UTC())
+	suite.Equal(testNote, inserted.Note)
+	suite.Equal(testLocation, inserted.Location.String)
+	suite.ElementsMatch([]int{p1.ID, p2.ID}, inserted.PersonIDs)
+
+	for _, id := range []int{p1.ID, p2.ID} {
+		p, err := suite.personRepo.Get(ctx, id)
+		suite.Require().NoError(err)
+		suite.Equal(occurredAt, p.LastContactedAt.Time.UTC())
+	}
+}
+
+func (suite *interactionRepoTestSuite) TestInsertInvalid() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	p := suite.createTestPerson(ctx, "Anna")
+
+	err := suite.repo.Insert(ctx, &Interaction{Kind: "telepathy", OccurredAt: time.Now(), PersonIDs: []int{p.ID}})
+	suite.ErrorIs(err, ErrUnknownKind)
+
+	err = suite.repo.Insert(ctx, &Interaction{Kind: Call, OccurredAt: time.Now()})
+	suite.ErrorIs(err, ErrNoPersons)
+}
+
+func (suite *interactionRepoTestSuite) TestListByPerson() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	p1 := suite.createTestPerson(ctx, "Anna")
+	p2 := suite.createTestPerson(ctx, "Boris")
+
+	base := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+	older := Interaction{Kind: Call, OccurredAt: base, PersonIDs: []int{p1.ID}}
+	newer := Interaction{Kind: Message, OccurredAt: base.Add(time.Hour), PersonIDs: []int{p1.ID, p2.ID}}
+	other := Interaction{Kind: Email, OccurredAt: base.Add(2 * time.Hour), PersonIDs: []int{p2.ID}}
+	for _, i := range []*Interaction{&older, &newer, &other} {
+		suite.Require().NoError(suite.repo.Insert(ctx, i))
+	}
+
+	timeline, err := suite.repo.ListByPerson(ctx, p1.ID, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(timeline, 2)
+	suite.Equal(newer.ID, timeline[0].ID)
+	suite.ElementsMatch([]int{p1.ID, p2.ID}, timeline[0].PersonIDs)
+	suite.Equal(older.ID, timeline[1].ID)
+
+	timeline, err = suite.repo.ListByPerson(ctx, p1.ID, 1, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(timeline, 1)
+	suite.Equal(older.ID, timeline[0].ID)
+}
+
+func (suite *interactionRepoTestSuite) TestDelete() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	p := suite.createTestPerson(ctx, "Anna")
+
+	interaction := Interaction{Kind: Call, OccurredAt: time.Now().UTC(), PersonIDs: []int{p.ID}}
+	suite.Require().NoError(suite.repo.Insert(ctx, &interaction))
+
+	suite.Require().NoError(suite.repo.Delete(ctx, interaction.ID))
+
+	_, err := suite.repo.Get(ctx, interaction.ID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+	suite.ErrorIs(suite.repo.Delete(ctx, interaction.ID), models.ErrRecordNotFound)
+}
+
+func (suite *interactionRepoTestSuite) TestOwnerIsolation() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	other, err := users.NewRepository(suite.DB).EnsureTelegramUser(ctx, 1001, "Other")
+	suite.Require().NoError(err)
+	otherCtx := owner.WithID(ctx, other.ID)
+
+	p := suite.createTestPerson(ctx, "Anna")
+	otherPerson := suite.createTestPerson(otherCtx, "Boris")
+
+	interaction := Interaction{Kind: Call, OccurredAt: time.Now().UTC(), PersonIDs: []int{p.ID}}
+	suite.Require().NoError(suite.repo.Insert(ctx, &interaction))
+	suite.Equal(users.DefaultID, interaction.OwnerID)
+
+	_, err = suite.repo.Get(otherCtx, interaction.ID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	timeline, err := suite.repo.ListByPerson(otherCtx, p.ID, 10, 0)
+	suite.Require().NoError(err)
+	suite.Empty(timeline)
+
+	suite.ErrorIs(suite.repo.Delete(otherCtx, interaction.ID), models.ErrRecordNotFound)
+
+	foreign := Interaction{Kind: Call, OccurredAt: time.Now().UTC(), PersonIDs: []int{p.ID}}
+	suite.ErrorIs(suite.repo.Insert(otherCtx, &foreign), models.ErrRecordNotFound)
+
+	mixed := Interaction{Kind: Meeting, OccurredAt: time.Now().UTC(), PersonIDs: []int{p.ID, otherPerson.ID}}
+	suite.ErrorIs(suite.repo.Insert(ctx, &mixed), models.ErrRecordNotFound)
+}
+
+func (suite *interactionRepoTestSuite) createTestPerson(ctx context.Context, firstName string) *person.Person {
+	p := &person.Person{FirstName: firstName}
+
+	err := suite.personRepo.Insert(ctx, p)
+	suite.Require().NoError(err)
+	suite.Require().NotZero(p.ID)
+
+	return p
+}
+
+func TestInteractionRepoTestSuite(t *testing.T) {
+	suite.Run(t, new(interactionRepoTestSuite))
+}
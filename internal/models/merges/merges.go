@@ -0,0 +1,208 @@
+package merges
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+)
+
+var (
+	ErrSamePerson = errors.New("a person can't be merged into themselves")
+	// ErrAlreadyUndone is returned when undoing a merge a second time.
+	ErrAlreadyUndone = errors.New("merge is already undone")
+	// ErrSurvivorDeleted is returned when undoing a merge into a person who
+	// has since been deleted or merged into someone else.
+	ErrSurvivorDeleted = errors.New("the person merged into no longer exists")
+)
+
+// Merge records that one person was merged into another, the survivor.
+type Merge struct {
+	CreatedAt time.Time
+	UndoneAt  sql.NullTime
+	// MergedName is the full name of the merged person, who no longer exists
+	// unless the merge is undone.
+	MergedName string
+	ID         int
+	SurvivorID int
+	MergedID   int
+}
+
+// snapshot is what a merge changed, stored with the merge to undo it.
+type snapshot struct {
+	// Merged is the merged person as they were before the merge.
+	Merged person.Person `json:"merged"`
+	// Filled names the fields of the survivor that were empty and taken
+	// from the merged person.
+	Filled        []string             `json:"filled,omitempty"`
+	AddedContacts []person.ContactInfo `json:"added_contacts,omitempty"`
+	AddedJobs     []person.JobInfo     `json:"added_jobs,omitempty"`
+	AddedExtra    []string             `json:"added_extra,omitempty"`
+	// LastContactedAt is the last contact with the survivor before the
+	// merge.
+	LastContactedAt sql.NullTime `json:"last_contacted_at"`
+	// Notifications were moved to the survivor, and Skipped are the pending
+	// ones skipped because the survivor had the same kind pending.
+	Notifications []int `json:"notifications,omitempty"`
+	Skipped       []int `json:"skipped,omitempty"`
+	// Interactions were moved to the survivor, and Shared are the ones the
+	// survivor already took part in.
+	Interactions []int `json:"interactions,omitempty"`
+	Shared       []int `json:"shared,omitempty"`
+}
+
+const (
+	filledLastName   = "last_name"
+	filledSecondName = "second_name"
+	filledBirthDate  = "birth_date"
+)
+
+// record notes what merging changed in the survivor. Person.Merge only ever
+// fills empty fields and appends.
+func (s *snapshot) record(before, after *person.Person) {
+	if !before.LastName.Valid && after.LastName.Valid {
+		s.Filled = append(s.Filled, filledLastName)
+	}
+	if !before.SecondName.Valid && after.SecondName.Valid {
+		s.Filled = append(s.Filled, filledSecondName)
+	}
+	if !before.BirthDate.Valid && after.BirthDate.Valid {
+		s.Filled = append(s.Filled, filledBirthDate)
+	}
+
+	s.AddedContacts = after.ContactInfos[len(before.ContactInfos):]
+	s.AddedJobs = after.JobInfos[len(before.JobInfos):]
+
+	for _, line := range extraLines(after.VCardExtra) {
+		if !slices.Contains(extraLines(before.VCardExtra), line) {
+			s.AddedExtra = append(s.AddedExtra, line)
+		}
+	}
+}
+
+// revert takes out of the survivor what the merge added, leaving alone what
+// was changed since.
+func (s *snapshot) revert(p *person.Person) {
+	for _, field := range s.Filled {
+		switch field {
+		case filledLastName:
+			if p.LastName == s.Merged.LastName {
+				p.LastName = sql.NullString{}
+			}
+		case filledSecondName:
+			if p.SecondName == s.Merged.SecondName {
+				p.SecondName = sql.NullString{}
+			}
+		case filledBirthDate:
+			if p.BirthDate.Valid && p.BirthDate.Time.Equal(s.Merged.BirthDate.Time) {
+				p.BirthDate = sql.NullTime{}
+			}
+		}
+	}
+
+	p.ContactInfos = slices.DeleteFunc(p.ContactInfos, func(c person.ContactInfo) bool {
+		return slices.ContainsFunc(s.AddedContacts, func(added person.ContactInfo) bool {
+			return added.Method == c.Method && added.Data == c.Data
+		})
+	})
+
+	p.JobInfos = slices.DeleteFunc(p.JobInfos, func(j person.JobInfo) bool {
+		return slices.ContainsFunc(s.AddedJobs, func(added person.JobInfo) bool {
+			return added.Company == j.Company && added.Position == j.Position
+		})
+	})
+
+	lines := slices.DeleteFunc(extraLines(p.VCardExtra), func(line string) bool {
+		return slices.Contains(s.AddedExtra, line)
+	})
+	p.VCardExtra = strings.Join(lines, "\n")
+}
+
+func extraLines(extra string) []string {
+	if extra == "" {
+		return nil
+	}
+	return strings.Split(extra, "\n")
+}
+
+// Duplicate is a pair of persons who are likely the same.
+type Duplicate struct {
+	A, B person.Person
+	// Score is between 0 and 1, higher meaning more likely.
+	Score float64
+	// Reasons explain the score, such as "same phone +79161234567".
+	Reasons []string
+}
+
+// The weights of the signals a duplicate is scored by. A pair is reported
+// from MinScore: matching names alone or a shared phone, email or Telegram
+// handle with somewhat similar names are enough, while different birth
+// dates speak against it.
+const (
+	MinScore               = 0.5
+	nameWeight             = 0.75
+	phoneWeight            = 0.45
+	emailWeight            = 0.45
+	telegramWeight         = 0.5
+	birthDateWeight        = 0.2
+	birthDateMismatchScore = -0.4
+)
+
+// minNameSimilarity is the least similar names can be for a pair to reach
+// MinScore without sharing contacts.
+const minNameSimilarity = (MinScore - birthDateWeight) / nameWeight
+
+// Score rates how likely a and b are the same person given the similarity
+// of their names, between 0 and 1.
+func Score(a, b *person.Person, nameSimilarity float64) (float64, []string) {
+	score := nameSimilarity * nameWeight
+
+	var reasons []string
+	if nameSimilarity >= minNameSimilarity {
+		reasons = append(reasons, fmt.Sprintf("names are %.0f%% similar", nameSimilarity*100))
+	}
+
+	shared := make(map[person.ContactMethod]bool)
+	for _, c := range a.ContactInfos {
+		method := c.Method
+		if method == person.WhatsApp {
+			method = person.Phone
+		}
+
+		var weight float64
+		switch method {
+		case person.Phone:
+			weight = phoneWeight
+		case person.Email:
+			weight = emailWeight
+		case person.Telegram:
+			weight = telegramWeight
+		default:
+			continue
+		}
+
+		if shared[method] || !b.SharesContact(c) {
+			continue
+		}
+		shared[method] = true
+
+		score += weight
+		reasons = append(reasons, fmt.Sprintf("same %s %s", method, c.Data))
+	}
+
+	if a.BirthDate.Valid && b.BirthDate.Valid {
+		if a.BirthDate.Time.Equal(b.BirthDate.Time) {
+			score += birthDateWeight
+			reasons = append(reasons, "same birth date")
+		} else {
+			score += birthDateMismatchScore
+			reasons = append(reasons, "different birth dates")
+		}
+	}
+
+	return min(max(score, 0), 1), reasons
+}
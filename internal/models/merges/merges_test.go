@@ -0,0 +1,117 @@
+package merges
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/stretchr/testify/assert"
+)
+
+func birthDate(year int, month time.Month, day int) sql.NullTime {
+	return sql.NullTime{Time: time.Date(year, month, day, 0, 0, 0, 0, time.UTC), Valid: true}
+}
+
+func TestScore(t *testing.T) {
+	anna := &person.Person{
+		FirstName: "Anna",
+		BirthDate: birthDate(1990, time.May, 17),
+		ContactInfos: []person.ContactInfo{
+			{Method: person.Phone, Data: "+79161234567"},
+			{Method: person.Phone, Data: "+79990000000"},
+			{Method: person.Email, Data: "anna@example.com"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		other      *person.Person
+		similarity float64
+		score      float64
+		reasons    []string
+	}{
+		{
+			name:       "same name",
+			other:      &person.Person{FirstName: "Anna"},
+			similarity: 1,
+			score:      0.75,
+			reasons:    []string{"names are 100% similar"},
+		},
+		{
+			name:       "shared phones count once",
+			other:      &person.Person{ContactInfos: []person.ContactInfo{{Method: person.WhatsApp, Data: "+79161234567"}, {Method: person.Phone, Data: "+79990000000"}}},
+			similarity: 0.2,
+			score:      0.6,
+			reasons:    []string{"same phone +79161234567"},
+		},
+		{
+			name:       "same birth date",
+			other:      &person.Person{BirthDate: birthDate(1990, time.May, 17), ContactInfos: []person.ContactInfo{{Method: person.Email, Data: "anna@example.com"}}},
+			similarity: 0.5,
+			score:      1,
+			reasons:    []string{"names are 50% similar", "same email anna@example.com", "same birth date"},
+		},
+		{
+			name:       "different birth dates",
+			other:      &person.Person{BirthDate: birthDate(1985, time.May, 17)},
+			similarity: 1,
+			score:      0.35,
+			reasons:    []string{"names are 100% similar", "different birth dates"},
+		},
+		{
+			name:       "unrelated",
+			other:      &person.Person{BirthDate: birthDate(1985, time.May, 17)},
+			similarity: 0.1,
+			score:      0,
+			reasons:    []string{"different birth dates"},
+		},
+	}
+
+	for _, tt := range tests {
+		score, reasons := Score(anna, tt.other, tt.similarity)
+		assert.InDelta(t, tt.score, score, 1e-9, tt.name)
+		assert.Equal(t, tt.reasons, reasons, tt.name)
+	}
+}
+
+func TestSnapshotRevert(t *testing.T) {
+	survivor := &person.Person{
+		FirstName:    "Anna",
+		ContactInfos: []person.ContactInfo{{Method: person.Phone, Data: "+79161234567", Primary: true}},
+		VCardExtra:   "NICKNAME:Annie",
+	}
+	merged := &person.Person{
+		FirstName:    "Anna",
+		LastName:     sql.NullString{String: "Shchukina", Valid: true},
+		BirthDate:    birthDate(1990, time.May, 17),
+		ContactInfos: []person.ContactInfo{{Method: person.Email, Data: "anna@example.com", Primary: true}},
+		JobInfos:     []person.JobInfo{{Company: "Acme", Current: true}},
+		VCardExtra:   "NOTE:Met in Kazan",
+	}
+
+	s := &snapshot{Merged: *merged}
+	before := *survivor
+	survivor.Merge(merged)
+	s.record(&before, survivor)
+
+	assert.Equal(t, []string{filledLastName, filledBirthDate}, s.Filled)
+	assert.Equal(t, merged.ContactInfos, s.AddedContacts)
+	assert.Equal(t, merged.JobInfos, s.AddedJobs)
+	assert.Equal(t, []string{"NOTE:Met in Kazan"}, s.AddedExtra)
+
+	// A birth date corrected after the merge stays.
+	survivor.BirthDate = birthDate(1991, time.May, 17)
+	survivor.ContactInfos = append(survivor.ContactInfos, person.ContactInfo{Method: person.Telegram, Data: "anna_sh"})
+
+	s.revert(survivor)
+
+	assert.False(t, survivor.LastName.Valid)
+	assert.Equal(t, birthDate(1991, time.May, 17), survivor.BirthDate)
+	assert.Equal(t, []person.ContactInfo{
+		{Method: person.Phone, Data: "+79161234567", Primary: true},
+		{Method: person.Telegram, Data: "anna_sh"},
+	}, survivor.ContactInfos)
+	assert.Empty(t, survivor.JobInfos)
+	assert.Equal(t, "NICKNAME:Annie", survivor.VCardExtra)
+}
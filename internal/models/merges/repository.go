@@ -0,0 +1,478 @@
+package merges
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+
+	"github.com/lib/pq"
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+)
+
+type MergeRepository struct {
+	db      *sql.DB
+	persons *person.PersonRepository
+}
+
+func NewRepository(db *sql.DB, persons *person.PersonRepository) *MergeRepository {
+	return &MergeRepository{db: db, persons: persons}
+}
+
+type DB interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *MergeRepository) getDB(ctx context.Context) DB {
+	if tx, ok := txcontext.GetTx(ctx); ok {
+		return tx
+	}
+	return r.db
+}
+
+// FindDuplicates returns the pairs of persons scoring at least MinScore,
+// most likely duplicates first. Candidates are pairs with similar names or a
+// shared phone, email or Telegram handle.
+func (r *MergeRepository) FindDuplicates(ctx context.Context, limit int) ([]Duplicate, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type pair struct {
+		a, b       int
+		similarity float64
+	}
+
+	var pairs []pair
+	err = txcontext.Run(ctx, r.db, func(ctx context.Context) error {
+		// % compares names against the threshold set here, which lets it use
+		// the trigram index on the folded name. The expression must stay the
+		// one the index is built on.
+		const thresholdStmt = `SELECT set_config('pg_trgm.similarity_threshold', $1, true)`
+
+		threshold := strconv.FormatFloat(minNameSimilarity, 'f', -1, 64)
+		if _, err := r.getDB(ctx).ExecContext(ctx, thresholdStmt, threshold); err != nil {
+			return err
+		}
+
+		const stmt = `WITH candidates AS (
+                SELECT a.id AS a_id, b.id AS b_id
+                FROM persons a
+                JOIN persons b ON b.owner_id = a.owner_id AND b.id > a.id
+                    AND crm_fold(b.first_name || ' ' || coalesce(b.last_name, '')) % crm_fold(a.first_name || ' ' || coalesce(a.last_name, ''))
+                WHERE a.owner_id = $1
+                UNION
+                SELECT a.id, b.id
+                FROM persons a
+                JOIN contact_infos ca ON ca.person_id = a.id
+                JOIN contact_infos cb ON cb.contact_data = ca.contact_data AND cb.person_id > ca.person_id
+                    AND (cb.method = ca.method OR cb.method IN ('phone', 'whatsapp') AND ca.method IN ('phone', 'whatsapp'))
+                JOIN persons b ON b.id = cb.person_id AND b.owner_id = a.owner_id
+                WHERE a.owner_id = $1 AND ca.method IN ('phone', 'whatsapp', 'email', 'telegram')
+            )
+            SELECT a.id, b.id,
+                similarity(crm_fold(a.first_name || ' ' || coalesce(a.last_name, '')), crm_fold(b.first_name || ' ' || coalesce(b.last_name, '')))
+            FROM candidates c
+            JOIN persons a ON a.id = c.a_id
+            JOIN persons b ON b.id = c.b_id
+            ORDER BY a.id, b.id`
+
+		rows, err := r.getDB(ctx).QueryContext(ctx, stmt, ownerID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var p pair
+			if err := rows.Scan(&p.a, &p.b, &p.similarity); err != nil {
+				return err
+			}
+			pairs = append(pairs, p)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, pair := range pairs {
+		ids = append(ids, pair.a, pair.b)
+	}
+	slices.Sort(ids)
+
+	candidates, err := r.persons.GetMany(ctx, slices.Compact(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	persons := make(map[int]*person.Person, len(candidates))
+	for i := range candidates {
+		persons[candidates[i].ID] = &candidates[i]
+	}
+
+	var duplicates []Duplicate
+	for _, pair := range pairs {
+		a, b := persons[pair.a], persons[pair.b]
+		if a == nil || b == nil {
+			continue
+		}
+
+		score, reasons := Score(a, b, pair.similarity)
+		if score < MinScore {
+			continue
+		}
+
+		duplicates = append(duplicates, Duplicate{A: *a, B: *b, Score: score, Reasons: reasons})
+	}
+
+	slices.SortStableFunc(duplicates, func(x, y Duplicate) int {
+		return cmp.Compare(y.Score, x.Score)
+	})
+
+	if limit > 0 && len(duplicates) > limit {
+		duplicates = duplicates[:limit]
+	}
+
+	return duplicates, nil
+}
+
+// Merge merges one person into the survivor: the survivor takes the names
+// and birth date they are missing, the contacts and jobs they don't have
+// yet, and all notifications and interactions of the merged person, who is
+// then deleted. What changed is recorded so the merge can be undone.
+func (r *MergeRepository) Merge(ctx context.Context, survivorID, mergedID int) (*Merge, error) {
+	if survivorID == mergedID {
+		return nil, ErrSamePerson
+	}
+
+	m := &Merge{SurvivorID: survivorID, MergedID: mergedID}
+	err := txcontext.Run(ctx, r.db, func(ctx context.Context) error {
+		survivor, err := r.persons.Get(ctx, survivorID)
+		if err != nil {
+			return err
+		}
+
+		merged, err := r.persons.Get(ctx, mergedID)
+		if err != nil {
+			return err
+		}
+
+		s := &snapshot{Merged: *merged, LastContactedAt: survivor.LastContactedAt}
+
+		before := *survivor
+		survivor.Merge(merged)
+		s.record(&before, survivor)
+
+		if err := r.persons.Update(ctx, survivor); err != nil {
+			return err
+		}
+
+		if merged.LastContactedAt.Valid {
+			if err := r.persons.TouchLastContacted(ctx, survivorID, merged.LastContactedAt.Time); err != nil {
+				return err
+			}
+		}
+
+		if s.Skipped, err = r.skipDuplicateNotifications(ctx, survivorID, mergedID); err != nil {
+			return err
+		}
+
+		if s.Notifications, err = r.moveNotifications(ctx, mergedID, survivorID); err != nil {
+			return err
+		}
+
+		if s.Shared, s.Interactions, err = r.moveInteractions(ctx, mergedID, survivorID); err != nil {
+			return err
+		}
+
+		if err := r.persons.Delete(ctx, mergedID); err != nil {
+			return err
+		}
+
+		m.MergedName = merged.FullName()
+		return r.insert(ctx, m, s)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Undo brings the merged person back with their notifications and
+// interactions and takes out of the survivor what the merge added. Changes
+// made to the survivor since are kept.
+func (r *MergeRepository) Undo(ctx context.Context, id int) (*Merge, error) {
+	var m *Merge
+	err := txcontext.Run(ctx, r.db, func(ctx context.Context) error {
+		var (
+			s   *snapshot
+			err error
+		)
+		m, s, err = r.get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if m.UndoneAt.Valid {
+			return ErrAlreadyUndone
+		}
+
+		survivor, err := r.persons.Get(ctx, m.SurvivorID)
+		if err != nil {
+			if errors.Is(err, models.ErrRecordNotFound) {
+				return ErrSurvivorDeleted
+			}
+			return err
+		}
+
+		merged := s.Merged
+		if err := r.persons.Restore(ctx, &merged); err != nil {
+			return err
+		}
+
+		s.revert(survivor)
+		if err := r.persons.Update(ctx, survivor); err != nil {
+			return err
+		}
+
+		if err := r.restoreLastContacted(ctx, survivor, s); err != nil {
+			return err
+		}
+
+		if err := r.restoreNotifications(ctx, m, s.Notifications); err != nil {
+			return err
+		}
+
+		if err := r.unskipNotifications(ctx, s.Skipped); err != nil {
+			return err
+		}
+
+		if err := r.restoreInteractions(ctx, m, s); err != nil {
+			return err
+		}
+
+		return r.markUndone(ctx, m)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// List returns the latest merges, undone ones included.
+func (r *MergeRepository) List(ctx context.Context, limit int) ([]Merge, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const stmt = `SELECT id, survivor_id, merged_id, merged_name, created_at, undone_at
+        FROM person_merges
+        WHERE owner_id = $1
+        ORDER BY created_at DESC, id DESC
+        LIMIT $2`
+
+	rows, err := r.getDB(ctx).QueryContext(ctx, stmt, ownerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var merges []Merge
+	for rows.Next() {
+		var m Merge
+		if err := rows.Scan(&m.ID, &m.SurvivorID, &m.MergedID, &m.MergedName, &m.CreatedAt, &m.UndoneAt); err != nil {
+			return nil, err
+		}
+		merges = append(merges, m)
+	}
+
+	return merges, rows.Err()
+}
+
+func (r *MergeRepository) get(ctx context.Context, id int) (*Merge, *snapshot, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	const stmt = `SELECT id, survivor_id, merged_id, merged_name, created_at, undone_at, snapshot
+        FROM person_merges
+        WHERE id = $1 AND owner_id = $2
+        FOR UPDATE`
+
+	var (
+		m    Merge
+		data []byte
+	)
+	err = r.getDB(ctx).QueryRowContext(ctx, stmt, id, ownerID).Scan(&m.ID, &m.SurvivorID, &m.MergedID, &m.MergedName,
+		&m.CreatedAt, &m.UndoneAt, &data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, models.ErrRecordNotFound
+		}
+		return nil, nil, err
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, nil, err
+	}
+
+	return &m, &s, nil
+}
+
+func (r *MergeRepository) insert(ctx context.Context, m *Merge, s *snapshot) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `INSERT INTO person_merges (owner_id, survivor_id, merged_id, merged_name, snapshot)
+        VALUES($1, $2, $3, $4, $5) RETURNING id, created_at`
+
+	return r.getDB(ctx).QueryRowContext(ctx, stmt, ownerID, m.SurvivorID, m.MergedID, m.MergedName, data).Scan(&m.ID, &m.CreatedAt)
+}
+
+func (r *MergeRepository) markUndone(ctx context.Context, m *Merge) error {
+	const stmt = `UPDATE person_merges SET undone_at = NOW() WHERE id = $1 RETURNING undone_at`
+
+	return r.getDB(ctx).QueryRowContext(ctx, stmt, m.ID).Scan(&m.UndoneAt)
+}
+
+// skipDuplicateNotifications skips the pending notifications of the merged
+// person of a type the survivor has pending as well, so that they aren't
+// reminded of twice.
+func (r *MergeRepository) skipDuplicateNotifications(ctx context.Context, survivorID, mergedID int) ([]int, error) {
+	const stmt = `UPDATE notifications n
+        SET status = 'skipped'
+        WHERE n.person_id = $1 AND n.status = 'pending'
+            AND EXISTS (
+                SELECT 1 FROM notifications s
+                WHERE s.person_id = $2 AND s.type = n.type AND s.status = 'pending'
+            )
+        RETURNING n.id`
+
+	return r.queryIDs(ctx, stmt, mergedID, survivorID)
+}
+
+func (r *MergeRepository) unskipNotifications(ctx context.Context, ids []int) error {
+	const stmt = `UPDATE notifications SET status = 'pending' WHERE id = ANY($1) AND status = 'skipped'`
+
+	_, err := r.getDB(ctx).ExecContext(ctx, stmt, int64Array(ids))
+	return err
+}
+
+func (r *MergeRepository) moveNotifications(ctx context.Context, mergedID, survivorID int) ([]int, error) {
+	const stmt = `UPDATE notifications SET person_id = $2 WHERE person_id = $1 RETURNING id`
+
+	return r.queryIDs(ctx, stmt, mergedID, survivorID)
+}
+
+// restoreNotifications gives the merged person back the notifications moved
+// to the survivor.
+func (r *MergeRepository) restoreNotifications(ctx context.Context, m *Merge, ids []int) error {
+	const stmt = `UPDATE notifications SET person_id = $2 WHERE person_id = $1 AND id = ANY($3)`
+
+	_, err := r.getDB(ctx).ExecContext(ctx, stmt, m.SurvivorID, m.MergedID, int64Array(ids))
+	return err
+}
+
+// moveInteractions moves the interactions of the merged person to the
+// survivor. The ones the survivor already took part in are shared and only
+// lose the merged person.
+func (r *MergeRepository) moveInteractions(ctx context.Context, mergedID, survivorID int) (shared, moved []int, err error) {
+	const sharedStmt = `DELETE FROM interaction_persons m
+        USING interaction_persons s
+        WHERE m.person_id = $1 AND s.person_id = $2 AND s.interaction_id = m.interaction_id
+        RETURNING m.interaction_id`
+
+	if shared, err = r.queryIDs(ctx, sharedStmt, mergedID, survivorID); err != nil {
+		return nil, nil, err
+	}
+
+	const moveStmt = `UPDATE interaction_persons SET person_id = $2 WHERE person_id = $1 RETURNING interaction_id`
+
+	if moved, err = r.queryIDs(ctx, moveStmt, mergedID, survivorID); err != nil {
+		return nil, nil, err
+	}
+
+	return shared, moved, nil
+}
+
+func (r *MergeRepository) restoreInteractions(ctx context.Context, m *Merge, s *snapshot) error {
+	const moveStmt = `UPDATE interaction_persons
+        SET person_id = $2
+        WHERE person_id = $1 AND interaction_id = ANY($3)`
+
+	if _, err := r.getDB(ctx).ExecContext(ctx, moveStmt, m.SurvivorID, m.MergedID, int64Array(s.Interactions)); err != nil {
+		return err
+	}
+
+	const sharedStmt = `INSERT INTO interaction_persons (interaction_id, person_id)
+        SELECT id, $1 FROM interactions WHERE id = ANY($2)
+        ON CONFLICT DO NOTHING`
+
+	_, err := r.getDB(ctx).ExecContext(ctx, sharedStmt, m.MergedID, int64Array(s.Shared))
+	return err
+}
+
+// restoreLastContacted sets the last contact with the survivor back to what
+// it was before the merge, unless there has been a contact since.
+func (r *MergeRepository) restoreLastContacted(ctx context.Context, survivor *person.Person, s *snapshot) error {
+	if !s.Merged.LastContactedAt.Valid || !survivor.LastContactedAt.Valid ||
+		!survivor.LastContactedAt.Time.Equal(s.Merged.LastContactedAt.Time) {
+		return nil
+	}
+
+	const stmt = `UPDATE persons SET last_contacted_at = $1 WHERE id = $2`
+
+	_, err := r.getDB(ctx).ExecContext(ctx, stmt, s.LastContactedAt, survivor.ID)
+	return err
+}
+
+func (r *MergeRepository) queryIDs(ctx context.Context, stmt string, args ...any) ([]int, error) {
+	rows, err := r.getDB(ctx).QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func int64Array(ids []int) pq.Int64Array {
+	a := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		a[i] = int64(id)
+	}
+	return a
+}
@@ -0,0 +1,273 @@
+package merges
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/interactions"
+	"github.com/lincentpega/personal-crm/internal/models/notifications"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/test"
+	"github.com/stretchr/testify/suite"
+)
+
+type mergeRepoTestSuite struct {
+	test.TestSuite
+	repo            *MergeRepository
+	personRepo      *person.PersonRepository
+	notifRepo       *notifications.NotificationRepository
+	interactionRepo *interactions.InteractionRepository
+	tx              *sql.Tx
+}
+
+func (suite *mergeRepoTestSuite) SetupSuite() {
+	suite.TestSuite.SetupSuite()
+	suite.Ctx = owner.WithID(suite.Ctx, users.DefaultID)
+
+	suite.personRepo = person.NewRepository(suite.DB)
+	suite.notifRepo = notifications.NewRepository(suite.DB)
+	suite.interactionRepo = interactions.NewRepository(suite.DB)
+	suite.repo = NewRepository(suite.DB, suite.personRepo)
+}
+
+func (suite *mergeRepoTestSuite) SetupTest() {
+	var err error
+	suite.tx, err = suite.DB.BeginTx(suite.Ctx, nil)
+	suite.Require().NoError(err)
+}
+
+func (suite *mergeRepoTestSuite) TearDownTest() {
+	err := suite.tx.Rollback()
+	suite.Require().NoError(err)
+}
+
+func (suite *mergeRepoTestSuite) TestFindDuplicates() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	anna := suite.createTestPerson(ctx, &person.Person{
+		FirstName:    "Anna",
+		LastName:     sql.NullString{String: "Shchukina", Valid: true},
+		ContactInfos: []person.ContactInfo{{Method: person.Phone, Data: "+79161234567", Primary: true}},
+	})
+	annaCyrillic := suite.createTestPerson(ctx, &person.Person{
+		FirstName: "Анна",
+		LastName:  sql.NullString{String: "Щукина", Valid: true},
+	})
+	anya := suite.createTestPerson(ctx, &person.Person{
+		FirstName:    "Anya",
+		ContactInfos: []person.ContactInfo{{Method: person.WhatsApp, Data: "+79161234567", Primary: true}},
+	})
+	suite.createTestPerson(ctx, &person.Person{FirstName: "Boris"})
+
+	duplicates, err := suite.repo.FindDuplicates(ctx, 10)
+	suite.Require().NoError(err)
+
+	pairs := make(map[[2]int]bool)
+	for _, d := range duplicates {
+		suite.GreaterOrEqual(d.Score, MinScore)
+		suite.NotEmpty(d.Reasons)
+		pairs[[2]int{d.A.ID, d.B.ID}] = true
+	}
+
+	suite.Len(duplicates, 2)
+	suite.True(pairs[[2]int{anna.ID, annaCyrillic.ID}])
+	suite.True(pairs[[2]int{anna.ID, anya.ID}])
+
+	otherCtx := owner.WithID(ctx, suite.createTestUser(ctx))
+	duplicates, err = suite.repo.FindDuplicates(otherCtx, 10)
+	suite.Require().NoError(err)
+	suite.Empty(duplicates)
+}
+
+func (suite *mergeRepoTestSuite) TestMergeAndUndo() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	survivor := suite.createTestPerson(ctx, &person.Person{
+		FirstName: "Anna",
+		ContactInfos: []person.ContactInfo{
+			{Method: person.Phone, Data: "+79161234567", Primary: true},
+		},
+	})
+	merged := suite.createTestPerson(ctx, &person.Person{
+		FirstName: "Anna",
+		LastName:  sql.NullString{String: "Shchukina", Valid: true},
+		ContactInfos: []person.ContactInfo{
+			{Method: person.Phone, Data: "+79161234567", Primary: true},
+			{Method: person.Email, Data: "anna@example.com", Primary: true},
+		},
+		JobInfos: []person.JobInfo{{Company: "Acme", Position: "CEO", Current: true}},
+		Settings: person.Settings{BirthdayNotify: true},
+	})
+	other := suite.createTestPerson(ctx, &person.Person{FirstName: "Boris"})
+
+	at := time.Date(2024, time.June, 10, 18, 30, 0, 0, time.UTC)
+	suite.Require().NoError(suite.personRepo.TouchLastContacted(ctx, merged.ID, at))
+
+	survivorReminder := suite.createTestNotification(ctx, survivor.ID)
+	mergedReminder := suite.createTestNotification(ctx, merged.ID)
+	mergedBirthday := &notifications.Notification{PersonID: merged.ID, Type: notifications.Birthday, Status: notifications.Raised,
+		NotificationTime: at}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, mergedBirthday))
+
+	shared := suite.createTestInteraction(ctx, survivor.ID, merged.ID)
+	moved := suite.createTestInteraction(ctx, merged.ID, other.ID)
+
+	_, err := suite.repo.Merge(ctx, survivor.ID, survivor.ID)
+	suite.ErrorIs(err, ErrSamePerson)
+
+	m, err := suite.repo.Merge(ctx, survivor.ID, merged.ID)
+	suite.Require().NoError(err)
+	suite.NotZero(m.ID)
+	suite.Equal("Anna Shchukina", m.MergedName)
+
+	_, err = suite.personRepo.Get(ctx, merged.ID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	got, err := suite.personRepo.Get(ctx, survivor.ID)
+	suite.Require().NoError(err)
+	suite.Equal("Shchukina", got.LastName.String)
+	suite.ElementsMatch([]person.ContactInfo{
+		{Method: person.Phone, Data: "+79161234567", Primary: true},
+		{Method: person.Email, Data: "anna@example.com", Primary: true},
+	}, got.ContactInfos)
+	suite.Equal(merged.JobInfos, got.JobInfos)
+	suite.True(got.LastContactedAt.Time.Equal(at))
+
+	n, err := suite.notifRepo.Get(ctx, mergedReminder.ID)
+	suite.Require().NoError(err)
+	suite.Equal(survivor.ID, n.PersonID)
+	suite.Equal(notifications.Skipped, n.Status)
+
+	n, err = suite.notifRepo.Get(ctx, survivorReminder.ID)
+	suite.Require().NoError(err)
+	suite.Equal(notifications.Pending, n.Status)
+
+	n, err = suite.notifRepo.Get(ctx, mergedBirthday.ID)
+	suite.Require().NoError(err)
+	suite.Equal(survivor.ID, n.PersonID)
+
+	i, err := suite.interactionRepo.Get(ctx, shared.ID)
+	suite.Require().NoError(err)
+	suite.Equal([]int{survivor.ID}, i.PersonIDs)
+
+	i, err = suite.interactionRepo.Get(ctx, moved.ID)
+	suite.Require().NoError(err)
+	suite.ElementsMatch([]int{survivor.ID, other.ID}, i.PersonIDs)
+
+	merges, err := suite.repo.List(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(merges, 1)
+	suite.Equal(m.ID, merges[0].ID)
+	suite.False(merges[0].UndoneAt.Valid)
+
+	undone, err := suite.repo.Undo(ctx, m.ID)
+	suite.Require().NoError(err)
+	suite.True(undone.UndoneAt.Valid)
+
+	_, err = suite.repo.Undo(ctx, m.ID)
+	suite.ErrorIs(err, ErrAlreadyUndone)
+
+	restored, err := suite.personRepo.Get(ctx, merged.ID)
+	suite.Require().NoError(err)
+	suite.Equal(merged.FullName(), restored.FullName())
+	suite.ElementsMatch(merged.ContactInfos, restored.ContactInfos)
+	suite.Equal(merged.JobInfos, restored.JobInfos)
+	suite.Equal(merged.Settings, restored.Settings)
+	suite.True(restored.LastContactedAt.Time.Equal(at))
+
+	got, err = suite.personRepo.Get(ctx, survivor.ID)
+	suite.Require().NoError(err)
+	suite.False(got.LastName.Valid)
+	suite.Equal([]person.ContactInfo{{Method: person.Phone, Data: "+79161234567", Primary: true}}, got.ContactInfos)
+	suite.Empty(got.JobInfos)
+	suite.False(got.LastContactedAt.Valid)
+
+	n, err = suite.notifRepo.Get(ctx, mergedReminder.ID)
+	suite.Require().NoError(err)
+	suite.Equal(merged.ID, n.PersonID)
+	suite.Equal(notifications.Pending, n.Status)
+
+	n, err = suite.notifRepo.Get(ctx, mergedBirthday.ID)
+	suite.Require().NoError(err)
+	suite.Equal(merged.ID, n.PersonID)
+
+	i, err = suite.interactionRepo.Get(ctx, shared.ID)
+	suite.Require().NoError(err)
+	suite.ElementsMatch([]int{survivor.ID, merged.ID}, i.PersonIDs)
+
+	i, err = suite.interactionRepo.Get(ctx, moved.ID)
+	suite.Require().NoError(err)
+	suite.ElementsMatch([]int{merged.ID, other.ID}, i.PersonIDs)
+}
+
+func (suite *mergeRepoTestSuite) TestUndoAfterSurvivorDeleted() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	survivor := suite.createTestPerson(ctx, &person.Person{FirstName: "Anna"})
+	merged := suite.createTestPerson(ctx, &person.Person{FirstName: "Anya"})
+
+	m, err := suite.repo.Merge(ctx, survivor.ID, merged.ID)
+	suite.Require().NoError(err)
+
+	otherCtx := owner.WithID(ctx, suite.createTestUser(ctx))
+	_, err = suite.repo.Undo(otherCtx, m.ID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	suite.Require().NoError(suite.personRepo.Delete(ctx, survivor.ID))
+
+	_, err = suite.repo.Undo(ctx, m.ID)
+	suite.ErrorIs(err, ErrSurvivorDeleted)
+}
+
+func (suite *mergeRepoTestSuite) createTestPerson(ctx context.Context, p *person.Person) *person.Person {
+	err := suite.personRepo.Insert(ctx, p)
+	suite.Require().NoError(err)
+	suite.Require().NotZero(p.ID)
+
+	return p
+}
+
+func (suite *mergeRepoTestSuite) createTestNotification(ctx context.Context, personID int) *notifications.Notification {
+	n := &notifications.Notification{
+		PersonID:         personID,
+		Type:             notifications.KeepInTouch,
+		Status:           notifications.Pending,
+		NotificationTime: time.Now().UTC().Add(time.Hour),
+	}
+
+	err := suite.notifRepo.Insert(ctx, n)
+	suite.Require().NoError(err)
+
+	return n
+}
+
+func (suite *mergeRepoTestSuite) createTestInteraction(ctx context.Context, personIDs ...int) *interactions.Interaction {
+	i := &interactions.Interaction{
+		Kind:       interactions.Call,
+		OccurredAt: time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC),
+		PersonIDs:  personIDs,
+	}
+
+	err := suite.interactionRepo.Insert(ctx, i)
+	suite.Require().NoError(err)
+
+	return i
+}
+
+func (suite *mergeRepoTestSuite) createTestUser(ctx context.Context) int {
+	var id int
+	err := suite.tx.QueryRowContext(ctx, `INSERT INTO users DEFAULT VALUES RETURNING id`).Scan(&id)
+	suite.Require().NoError(err)
+
+	return id
+}
+
+func TestMergeRepoTestSuite(t *testing.T) {
+	suite.Run(t, new(mergeRepoTestSuite))
+}
@@ -1,6 +1,7 @@
 package notifications
 
 import (
+	"database/sql"
 	"time"
 )
 
@@ -8,21 +9,43 @@ type Type string
 
 const (
 	KeepInTouch Type = "keep_in_touch"
+	Birthday    Type = "birthday"
 )
 
 type Status string
 
 const (
-	Pending Status = "pending"
-	Raised  Status = "raised"
-	Failed  Status = "failed"
+	Pending    Status = "pending"
+	Processing Status = "processing"
+	Raised     Status = "raised"
+	Failed     Status = "failed"
+	// Acknowledged, Snoozed and Skipped are the outcomes of the actions
+	// offered on a raised reminder.
+	Acknowledged Status = "acknowledged"
+	Snoozed      Status = "snoozed"
+	Skipped      Status = "skipped"
 )
 
 type Notification struct {
 	NotificationTime time.Time
-	Description      string
-	Type             Type
-	Status           Status
-	PersonID         int
-	ID               int
+	// LeaseExpiresAt is set while the notification is being processed.
+	LeaseExpiresAt sql.NullTime
+	// NextAttemptAt is when a notification that failed to send is retried.
+	NextAttemptAt sql.NullTime
+	LastError     sql.NullString
+	Description   string
+	// Channels names the delivery channels of the notification. Empty means
+	// the default channels.
+	Channels []string
+	// DeliveredChannels are the channels that already delivered the
+	// notification. A retry only goes to the rest.
+	DeliveredChannels []string
+	Type              Type
+	Status            Status
+	Attempts          int
+	// OwnerID is the user the notification belongs to, the owner of its
+	// person.
+	OwnerID  int
+	PersonID int
+	ID       int
 }
@@ -4,11 +4,27 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
+	"github.com/lib/pq"
+	"github.com/lincentpega/personal-crm/internal/common/owner"
 	"github.com/lincentpega/personal-crm/internal/common/txcontext"
 	"github.com/lincentpega/personal-crm/internal/models"
 )
 
+// ErrLeaseLost is returned when a sender records the outcome of a
+// notification whose lease expired and was reaped, possibly to be claimed by
+// another sender.
+var ErrLeaseLost = errors.New("notification lease is lost")
+
+// ErrProcessing is returned when changing a notification a sender has
+// claimed.
+var ErrProcessing = errors.New("notification is being sent")
+
+// ErrNotPending is returned when rescheduling a notification that is no
+// longer pending.
+var ErrNotPending = errors.New("notification is no longer pending")
+
 type NotificationRepository struct {
 	db *sql.DB
 }
@@ -30,38 +46,117 @@ func (r *NotificationRepository) getDB(ctx context.Context) DB {
 	return r.db
 }
 
+const notificationColumns = `id, owner_id, person_id, type, status, notification_time, description, lease_expires_at,
+	attempts, last_error, next_attempt_at, channels, delivered_channels`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanNotification(s scanner, n *Notification) error {
+	return s.Scan(&n.ID, &n.OwnerID, &n.PersonID, &n.Type, &n.Status, &n.NotificationTime, &n.Description, &n.LeaseExpiresAt,
+		&n.Attempts, &n.LastError, &n.NextAttemptAt, (*pq.StringArray)(&n.Channels), (*pq.StringArray)(&n.DeliveredChannels))
+}
+
+// Insert stores the notification for a person of the owner from the context.
+// A person of another owner is reported as not found.
 func (r *NotificationRepository) Insert(ctx context.Context, n *Notification) error {
-	const stmt = `INSERT INTO notifications (person_id, type, status, notification_time, description)
-	VALUES($1, $2, $3, $4, $5)
-	RETURNING id`
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `INSERT INTO notifications (owner_id, person_id, type, status, notification_time, description, channels)
+	SELECT owner_id, id, $2, $3, $4, $5, COALESCE($6::text[], '{}')
+	FROM persons
+	WHERE id = $1 AND owner_id = $7
+	RETURNING id, owner_id`
 
-	err := r.getDB(ctx).QueryRowContext(ctx, stmt, n.PersonID, n.Type, n.Status, n.NotificationTime, n.Description).Scan(&n.ID)
+	err = r.getDB(ctx).QueryRowContext(ctx, stmt, n.PersonID, n.Type, n.Status, n.NotificationTime, n.Description,
+		pq.StringArray(n.Channels), ownerID).Scan(&n.ID, &n.OwnerID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrRecordNotFound
+		}
 		return err
 	}
 
 	return nil
 }
 
-func (r *NotificationRepository) UpdateNotificationStatus(ctx context.Context, notifID int, status Status) error {
-	const stmt = `UPDATE notifications SET status = $1 WHERE id = $2`
+// UpdateNotificationStatus moves a notification claimed with the given lease
+// out of processing to status. ErrLeaseLost is returned when the lease has
+// been reaped since, so a late sender never overwrites the outcome of the one
+// that claimed the notification after it.
+func (r *NotificationRepository) UpdateNotificationStatus(ctx context.Context, notifID int, lease time.Time, status Status) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `UPDATE notifications SET status = $1, lease_expires_at = NULL
+	WHERE id = $2 AND owner_id = $3 AND status = 'processing' AND lease_expires_at = $4`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, status, notifID, ownerID, lease)
+	if err != nil {
+		return err
+	}
+
+	return checkLease(res)
+}
 
-	_, err := r.getDB(ctx).ExecContext(ctx, stmt, status, notifID)
+// checkLease reports ErrLeaseLost when a write guarded by a lease changed
+// nothing.
+func checkLease(res sql.Result) error {
+	affected, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	if affected == 0 {
+		return ErrLeaseLost
+	}
+
 	return nil
 }
 
+// TransitionStatus moves the notification to status only if it currently is
+// in from, and reports whether it did. It guards against handling the same
+// notification twice.
+func (r *NotificationRepository) TransitionStatus(ctx context.Context, notifID int, from, to Status) (bool, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	const stmt = `UPDATE notifications SET status = $1 WHERE id = $2 AND status = $3 AND owner_id = $4`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, to, notifID, from, ownerID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
 func (r *NotificationRepository) Get(ctx context.Context, id int) (*Notification, error) {
-	const stmt = `SELECT id, person_id, type, status, notification_time, description
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const stmt = `SELECT ` + notificationColumns + `
 	FROM notifications
-	WHERE id = $1`
+	WHERE id = $1 AND owner_id = $2`
 
 	var n Notification
 
-	err := r.getDB(ctx).QueryRowContext(ctx, stmt, id).Scan(&n.ID, &n.PersonID, &n.Type, &n.Status, &n.NotificationTime, &n.Description)
+	err = scanNotification(r.getDB(ctx).QueryRowContext(ctx, stmt, id, ownerID), &n)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrRecordNotFound
@@ -73,13 +168,18 @@ func (r *NotificationRepository) Get(ctx context.Context, id int) (*Notification
 }
 
 func (r *NotificationRepository) GetAwaitingSend(ctx context.Context) ([]Notification, error) {
-	const stmt = `SELECT id, person_id, type, status, notification_time, description
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const stmt = `SELECT ` + notificationColumns + `
 	FROM notifications
-	WHERE status = 'pending' AND NOW() >= notification_time`
+	WHERE status = 'pending' AND NOW() >= notification_time AND owner_id = $1`
 
 	var ns []Notification
 
-	rows, err := r.getDB(ctx).QueryContext(ctx, stmt)
+	rows, err := r.getDB(ctx).QueryContext(ctx, stmt, ownerID)
 	if err != nil {
 		return nil, err
 	}
@@ -88,7 +188,7 @@ func (r *NotificationRepository) GetAwaitingSend(ctx context.Context) ([]Notific
 	for rows.Next() {
 		var n Notification
 
-		err := rows.Scan(&n.ID, &n.PersonID, &n.Type, &n.Status, &n.NotificationTime, &n.Description)
+		err := scanNotification(rows, &n)
 		if err != nil {
 			return nil, err
 		}
@@ -96,5 +196,390 @@ func (r *NotificationRepository) GetAwaitingSend(ctx context.Context) ([]Notific
 		ns = append(ns, n)
 	}
 
-	return ns, nil
+	return ns, rows.Err()
+}
+
+// LeaseDuration is how long a claimed notification may stay in processing.
+// Notifications whose lease expired are returned to pending by
+// ReapExpiredLeases, so a crashed sender does not lose them.
+const LeaseDuration = 5 * time.Minute
+
+// ClaimDue atomically moves up to limit due pending notifications of all
+// owners to processing and returns them. A notification that failed before
+// is due at its next attempt time. Rows locked by a concurrent claim are
+// skipped, so several senders never claim the same notification.
+func (r *NotificationRepository) ClaimDue(ctx context.Context, limit int) ([]Notification, error) {
+	const stmt = `UPDATE notifications n
+	SET status = 'processing', lease_expires_at = NOW() + make_interval(secs => $2)
+	FROM (
+		SELECT id FROM notifications
+		WHERE status = 'pending' AND NOW() >= COALESCE(next_attempt_at, notification_time)
+		ORDER BY COALESCE(next_attempt_at, notification_time)
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	) due
+	WHERE n.id = due.id
+	RETURNING n.id, n.owner_id, n.person_id, n.type, n.status, n.notification_time, n.description, n.lease_expires_at,
+		n.attempts, n.last_error, n.next_attempt_at, n.channels, n.delivered_channels`
+
+	rows, err := r.getDB(ctx).QueryContext(ctx, stmt, limit, LeaseDuration.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ns []Notification
+	for rows.Next() {
+		var n Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, err
+		}
+		ns = append(ns, n)
+	}
+
+	return ns, rows.Err()
+}
+
+// ReapExpiredLeases returns notifications of all owners stuck in processing
+// past their lease back to pending and reports how many were returned.
+func (r *NotificationRepository) ReapExpiredLeases(ctx context.Context) (int, error) {
+	const stmt = `UPDATE notifications
+	SET status = 'pending', lease_expires_at = NULL
+	WHERE status = 'processing' AND lease_expires_at < NOW()`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
+
+// ExistsAt reports whether the person already has a notification of the
+// given type, in any status, scheduled at the given time.
+func (r *NotificationRepository) ExistsAt(ctx context.Context, personID int, t Type, at time.Time) (bool, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	const stmt = `SELECT EXISTS (
+		SELECT 1 FROM notifications
+		WHERE person_id = $1 AND type = $2 AND notification_time = $3 AND owner_id = $4
+	)`
+
+	var exists bool
+	err = r.getDB(ctx).QueryRowContext(ctx, stmt, personID, t, at, ownerID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// GetPending returns the earliest pending notification of the given type for
+// the person.
+func (r *NotificationRepository) GetPending(ctx context.Context, personID int, t Type) (*Notification, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const stmt = `SELECT ` + notificationColumns + `
+	FROM notifications
+	WHERE person_id = $1 AND type = $2 AND status = 'pending' AND owner_id = $3
+	ORDER BY notification_time
+	LIMIT 1`
+
+	var n Notification
+
+	err = scanNotification(r.getDB(ctx).QueryRowContext(ctx, stmt, personID, t, ownerID), &n)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+// ListUpcoming returns the notifications of the person that are still to be
+// sent, earliest first.
+func (r *NotificationRepository) ListUpcoming(ctx context.Context, personID int) ([]Notification, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const stmt = `SELECT ` + notificationColumns + `
+	FROM notifications
+	WHERE person_id = $1 AND owner_id = $2 AND status IN ('pending', 'processing')
+	ORDER BY notification_time, id`
+
+	rows, err := r.getDB(ctx).QueryContext(ctx, stmt, personID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ns []Notification
+	for rows.Next() {
+		var n Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, err
+		}
+		ns = append(ns, n)
+	}
+
+	return ns, rows.Err()
+}
+
+const defaultPageSize = 20
+
+// ListOptions filters and pages the notifications returned by List. Zero
+// values don't filter.
+type ListOptions struct {
+	PersonID int
+	Status   Status
+	// Page is 1-based. Values below 1 are treated as the first page.
+	Page     int
+	PageSize int
+}
+
+func (o ListOptions) limitOffset() (int, int) {
+	size := o.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+
+	page := o.Page
+	if page < 1 {
+		page = 1
+	}
+
+	return size, (page - 1) * size
+}
+
+// List returns one page of the owner's notifications, latest first,
+// together with the total number of notifications matching the filter.
+func (r *NotificationRepository) List(ctx context.Context, opts ListOptions) ([]Notification, int, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	const filter = `WHERE owner_id = $1 AND ($2 = 0 OR person_id = $2) AND ($3 = '' OR status::text = $3)`
+
+	const countStmt = `SELECT count(*) FROM notifications ` + filter
+
+	var total int
+	err = r.getDB(ctx).QueryRowContext(ctx, countStmt, ownerID, opts.PersonID, opts.Status).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	const stmt = `SELECT ` + notificationColumns + `
+	FROM notifications ` + filter + `
+	ORDER BY notification_time DESC, id DESC
+	LIMIT $4 OFFSET $5`
+
+	limit, offset := opts.limitOffset()
+
+	rows, err := r.getDB(ctx).QueryContext(ctx, stmt, ownerID, opts.PersonID, opts.Status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var ns []Notification
+	for rows.Next() {
+		var n Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, 0, err
+		}
+		ns = append(ns, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return ns, total, nil
+}
+
+// Update overwrites the type, status, time, description and channels of the
+// notification and starts its delivery over: attempts, errors and delivered
+// channels are reset. The person of a notification can't be changed, and a
+// notification being sent can't be changed until the sender is done with it,
+// ErrProcessing is returned then.
+func (r *NotificationRepository) Update(ctx context.Context, n *Notification) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `UPDATE notifications
+	SET type = $1, status = $2, notification_time = $3, description = $4, channels = COALESCE($5::text[], '{}'),
+		lease_expires_at = NULL, attempts = 0, last_error = NULL, next_attempt_at = NULL, delivered_channels = '{}'
+	WHERE id = $6 AND owner_id = $7 AND status <> 'processing'
+	RETURNING ` + notificationColumns
+
+	err = scanNotification(r.getDB(ctx).QueryRowContext(ctx, stmt, n.Type, n.Status, n.NotificationTime, n.Description,
+		pq.StringArray(n.Channels), n.ID, ownerID), n)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := r.Get(ctx, n.ID); err != nil {
+			return err
+		}
+		return ErrProcessing
+	}
+
+	return err
+}
+
+func (r *NotificationRepository) Delete(ctx context.Context, id int) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `DELETE FROM notifications WHERE id = $1 AND owner_id = $2`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, id, ownerID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return models.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Reschedule moves a pending notification to a new time, resetting its
+// attempts and deliveries. ErrNotPending is returned when the notification
+// is no longer pending, such as when a sender has just claimed it.
+func (r *NotificationRepository) Reschedule(ctx context.Context, notifID int, at time.Time) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `UPDATE notifications
+	SET notification_time = $1, attempts = 0, last_error = NULL, next_attempt_at = NULL, delivered_channels = '{}'
+	WHERE id = $2 AND owner_id = $3 AND status = 'pending'`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, at, notifID, ownerID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNotPending
+	}
+
+	return nil
+}
+
+// RescheduleClaimed moves a notification claimed with the given lease to a
+// new time and returns it to pending, releasing the lease and resetting its
+// attempts and deliveries. ErrLeaseLost is returned when the lease has been
+// reaped since.
+func (r *NotificationRepository) RescheduleClaimed(ctx context.Context, notifID int, lease, at time.Time) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `UPDATE notifications
+	SET notification_time = $1, status = 'pending', lease_expires_at = NULL,
+		attempts = 0, last_error = NULL, next_attempt_at = NULL, delivered_channels = '{}'
+	WHERE id = $2 AND owner_id = $3 AND status = 'processing' AND lease_expires_at = $4`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, at, notifID, ownerID, lease)
+	if err != nil {
+		return err
+	}
+
+	return checkLease(res)
+}
+
+// AddDeliveredChannels records that the channels delivered a notification
+// claimed with the given lease. ErrLeaseLost is returned when the lease has
+// been reaped since.
+func (r *NotificationRepository) AddDeliveredChannels(ctx context.Context, notifID int, lease time.Time, channels []string) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `UPDATE notifications SET delivered_channels = delivered_channels || $1::text[]
+	WHERE id = $2 AND owner_id = $3 AND status = 'processing' AND lease_expires_at = $4`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, pq.StringArray(channels), notifID, ownerID, lease)
+	if err != nil {
+		return err
+	}
+
+	return checkLease(res)
+}
+
+// ScheduleRetry records a failed delivery attempt of a notification claimed
+// with the given lease and returns it to pending until retryAt.
+// ErrLeaseLost is returned when the lease has been reaped since.
+func (r *NotificationRepository) ScheduleRetry(ctx context.Context, notifID int, lease time.Time, lastError string, retryAt time.Time) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `UPDATE notifications
+	SET status = 'pending', lease_expires_at = NULL,
+		attempts = attempts + 1, last_error = $1, next_attempt_at = $2
+	WHERE id = $3 AND owner_id = $4 AND status = 'processing' AND lease_expires_at = $5`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, lastError, retryAt, notifID, ownerID, lease)
+	if err != nil {
+		return err
+	}
+
+	return checkLease(res)
+}
+
+// Fail records the final failed delivery attempt of a notification claimed
+// with the given lease and moves it to failed. ErrLeaseLost is returned when
+// the lease has been reaped since.
+func (r *NotificationRepository) Fail(ctx context.Context, notifID int, lease time.Time, lastError string) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `UPDATE notifications
+	SET status = 'failed', lease_expires_at = NULL, next_attempt_at = NULL,
+		attempts = attempts + 1, last_error = $1
+	WHERE id = $2 AND owner_id = $3 AND status = 'processing' AND lease_expires_at = $4`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, lastError, notifID, ownerID, lease)
+	if err != nil {
+		return err
+	}
+
+	return checkLease(res)
 }
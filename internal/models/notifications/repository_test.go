@@ -3,11 +3,16 @@ package notifications
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/lincentpega/personal-crm/internal/common/owner"
 	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
 	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/models/users"
 	"github.com/lincentpega/personal-crm/internal/test"
 	"github.com/stretchr/testify/suite"
 )
@@ -30,8 +35,10 @@ type notificationRepoTestSuite struct {
 
 func (suite *notificationRepoTestSuite) SetupSuite() {
 	suite.TestSuite.SetupSuite()
+	suite.Ctx = owner.WithID(suite.Ctx, users.DefaultID)
 
 	suite.notifRepo = NewRepository(suite.DB)
+	suite.personRepo = person.NewRepository(suite.DB)
 }
 
 func (suite *notificationRepoTestSuite) SetupTest() {
@@ -53,9 +60,9 @@ func (suite *notificationRepoTestSuite) TestGet() {
 	notifTime := time.Now().Add(24 * time.Hour).UTC()
 
 	var notifID int
-	stmt := `INSERT INTO notifications (person_id, type, status, notification_time, description) 
-	VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	err := suite.tx.QueryRowContext(ctx, stmt, person.ID, testNotifType, testNotifStatus, notifTime, testNotifDescription).Scan(&notifID)
+	stmt := `INSERT INTO notifications (owner_id, person_id, type, status, notification_time, description) 
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err := suite.tx.QueryRowContext(ctx, stmt, users.DefaultID, person.ID, testNotifType, testNotifStatus, notifTime, testNotifDescription).Scan(&notifID)
 	suite.NoError(err)
 
 	notification, err := suite.notifRepo.Get(ctx, notifID)
@@ -95,12 +102,38 @@ func (suite *notificationRepoTestSuite) TestInsert() {
 	suite.Require().Equal(testNotifDescription, insertedNotif.Description)
 }
 
+func (suite *notificationRepoTestSuite) TestInsertChannels() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	routed := Notification{
+		PersonID:         person.ID,
+		Type:             testNotifType,
+		Status:           Pending,
+		NotificationTime: time.Now().UTC(),
+		Channels:         []string{"email", "webhook"},
+	}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &routed))
+
+	defaults := Notification{PersonID: person.ID, Type: testNotifType, Status: Pending, NotificationTime: time.Now().UTC()}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &defaults))
+
+	n, err := suite.notifRepo.Get(ctx, routed.ID)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"email", "webhook"}, n.Channels)
+
+	n, err = suite.notifRepo.Get(ctx, defaults.ID)
+	suite.Require().NoError(err)
+	suite.Empty(n.Channels)
+}
+
 func (suite *notificationRepoTestSuite) TestUpdateNotificationStatus() {
 	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
 
 	person := suite.createTestPerson(ctx)
 
-	notifTime := time.Now().Add(time.Hour * 24).UTC()
+	notifTime := time.Now().Add(-time.Hour).UTC()
 	notif := Notification{
 		PersonID:         person.ID,
 		Type:             testNotifType,
@@ -112,12 +145,50 @@ func (suite *notificationRepoTestSuite) TestUpdateNotificationStatus() {
 	err := suite.notifRepo.Insert(ctx, &notif)
 	suite.Require().NoError(err)
 
-	err = suite.notifRepo.UpdateNotificationStatus(ctx, notif.ID, Failed)
+	claimed, err := suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+
+	err = suite.notifRepo.UpdateNotificationStatus(ctx, notif.ID, claimed[0].LeaseExpiresAt.Time, Failed)
 	suite.Require().NoError(err)
 
 	updatedNotif, err := suite.notifRepo.Get(ctx, notif.ID)
 	suite.Require().NoError(err)
 	suite.Require().Equal(Failed, updatedNotif.Status)
+
+	err = suite.notifRepo.UpdateNotificationStatus(ctx, notif.ID, claimed[0].LeaseExpiresAt.Time, Raised)
+	suite.ErrorIs(err, ErrLeaseLost)
+}
+
+func (suite *notificationRepoTestSuite) TestLostLease() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	n := Notification{PersonID: person.ID, Type: testNotifType, Status: Pending, NotificationTime: time.Now().Add(-time.Hour).UTC()}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &n))
+
+	claimed, err := suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+
+	// Another sender claims the notification after its lease was reaped. NOW()
+	// doesn't move within the test transaction, so the new lease is set here.
+	_, err = suite.tx.ExecContext(ctx, `UPDATE notifications SET lease_expires_at = lease_expires_at + INTERVAL '1 minute' WHERE id = $1`, n.ID)
+	suite.Require().NoError(err)
+
+	stale := claimed[0].LeaseExpiresAt.Time
+	suite.ErrorIs(suite.notifRepo.UpdateNotificationStatus(ctx, n.ID, stale, Raised), ErrLeaseLost)
+	suite.ErrorIs(suite.notifRepo.ScheduleRetry(ctx, n.ID, stale, "telegram: timeout", time.Now().UTC()), ErrLeaseLost)
+	suite.ErrorIs(suite.notifRepo.Fail(ctx, n.ID, stale, "telegram: forbidden"), ErrLeaseLost)
+	suite.ErrorIs(suite.notifRepo.RescheduleClaimed(ctx, n.ID, stale, time.Now().Add(time.Hour).UTC()), ErrLeaseLost)
+
+	got, err := suite.notifRepo.Get(ctx, n.ID)
+	suite.Require().NoError(err)
+	suite.Equal(Processing, got.Status)
+	suite.Zero(got.Attempts)
+
+	suite.Require().NoError(suite.notifRepo.UpdateNotificationStatus(ctx, n.ID, got.LeaseExpiresAt.Time, Raised))
 }
 
 func (suite *notificationRepoTestSuite) TestGetAwaitingSend() {
@@ -167,6 +238,530 @@ func (suite *notificationRepoTestSuite) TestGetAwaitingSend() {
 	suite.Require().Equal(shouldGetNotif.ID, notifs[0].ID)
 }
 
+func (suite *notificationRepoTestSuite) TestExistsAt() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	notifTime := time.Now().Add(time.Hour * 24).UTC().Truncate(time.Second)
+	notif := Notification{
+		PersonID:         person.ID,
+		Type:             Birthday,
+		Status:           Raised,
+		NotificationTime: notifTime,
+	}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &notif))
+
+	exists, err := suite.notifRepo.ExistsAt(ctx, person.ID, Birthday, notifTime)
+	suite.Require().NoError(err)
+	suite.True(exists)
+
+	// A heads-up days before the birthday is not the birthday notification.
+	exists, err = suite.notifRepo.ExistsAt(ctx, person.ID, Birthday, notifTime.AddDate(0, 0, -3))
+	suite.Require().NoError(err)
+	suite.False(exists)
+
+	exists, err = suite.notifRepo.ExistsAt(ctx, person.ID, KeepInTouch, notifTime)
+	suite.Require().NoError(err)
+	suite.False(exists)
+}
+
+func (suite *notificationRepoTestSuite) TestGetPendingAndReschedule() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	_, err := suite.notifRepo.GetPending(ctx, person.ID, KeepInTouch)
+	suite.Require().ErrorIs(err, models.ErrRecordNotFound)
+
+	notifTime := time.Now().Add(time.Hour * 24).UTC().Truncate(time.Microsecond)
+	raised := Notification{PersonID: person.ID, Type: KeepInTouch, Status: Raised, NotificationTime: notifTime}
+	pending := Notification{PersonID: person.ID, Type: KeepInTouch, Status: Pending, NotificationTime: notifTime}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &raised))
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &pending))
+
+	n, err := suite.notifRepo.GetPending(ctx, person.ID, KeepInTouch)
+	suite.Require().NoError(err)
+	suite.Equal(pending.ID, n.ID)
+
+	newTime := notifTime.Add(time.Hour * 24 * 7)
+	suite.Require().NoError(suite.notifRepo.Reschedule(ctx, pending.ID, newTime))
+
+	n, err = suite.notifRepo.Get(ctx, pending.ID)
+	suite.Require().NoError(err)
+	suite.Equal(newTime, n.NotificationTime.UTC())
+
+	// A claimed notification is the sender's to reschedule.
+	_, err = suite.tx.ExecContext(ctx, `UPDATE notifications SET notification_time = NOW() - INTERVAL '1 minute' WHERE id = $1`, pending.ID)
+	suite.Require().NoError(err)
+	claimed, err := suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+
+	suite.ErrorIs(suite.notifRepo.Reschedule(ctx, pending.ID, newTime), ErrNotPending)
+	suite.ErrorIs(suite.notifRepo.Reschedule(ctx, raised.ID, newTime), ErrNotPending)
+
+	suite.Require().NoError(suite.notifRepo.RescheduleClaimed(ctx, pending.ID, claimed[0].LeaseExpiresAt.Time, newTime))
+	n, err = suite.notifRepo.Get(ctx, pending.ID)
+	suite.Require().NoError(err)
+	suite.Equal(Pending, n.Status)
+	suite.False(n.LeaseExpiresAt.Valid)
+	suite.Equal(newTime, n.NotificationTime.UTC())
+}
+
+func (suite *notificationRepoTestSuite) TestTransitionStatus() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	n := Notification{PersonID: person.ID, Type: testNotifType, Status: Raised, NotificationTime: time.Now().UTC()}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &n))
+
+	ok, err := suite.notifRepo.TransitionStatus(ctx, n.ID, Raised, Snoozed)
+	suite.Require().NoError(err)
+	suite.True(ok)
+
+	ok, err = suite.notifRepo.TransitionStatus(ctx, n.ID, Raised, Acknowledged)
+	suite.Require().NoError(err)
+	suite.False(ok)
+
+	got, err := suite.notifRepo.Get(ctx, n.ID)
+	suite.Require().NoError(err)
+	suite.Equal(Snoozed, got.Status)
+}
+
+func (suite *notificationRepoTestSuite) TestOwnerIsolation() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	other, err := users.NewRepository(suite.DB).EnsureTelegramUser(ctx, 1001, "Other")
+	suite.Require().NoError(err)
+	otherCtx := owner.WithID(ctx, other.ID)
+
+	person := suite.createTestPerson(ctx)
+
+	n := Notification{PersonID: person.ID, Type: testNotifType, Status: Raised, NotificationTime: time.Now().UTC()}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &n))
+	suite.Equal(users.DefaultID, n.OwnerID)
+
+	_, err = suite.notifRepo.Get(otherCtx, n.ID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	_, err = suite.notifRepo.GetPending(otherCtx, person.ID, testNotifType)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	ok, err := suite.notifRepo.TransitionStatus(otherCtx, n.ID, Raised, Skipped)
+	suite.Require().NoError(err)
+	suite.False(ok)
+
+	foreign := Notification{PersonID: person.ID, Type: testNotifType, Status: Pending, NotificationTime: time.Now().UTC()}
+	suite.ErrorIs(suite.notifRepo.Insert(otherCtx, &foreign), models.ErrRecordNotFound)
+
+	got, err := suite.notifRepo.Get(ctx, n.ID)
+	suite.Require().NoError(err)
+	suite.Equal(Raised, got.Status)
+}
+
+func (suite *notificationRepoTestSuite) TestListUpcoming() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	later := Notification{PersonID: person.ID, Type: Birthday, Status: Pending, NotificationTime: now.Add(48 * time.Hour)}
+	sooner := Notification{PersonID: person.ID, Type: KeepInTouch, Status: Pending, NotificationTime: now.Add(time.Hour)}
+	raised := Notification{PersonID: person.ID, Type: KeepInTouch, Status: Raised, NotificationTime: now.Add(-time.Hour)}
+	for _, n := range []*Notification{&later, &sooner, &raised} {
+		suite.Require().NoError(suite.notifRepo.Insert(ctx, n))
+	}
+
+	upcoming, err := suite.notifRepo.ListUpcoming(ctx, person.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(upcoming, 2)
+	suite.Equal(sooner.ID, upcoming[0].ID)
+	suite.Equal(later.ID, upcoming[1].ID)
+}
+
+func (suite *notificationRepoTestSuite) TestList() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	john := suite.createTestPerson(ctx)
+	jane := suite.createTestPerson(ctx)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	first := Notification{PersonID: john.ID, Type: Birthday, Status: Pending, NotificationTime: now.Add(time.Hour)}
+	second := Notification{PersonID: john.ID, Type: KeepInTouch, Status: Raised, NotificationTime: now.Add(2 * time.Hour)}
+	other := Notification{PersonID: jane.ID, Type: KeepInTouch, Status: Pending, NotificationTime: now.Add(3 * time.Hour)}
+	for _, n := range []*Notification{&first, &second, &other} {
+		suite.Require().NoError(suite.notifRepo.Insert(ctx, n))
+	}
+
+	ns, total, err := suite.notifRepo.List(ctx, ListOptions{PersonID: john.ID, PageSize: 1})
+	suite.Require().NoError(err)
+	suite.Equal(2, total)
+	suite.Require().Len(ns, 1)
+	suite.Equal(second.ID, ns[0].ID)
+
+	ns, _, err = suite.notifRepo.List(ctx, ListOptions{PersonID: john.ID, Page: 2, PageSize: 1})
+	suite.Require().NoError(err)
+	suite.Require().Len(ns, 1)
+	suite.Equal(first.ID, ns[0].ID)
+
+	ns, total, err = suite.notifRepo.List(ctx, ListOptions{Status: Pending})
+	suite.Require().NoError(err)
+	suite.Equal(2, total)
+	suite.Equal(other.ID, ns[0].ID)
+}
+
+func (suite *notificationRepoTestSuite) TestUpdateAndDelete() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	n := Notification{PersonID: person.ID, Type: KeepInTouch, Status: Pending, NotificationTime: time.Now().UTC()}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &n))
+
+	n.Type = Birthday
+	n.Status = Skipped
+	n.NotificationTime = n.NotificationTime.Add(time.Hour).Truncate(time.Microsecond)
+	n.Description = "Updated"
+	n.Channels = []string{"email"}
+	suite.Require().NoError(suite.notifRepo.Update(ctx, &n))
+
+	got, err := suite.notifRepo.Get(ctx, n.ID)
+	suite.Require().NoError(err)
+	suite.Equal(Birthday, got.Type)
+	suite.Equal(Skipped, got.Status)
+	suite.Equal(n.NotificationTime, got.NotificationTime.UTC())
+	suite.Equal("Updated", got.Description)
+	suite.Equal([]string{"email"}, got.Channels)
+
+	suite.Require().NoError(suite.notifRepo.Delete(ctx, n.ID))
+
+	_, err = suite.notifRepo.Get(ctx, n.ID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	suite.ErrorIs(suite.notifRepo.Delete(ctx, n.ID), models.ErrRecordNotFound)
+	suite.ErrorIs(suite.notifRepo.Update(ctx, &n), models.ErrRecordNotFound)
+}
+
+func (suite *notificationRepoTestSuite) TestUpdateResetsDelivery() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	n := Notification{PersonID: person.ID, Type: KeepInTouch, Status: Pending, NotificationTime: time.Now().Add(-time.Hour).UTC()}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &n))
+
+	claimed, err := suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+
+	n.NotificationTime = time.Now().Add(time.Hour).UTC()
+	suite.ErrorIs(suite.notifRepo.Update(ctx, &n), ErrProcessing)
+
+	suite.Require().NoError(suite.notifRepo.Fail(ctx, n.ID, claimed[0].LeaseExpiresAt.Time, "telegram: forbidden"))
+
+	n.Status = Pending
+	n.NotificationTime = time.Now().Add(-time.Minute).UTC()
+	suite.Require().NoError(suite.notifRepo.Update(ctx, &n))
+	suite.Zero(n.Attempts)
+	suite.False(n.LastError.Valid)
+	suite.False(n.NextAttemptAt.Valid)
+
+	claimed, err = suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+	suite.Equal(n.ID, claimed[0].ID)
+}
+
+func (suite *notificationRepoTestSuite) TestClaimDue() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	due := Notification{PersonID: person.ID, Type: testNotifType, Status: Pending, NotificationTime: time.Now().Add(-time.Hour).UTC()}
+	early := Notification{PersonID: person.ID, Type: testNotifType, Status: Pending, NotificationTime: time.Now().Add(time.Hour).UTC()}
+	failed := Notification{PersonID: person.ID, Type: testNotifType, Status: Failed, NotificationTime: time.Now().Add(-time.Hour).UTC()}
+	for _, n := range []*Notification{&due, &early, &failed} {
+		suite.Require().NoError(suite.notifRepo.Insert(ctx, n))
+	}
+
+	claimed, err := suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+	suite.Equal(due.ID, claimed[0].ID)
+	suite.Equal(Processing, claimed[0].Status)
+	suite.True(claimed[0].LeaseExpiresAt.Valid)
+
+	lease := claimed[0].LeaseExpiresAt.Time
+
+	claimed, err = suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Empty(claimed)
+
+	suite.Require().NoError(suite.notifRepo.UpdateNotificationStatus(ctx, due.ID, lease, Raised))
+
+	raised, err := suite.notifRepo.Get(ctx, due.ID)
+	suite.Require().NoError(err)
+	suite.Equal(Raised, raised.Status)
+	suite.False(raised.LeaseExpiresAt.Valid)
+}
+
+func (suite *notificationRepoTestSuite) TestReapExpiredLeases() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	n := Notification{PersonID: person.ID, Type: testNotifType, Status: Pending, NotificationTime: time.Now().Add(-time.Hour).UTC()}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &n))
+
+	claimed, err := suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+
+	reaped, err := suite.notifRepo.ReapExpiredLeases(ctx)
+	suite.Require().NoError(err)
+	suite.Zero(reaped)
+
+	_, err = suite.tx.ExecContext(ctx, `UPDATE notifications SET lease_expires_at = NOW() - INTERVAL '1 minute' WHERE id = $1`, n.ID)
+	suite.Require().NoError(err)
+
+	reaped, err = suite.notifRepo.ReapExpiredLeases(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(1, reaped)
+
+	reapedNotif, err := suite.notifRepo.Get(ctx, n.ID)
+	suite.Require().NoError(err)
+	suite.Equal(Pending, reapedNotif.Status)
+	suite.False(reapedNotif.LeaseExpiresAt.Valid)
+}
+
+func (suite *notificationRepoTestSuite) TestRetries() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	n := Notification{PersonID: person.ID, Type: testNotifType, Status: Pending, NotificationTime: time.Now().Add(-time.Hour).UTC()}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &n))
+
+	claimed, err := suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+	suite.Zero(claimed[0].Attempts)
+
+	retryAt := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+	suite.Require().NoError(suite.notifRepo.ScheduleRetry(ctx, n.ID, claimed[0].LeaseExpiresAt.Time, "telegram: timeout", retryAt))
+
+	retried, err := suite.notifRepo.Get(ctx, n.ID)
+	suite.Require().NoError(err)
+	suite.Equal(Pending, retried.Status)
+	suite.Equal(1, retried.Attempts)
+	suite.Equal("telegram: timeout", retried.LastError.String)
+	suite.Equal(retryAt, retried.NextAttemptAt.Time.UTC())
+	suite.False(retried.LeaseExpiresAt.Valid)
+
+	claimed, err = suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Empty(claimed, "notification must not be claimed before its next attempt")
+
+	_, err = suite.tx.ExecContext(ctx, `UPDATE notifications SET next_attempt_at = NOW() - INTERVAL '1 minute' WHERE id = $1`, n.ID)
+	suite.Require().NoError(err)
+
+	claimed, err = suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+	suite.Require().NoError(suite.notifRepo.ScheduleRetry(ctx, n.ID, claimed[0].LeaseExpiresAt.Time, "telegram: bad gateway", time.Now().Add(-time.Minute).UTC()))
+
+	claimed, err = suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+	suite.Equal(2, claimed[0].Attempts)
+	suite.Equal("telegram: bad gateway", claimed[0].LastError.String)
+
+	suite.Require().NoError(suite.notifRepo.Fail(ctx, n.ID, claimed[0].LeaseExpiresAt.Time, "telegram: forbidden"))
+
+	failed, err := suite.notifRepo.Get(ctx, n.ID)
+	suite.Require().NoError(err)
+	suite.Equal(Failed, failed.Status)
+	suite.Equal(3, failed.Attempts)
+	suite.Equal("telegram: forbidden", failed.LastError.String)
+	suite.False(failed.NextAttemptAt.Valid)
+}
+
+func (suite *notificationRepoTestSuite) TestDeliveredChannels() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := suite.createTestPerson(ctx)
+
+	n := Notification{PersonID: person.ID, Type: testNotifType, Status: Pending, NotificationTime: time.Now().Add(-time.Hour).UTC(),
+		Channels: []string{"telegram", "email"}}
+	suite.Require().NoError(suite.notifRepo.Insert(ctx, &n))
+
+	claimed, err := suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+	suite.Empty(claimed[0].DeliveredChannels)
+
+	lease := claimed[0].LeaseExpiresAt.Time
+	suite.Require().NoError(suite.notifRepo.AddDeliveredChannels(ctx, n.ID, lease, []string{"telegram"}))
+	suite.Require().NoError(suite.notifRepo.ScheduleRetry(ctx, n.ID, lease, "email: timeout", time.Now().Add(-time.Minute).UTC()))
+
+	claimed, err = suite.notifRepo.ClaimDue(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(claimed, 1)
+	suite.Equal([]string{"telegram"}, claimed[0].DeliveredChannels)
+
+	suite.Require().NoError(suite.notifRepo.RescheduleClaimed(ctx, n.ID, claimed[0].LeaseExpiresAt.Time, time.Now().Add(time.Hour).UTC()))
+
+	got, err := suite.notifRepo.Get(ctx, n.ID)
+	suite.Require().NoError(err)
+	suite.Empty(got.DeliveredChannels)
+}
+
+// TestClaimDueConcurrent simulates several bot instances claiming from the
+// same table. It commits its rows, so it cleans up after itself.
+func (suite *notificationRepoTestSuite) TestClaimDueConcurrent() {
+	const (
+		notificationsCount = 50
+		instances          = 4
+		batchSize          = 3
+	)
+
+	person := suite.createTestPerson(suite.Ctx)
+	defer func() {
+		_, err := suite.DB.ExecContext(suite.Ctx, `DELETE FROM persons WHERE id = $1`, person.ID)
+		suite.Require().NoError(err)
+	}()
+
+	for i := 0; i < notificationsCount; i++ {
+		n := Notification{PersonID: person.ID, Type: testNotifType, Status: Pending, NotificationTime: time.Now().Add(-time.Minute).UTC()}
+		suite.Require().NoError(suite.notifRepo.Insert(suite.Ctx, &n))
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		claims  = make(map[int]int)
+		errs    []error
+		claimed int
+	)
+
+	for i := 0; i < instances; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				ns, err := suite.notifRepo.ClaimDue(suite.Ctx, batchSize)
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				}
+				for _, n := range ns {
+					claims[n.ID]++
+				}
+				claimed += len(ns)
+				mu.Unlock()
+				if err != nil || len(ns) == 0 {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	suite.Require().Empty(errs)
+	suite.Equal(notificationsCount, claimed)
+	suite.Len(claims, notificationsCount)
+	for id, count := range claims {
+		suite.Equal(1, count, "notification %d claimed more than once", id)
+	}
+}
+
+// TestRescheduleConcurrent simulates contacts recorded while bot instances
+// claim the reminders. A claimed reminder must not go back to pending, or it
+// would be sent twice. It commits its rows, so it cleans up after itself.
+func (suite *notificationRepoTestSuite) TestRescheduleConcurrent() {
+	const (
+		notificationsCount = 50
+		instances          = 4
+		batchSize          = 3
+	)
+
+	person := suite.createTestPerson(suite.Ctx)
+	defer func() {
+		_, err := suite.DB.ExecContext(suite.Ctx, `DELETE FROM persons WHERE id = $1`, person.ID)
+		suite.Require().NoError(err)
+	}()
+
+	ids := make([]int, notificationsCount)
+	for i := range ids {
+		n := Notification{PersonID: person.ID, Type: KeepInTouch, Status: Pending, NotificationTime: time.Now().Add(-time.Minute).UTC()}
+		suite.Require().NoError(suite.notifRepo.Insert(suite.Ctx, &n))
+		ids[i] = n.ID
+	}
+
+	var (
+		mu          sync.Mutex
+		wg          sync.WaitGroup
+		claims      = make(map[int]int)
+		rescheduled = make(map[int]bool)
+		errs        []error
+	)
+
+	for i := 0; i < instances; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				ns, err := suite.notifRepo.ClaimDue(suite.Ctx, batchSize)
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				}
+				for _, n := range ns {
+					claims[n.ID]++
+				}
+				mu.Unlock()
+				if err != nil || len(ns) == 0 {
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, id := range ids {
+			err := suite.notifRepo.Reschedule(suite.Ctx, id, time.Now().Add(time.Hour).UTC())
+			mu.Lock()
+			switch {
+			case err == nil:
+				rescheduled[id] = true
+			case !errors.Is(err, ErrNotPending):
+				errs = append(errs, err)
+			}
+			mu.Unlock()
+		}
+	}()
+	wg.Wait()
+
+	suite.Require().Empty(errs)
+	for _, id := range ids {
+		n, err := suite.notifRepo.Get(suite.Ctx, id)
+		suite.Require().NoError(err)
+
+		if claims[id] > 0 {
+			suite.Equal(1, claims[id], "notification %d claimed more than once", id)
+			suite.False(rescheduled[id], "claimed notification %d was rescheduled", id)
+			suite.Equal(Processing, n.Status)
+		} else {
+			suite.True(rescheduled[id], "notification %d was neither claimed nor rescheduled", id)
+			suite.Equal(Pending, n.Status)
+		}
+	}
+}
+
 func (suite *notificationRepoTestSuite) createTestPerson(ctx context.Context) *person.Person {
 	pBirthDate, err := time.Parse("2006-01-02", testPersonBirthDate)
 	suite.Require().NoError(err)
@@ -0,0 +1,45 @@
+package person
+
+import "time"
+
+// BirthdayIn returns the date on which a person born on birthDate celebrates
+// in the given year. People born on February 29 celebrate on February 28 in
+// non-leap years.
+func BirthdayIn(birthDate time.Time, year int) time.Time {
+	month, day := birthDate.Month(), birthDate.Day()
+	if month == time.February && day == 29 && !isLeap(year) {
+		day = 28
+	}
+
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// NextBirthday returns the first birthday that falls on the day of now or
+// later.
+func NextBirthday(birthDate, now time.Time) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	next := BirthdayIn(birthDate, today.Year())
+	if next.Before(today) {
+		next = BirthdayIn(birthDate, today.Year()+1)
+	}
+
+	return next
+}
+
+// AgeOn returns how many full years old a person born on birthDate is on the
+// day of t.
+func AgeOn(birthDate, t time.Time) int {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	age := day.Year() - birthDate.Year()
+	if day.Before(BirthdayIn(birthDate, day.Year())) {
+		age--
+	}
+
+	return age
+}
+
+func isLeap(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
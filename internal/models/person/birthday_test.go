@@ -0,0 +1,50 @@
+package person
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestNextBirthday(t *testing.T) {
+	tests := []struct {
+		birthDate time.Time
+		now       time.Time
+		expected  time.Time
+	}{
+		{date(1990, time.July, 19), date(2024, time.March, 1), date(2024, time.July, 19)},
+		{date(1990, time.July, 19), date(2024, time.July, 19).Add(15 * time.Hour), date(2024, time.July, 19)},
+		{date(1990, time.July, 19), date(2024, time.July, 20), date(2025, time.July, 19)},
+		{date(2000, time.February, 29), date(2024, time.January, 10), date(2024, time.February, 29)},
+		{date(2000, time.February, 29), date(2024, time.March, 1), date(2025, time.February, 28)},
+		{date(2000, time.February, 29), date(2099, time.March, 1), date(2100, time.February, 28)},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, NextBirthday(tt.birthDate, tt.now), tt.now.String())
+	}
+}
+
+func TestAgeOn(t *testing.T) {
+	tests := []struct {
+		birthDate time.Time
+		day       time.Time
+		expected  int
+	}{
+		{date(1990, time.July, 19), date(2024, time.July, 18), 33},
+		{date(1990, time.July, 19), date(2024, time.July, 19), 34},
+		{date(2000, time.February, 29), date(2025, time.February, 27), 24},
+		{date(2000, time.February, 29), date(2025, time.February, 28), 25},
+		{date(2000, time.February, 29), date(2028, time.February, 28), 27},
+		{date(2000, time.February, 29), date(2028, time.February, 29), 28},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, AgeOn(tt.birthDate, tt.day), tt.day.String())
+	}
+}
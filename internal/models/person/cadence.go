@@ -0,0 +1,136 @@
+package person
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CadenceUnit string
+
+const (
+	Day   CadenceUnit = "d"
+	Week  CadenceUnit = "w"
+	Month CadenceUnit = "m"
+	Year  CadenceUnit = "y"
+)
+
+var ErrInvalidCadence = errors.New("invalid cadence")
+
+// Cadence is a recurring interval such as "every 2 weeks". The zero value
+// means no cadence. Cadences are stored in their short form, e.g. "2w".
+type Cadence struct {
+	Count int
+	Unit  CadenceUnit
+}
+
+var (
+	cadencePattern = regexp.MustCompile(`^(\d+)\s*([a-z]+)$`)
+	cadenceUnits   = map[string]CadenceUnit{
+		"d": Day, "day": Day, "days": Day,
+		"w": Week, "week": Week, "weeks": Week,
+		"m": Month, "month": Month, "months": Month,
+		"y": Year, "year": Year, "years": Year,
+	}
+	cadenceWords = map[string]Cadence{
+		"daily":     {1, Day},
+		"weekly":    {1, Week},
+		"biweekly":  {2, Week},
+		"monthly":   {1, Month},
+		"quarterly": {3, Month},
+		"yearly":    {1, Year},
+	}
+)
+
+// ParseCadence accepts the short form ("2w", "3m"), a spelled out form
+// ("2 weeks") or one of daily, weekly, biweekly, monthly, quarterly and
+// yearly. A quarter ("1q") is parsed as three months.
+func ParseCadence(s string) (Cadence, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if c, ok := cadenceWords[s]; ok {
+		return c, nil
+	}
+
+	match := cadencePattern.FindStringSubmatch(s)
+	if match == nil {
+		return Cadence{}, fmt.Errorf("%w: %q", ErrInvalidCadence, s)
+	}
+
+	count, err := strconv.Atoi(match[1])
+	if err != nil || count <= 0 {
+		return Cadence{}, fmt.Errorf("%w: %q", ErrInvalidCadence, s)
+	}
+
+	if match[2] == "q" || strings.HasPrefix(match[2], "quarter") {
+		return Cadence{Count: count * 3, Unit: Month}, nil
+	}
+
+	unit, ok := cadenceUnits[match[2]]
+	if !ok {
+		return Cadence{}, fmt.Errorf("%w: %q", ErrInvalidCadence, s)
+	}
+
+	return Cadence{Count: count, Unit: unit}, nil
+}
+
+func (c Cadence) IsZero() bool {
+	return c.Count == 0
+}
+
+func (c Cadence) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	return strconv.Itoa(c.Count) + string(c.Unit)
+}
+
+// Next returns the moment one cadence period after from.
+func (c Cadence) Next(from time.Time) time.Time {
+	switch c.Unit {
+	case Day:
+		return from.AddDate(0, 0, c.Count)
+	case Week:
+		return from.AddDate(0, 0, 7*c.Count)
+	case Month:
+		return from.AddDate(0, c.Count, 0)
+	case Year:
+		return from.AddDate(c.Count, 0, 0)
+	default:
+		return from
+	}
+}
+
+func (c Cadence) Value() (driver.Value, error) {
+	if c.IsZero() {
+		return nil, nil
+	}
+	return c.String(), nil
+}
+
+func (c *Cadence) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*c = Cadence{}
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidCadence, src)
+	}
+
+	parsed, err := ParseCadence(s)
+	if err != nil {
+		return err
+	}
+
+	*c = parsed
+	return nil
+}
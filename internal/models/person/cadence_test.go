@@ -0,0 +1,55 @@
+package person
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCadence(t *testing.T) {
+	tests := map[string]Cadence{
+		"2w":        {2, Week},
+		"2 weeks":   {2, Week},
+		"10d":       {10, Day},
+		"1 Month":   {1, Month},
+		"quarterly": {3, Month},
+		"2q":        {6, Month},
+		"1y":        {1, Year},
+	}
+
+	for input, expected := range tests {
+		c, err := ParseCadence(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, c, input)
+	}
+
+	for _, input := range []string{"", "0w", "w", "2 fortnights", "-1d"} {
+		_, err := ParseCadence(input)
+		assert.ErrorIs(t, err, ErrInvalidCadence, input)
+	}
+}
+
+func TestCadenceNext(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2024, time.February, 14, 10, 0, 0, 0, time.UTC), Cadence{2, Week}.Next(from))
+	assert.Equal(t, time.Date(2024, time.April, 30, 10, 0, 0, 0, time.UTC), Cadence{3, Month}.Next(from.AddDate(0, 0, -1)))
+	assert.Equal(t, time.Date(2025, time.January, 31, 10, 0, 0, 0, time.UTC), Cadence{1, Year}.Next(from))
+}
+
+func TestCadenceScanValue(t *testing.T) {
+	v, err := Cadence{3, Month}.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "3m", v)
+
+	v, err = Cadence{}.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	var c Cadence
+	assert.NoError(t, c.Scan([]byte("2w")))
+	assert.Equal(t, Cadence{2, Week}, c)
+	assert.NoError(t, c.Scan(nil))
+	assert.True(t, c.IsZero())
+}
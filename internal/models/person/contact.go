@@ -0,0 +1,179 @@
+package person
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type ContactMethod string
+
+const (
+	Phone    ContactMethod = "phone"
+	Email    ContactMethod = "email"
+	Telegram ContactMethod = "telegram"
+	WhatsApp ContactMethod = "whatsapp"
+	URL      ContactMethod = "url"
+	Address  ContactMethod = "address"
+	Other    ContactMethod = "other"
+)
+
+var ContactMethods = []ContactMethod{Phone, Email, Telegram, WhatsApp, URL, Address, Other}
+
+var (
+	ErrUnknownContactMethod    = errors.New("unknown contact method")
+	ErrInvalidContactData      = errors.New("invalid contact data")
+	ErrMultiplePrimaryContacts = errors.New("more than one primary contact for a method")
+)
+
+// contactMethodAliases mirrors the mapping used by the typed contact methods
+// migration, so that free-form input is understood the same way as old rows.
+var contactMethodAliases = map[string]ContactMethod{
+	"phone":    Phone,
+	"tel":      Phone,
+	"mobile":   Phone,
+	"cell":     Phone,
+	"телефон":  Phone,
+	"email":    Email,
+	"e-mail":   Email,
+	"mail":     Email,
+	"почта":    Email,
+	"telegram": Telegram,
+	"tg":       Telegram,
+	"телеграм": Telegram,
+	"whatsapp": WhatsApp,
+	"wa":       WhatsApp,
+	"url":      URL,
+	"link":     URL,
+	"site":     URL,
+	"website":  URL,
+	"web":      URL,
+	"address":  Address,
+	"addr":     Address,
+	"адрес":    Address,
+	"other":    Other,
+}
+
+// ParseContactMethod resolves a method name or one of its common aliases
+// case-insensitively.
+func ParseContactMethod(s string) (ContactMethod, error) {
+	m, ok := contactMethodAliases[strings.ToLower(strings.TrimSpace(s))]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownContactMethod, s)
+	}
+	return m, nil
+}
+
+var (
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "", " ", "")
+	e164            = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	telegramHandle  = regexp.MustCompile(`^[a-z0-9_]{5,32}$`)
+	telegramPrefix  = regexp.MustCompile(`^(?i)(https?://)?(www\.)?(t\.me|telegram\.me)/`)
+	whitespace      = regexp.MustCompile(`\s+`)
+)
+
+// Normalize validates the contact data for its method and returns the
+// contact in canonical form: E.164 phone numbers, lower-cased emails and
+// Telegram handles without the leading @.
+func (c ContactInfo) Normalize() (ContactInfo, error) {
+	data := strings.TrimSpace(c.Data)
+
+	var err error
+	switch c.Method {
+	case Phone, WhatsApp:
+		data, err = normalizePhone(data)
+	case Email:
+		data, err = normalizeEmail(data)
+	case Telegram:
+		data, err = normalizeTelegram(data)
+	case URL:
+		data, err = normalizeURL(data)
+	case Address, Other:
+		data = whitespace.ReplaceAllString(data, " ")
+		if data == "" {
+			err = fmt.Errorf("%w: %s must not be empty", ErrInvalidContactData, c.Method)
+		}
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnknownContactMethod, c.Method)
+	}
+	if err != nil {
+		return c, err
+	}
+
+	c.Data = data
+	return c, nil
+}
+
+func normalizePhone(s string) (string, error) {
+	phone := phoneSeparators.Replace(s)
+	if strings.HasPrefix(phone, "00") {
+		phone = "+" + phone[2:]
+	}
+
+	if !e164.MatchString(phone) {
+		return "", fmt.Errorf("%w: %q is not an international phone number", ErrInvalidContactData, s)
+	}
+
+	return phone, nil
+}
+
+func normalizeEmail(s string) (string, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return "", fmt.Errorf("%w: %q is not an email address", ErrInvalidContactData, s)
+	}
+
+	return strings.ToLower(addr.Address), nil
+}
+
+func normalizeTelegram(s string) (string, error) {
+	handle := telegramPrefix.ReplaceAllString(s, "")
+	handle = strings.ToLower(strings.TrimPrefix(handle, "@"))
+
+	if !telegramHandle.MatchString(handle) {
+		return "", fmt.Errorf("%w: %q is not a Telegram username", ErrInvalidContactData, s)
+	}
+
+	return handle, nil
+}
+
+func normalizeURL(s string) (string, error) {
+	raw := s
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || strings.ContainsAny(u.Host, " ") {
+		return "", fmt.Errorf("%w: %q is not a URL", ErrInvalidContactData, s)
+	}
+
+	return u.String(), nil
+}
+
+// NormalizeContactInfos normalizes every contact in place and makes sure that
+// at most one contact per method is marked as primary.
+func NormalizeContactInfos(cs []ContactInfo) error {
+	primary := make(map[ContactMethod]bool)
+
+	for i, c := range cs {
+		nc, err := c.Normalize()
+		if err != nil {
+			return err
+		}
+
+		if nc.Primary {
+			if primary[nc.Method] {
+				return fmt.Errorf("%w: %s", ErrMultiplePrimaryContacts, nc.Method)
+			}
+			primary[nc.Method] = true
+		}
+
+		cs[i] = nc
+	}
+
+	return nil
+}
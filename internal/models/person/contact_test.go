@@ -0,0 +1,91 @@
+package person
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseContactMethod(t *testing.T) {
+	tests := map[string]ContactMethod{
+		"phone":    Phone,
+		" Tel ":    Phone,
+		"E-mail":   Email,
+		"tg":       Telegram,
+		"Telegram": Telegram,
+		"WA":       WhatsApp,
+		"website":  URL,
+		"Адрес":    Address,
+		"other":    Other,
+	}
+
+	for input, expected := range tests {
+		m, err := ParseContactMethod(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, m, input)
+	}
+
+	_, err := ParseContactMethod("pigeon")
+	assert.ErrorIs(t, err, ErrUnknownContactMethod)
+}
+
+func TestContactInfoNormalize(t *testing.T) {
+	tests := []struct {
+		method   ContactMethod
+		data     string
+		expected string
+	}{
+		{Phone, "+7 (955) 123-45-67", "+79551234567"},
+		{Phone, "0044 20 7946 0958", "+442079460958"},
+		{WhatsApp, "+1.415.555.2671", "+14155552671"},
+		{Email, " John.Smith@Example.COM ", "john.smith@example.com"},
+		{Telegram, "@PavelDurov", "paveldurov"},
+		{Telegram, "https://t.me/durov_bot", "durov_bot"},
+		{URL, "example.com/about", "https://example.com/about"},
+		{URL, "http://example.com", "http://example.com"},
+		{Address, "  221B   Baker\tStreet ", "221B Baker Street"},
+		{Other, "skype: john", "skype: john"},
+	}
+
+	for _, tt := range tests {
+		c, err := ContactInfo{Method: tt.method, Data: tt.data}.Normalize()
+		if assert.NoError(t, err, tt.data) {
+			assert.Equal(t, tt.expected, c.Data, tt.data)
+		}
+	}
+}
+
+func TestContactInfoNormalizeInvalid(t *testing.T) {
+	tests := []ContactInfo{
+		{Method: Phone, Data: "8 955 123 45 67"},
+		{Method: Phone, Data: "+12"},
+		{Method: Email, Data: "John <john@example.com>"},
+		{Method: Email, Data: "john"},
+		{Method: Telegram, Data: "@abc"},
+		{Method: Telegram, Data: "john smith"},
+		{Method: URL, Data: "://"},
+		{Method: Address, Data: "   "},
+	}
+
+	for _, c := range tests {
+		_, err := c.Normalize()
+		assert.ErrorIs(t, err, ErrInvalidContactData, c.Data)
+	}
+
+	_, err := ContactInfo{Method: "fax", Data: "123"}.Normalize()
+	assert.ErrorIs(t, err, ErrUnknownContactMethod)
+}
+
+func TestNormalizeContactInfos(t *testing.T) {
+	cs := []ContactInfo{
+		{Method: Phone, Data: "+7 955 123 45 67", Primary: true},
+		{Method: Phone, Data: "+44 20 7946 0958"},
+		{Method: Email, Data: "A@B.CO", Primary: true},
+	}
+	assert.NoError(t, NormalizeContactInfos(cs))
+	assert.Equal(t, "+79551234567", cs[0].Data)
+	assert.Equal(t, "a@b.co", cs[2].Data)
+
+	cs[1].Primary = true
+	assert.ErrorIs(t, NormalizeContactInfos(cs), ErrMultiplePrimaryContacts)
+}
@@ -2,22 +2,43 @@ package person
 
 import (
 	"database/sql"
+	"slices"
+	"strings"
 )
 
 type Person struct {
-	BirthDate    sql.NullTime
-	FirstName    string
-	LastName     sql.NullString
-	SecondName   sql.NullString
-	ContactInfos []ContactInfo
-	JobInfos     []JobInfo
-	Settings     Settings
-	ID           int
+	BirthDate sql.NullTime
+	// LastContactedAt is the last time an interaction with the person was
+	// logged. It is maintained separately from the rest of the record.
+	LastContactedAt sql.NullTime
+	FirstName       string
+	LastName        sql.NullString
+	SecondName      sql.NullString
+	ContactInfos    []ContactInfo
+	JobInfos        []JobInfo
+	Settings        Settings
+	// VCardExtra holds the vCard properties the person was imported with
+	// that have no field of their own, one content line each, so that
+	// exporting the person again keeps them.
+	VCardExtra string
+	// OwnerID is the user the person belongs to. It is set by the
+	// repository from the context.
+	OwnerID int
+	ID      int
+}
+
+func (p *Person) FullName() string {
+	name := p.FirstName
+	if p.LastName.Valid && p.LastName.String != "" {
+		name += " " + p.LastName.String
+	}
+	return strings.TrimSpace(name)
 }
 
 type ContactInfo struct {
-	Method string
-	Data   string
+	Method  ContactMethod
+	Data    string
+	Primary bool
 }
 
 type JobInfo struct {
@@ -28,4 +49,78 @@ type JobInfo struct {
 
 type Settings struct {
 	BirthdayNotify bool
+	// BirthdayNotifyDaysBefore adds a heads-up notification the given number
+	// of days before the birthday. Zero disables the heads-up.
+	BirthdayNotifyDaysBefore int
+	// KeepInTouch is how often the person should be reminded about. The zero
+	// cadence disables keep-in-touch reminders.
+	KeepInTouch Cadence
+}
+
+// Merge fills in what p is missing from other: the last and second name and
+// the birth date when p has none, and the contacts, jobs and vCard
+// properties p doesn't have yet. The primary contacts of p stay primary.
+func (p *Person) Merge(other *Person) {
+	if !p.LastName.Valid {
+		p.LastName = other.LastName
+	}
+	if !p.SecondName.Valid {
+		p.SecondName = other.SecondName
+	}
+	if !p.BirthDate.Valid {
+		p.BirthDate = other.BirthDate
+	}
+
+	primary := make(map[ContactMethod]bool)
+	for _, c := range p.ContactInfos {
+		primary[c.Method] = primary[c.Method] || c.Primary
+	}
+	for _, c := range other.ContactInfos {
+		if p.hasContact(c) {
+			continue
+		}
+		c.Primary = c.Primary && !primary[c.Method]
+		primary[c.Method] = primary[c.Method] || c.Primary
+		p.ContactInfos = append(p.ContactInfos, c)
+	}
+
+	for _, j := range other.JobInfos {
+		if !p.hasJob(j) {
+			p.JobInfos = append(p.JobInfos, j)
+		}
+	}
+
+	for _, line := range strings.Split(other.VCardExtra, "\n") {
+		if line == "" || slices.Contains(strings.Split(p.VCardExtra, "\n"), line) {
+			continue
+		}
+		if p.VCardExtra != "" {
+			p.VCardExtra += "\n"
+		}
+		p.VCardExtra += line
+	}
+}
+
+// SharesContact reports whether p has the contact data, phone and WhatsApp
+// numbers counting as the same.
+func (p *Person) SharesContact(c ContactInfo) bool {
+	phone := func(m ContactMethod) bool {
+		return m == Phone || m == WhatsApp
+	}
+
+	return slices.ContainsFunc(p.ContactInfos, func(pc ContactInfo) bool {
+		return pc.Data == c.Data && (pc.Method == c.Method || phone(pc.Method) && phone(c.Method))
+	})
+}
+
+func (p *Person) hasContact(c ContactInfo) bool {
+	return slices.ContainsFunc(p.ContactInfos, func(pc ContactInfo) bool {
+		return pc.Method == c.Method && strings.EqualFold(pc.Data, c.Data)
+	})
+}
+
+func (p *Person) hasJob(j JobInfo) bool {
+	return slices.ContainsFunc(p.JobInfos, func(pj JobInfo) bool {
+		return strings.EqualFold(pj.Company, j.Company) && strings.EqualFold(pj.Position, j.Position)
+	})
 }
@@ -0,0 +1,59 @@
+package person
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonMerge(t *testing.T) {
+	birthDate := sql.NullTime{Time: date(1990, time.May, 17), Valid: true}
+
+	p := &Person{
+		FirstName: "Anna",
+		ContactInfos: []ContactInfo{
+			{Method: Phone, Data: "+79991234567", Primary: true},
+		},
+		JobInfos:   []JobInfo{{Company: "Acme", Position: "CEO", Current: true}},
+		VCardExtra: "NICKNAME:Annie",
+	}
+	other := &Person{
+		FirstName: "Anya",
+		LastName:  sql.NullString{String: "Shchukina", Valid: true},
+		BirthDate: birthDate,
+		ContactInfos: []ContactInfo{
+			{Method: Phone, Data: "+79991234567"},
+			{Method: Phone, Data: "+79990000000", Primary: true},
+			{Method: Email, Data: "anna@example.com", Primary: true},
+		},
+		JobInfos:   []JobInfo{{Company: "ACME", Position: "ceo", Current: true}, {Company: "Initech"}},
+		VCardExtra: "NICKNAME:Annie\nNOTE:Met in Kazan",
+	}
+
+	p.Merge(other)
+
+	assert.Equal(t, "Anna", p.FirstName)
+	assert.Equal(t, "Shchukina", p.LastName.String)
+	assert.Equal(t, birthDate, p.BirthDate)
+	assert.Equal(t, []ContactInfo{
+		{Method: Phone, Data: "+79991234567", Primary: true},
+		{Method: Phone, Data: "+79990000000"},
+		{Method: Email, Data: "anna@example.com", Primary: true},
+	}, p.ContactInfos)
+	assert.Equal(t, []JobInfo{{Company: "Acme", Position: "CEO", Current: true}, {Company: "Initech"}}, p.JobInfos)
+	assert.Equal(t, "NICKNAME:Annie\nNOTE:Met in Kazan", p.VCardExtra)
+}
+
+func TestSharesContact(t *testing.T) {
+	p := &Person{ContactInfos: []ContactInfo{
+		{Method: WhatsApp, Data: "+79161234567"},
+		{Method: Email, Data: "anna@example.com"},
+	}}
+
+	assert.True(t, p.SharesContact(ContactInfo{Method: Phone, Data: "+79161234567"}))
+	assert.True(t, p.SharesContact(ContactInfo{Method: Email, Data: "anna@example.com"}))
+	assert.False(t, p.SharesContact(ContactInfo{Method: Telegram, Data: "anna@example.com"}))
+	assert.False(t, p.SharesContact(ContactInfo{Method: Phone, Data: "+79990000000"}))
+}
@@ -4,7 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/lib/pq"
+	"github.com/lincentpega/personal-crm/internal/common/owner"
 	"github.com/lincentpega/personal-crm/internal/common/txcontext"
 	"github.com/lincentpega/personal-crm/internal/models"
 )
@@ -52,32 +57,453 @@ func (m *PersonRepository) Get(ctx context.Context, id int) (*Person, error) {
 	return &p, nil
 }
 
+// GetMany returns the persons with the given ids, in no particular order,
+// with their contact infos, job infos and settings loaded in a few queries.
+// Ids of persons not found are skipped.
+func (m *PersonRepository) GetMany(ctx context.Context, ids []int) ([]Person, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	personIDs := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		personIDs[i] = int64(id)
+	}
+
+	const stmt = `SELECT id, owner_id, first_name, last_name, second_name, birth_date, last_contacted_at, vcard_extra
+        FROM persons
+        WHERE id = ANY($1) AND owner_id = $2`
+
+	rows, err := m.getDB(ctx).QueryContext(ctx, stmt, personIDs, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ps []Person
+	for rows.Next() {
+		var p Person
+		if err := rows.Scan(&p.ID, &p.OwnerID, &p.FirstName, &p.LastName, &p.SecondName, &p.BirthDate, &p.LastContactedAt, &p.VCardExtra); err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]*Person, len(ps))
+	for i := range ps {
+		byID[ps[i].ID] = &ps[i]
+	}
+
+	if err := m.fetchManyDetails(ctx, personIDs, byID); err != nil {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
 func (m *PersonRepository) Insert(ctx context.Context, p *Person) error {
-	if err := m.insertPerson(ctx, p); err != nil {
+	return txcontext.Run(ctx, m.db, func(ctx context.Context) error {
+		if err := m.insertPerson(ctx, p); err != nil {
+			return err
+		}
+
+		if err := m.insertContactInfos(ctx, p); err != nil {
+			return err
+		}
+
+		if err := m.insertJobInfos(ctx, p); err != nil {
+			return err
+		}
+
+		if err := m.insertSettings(ctx, p); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Update overwrites the person row and replaces all of its contact infos,
+// job infos and settings with the ones carried by p.
+func (m *PersonRepository) Update(ctx context.Context, p *Person) error {
+	return txcontext.Run(ctx, m.db, func(ctx context.Context) error {
+		if err := m.updatePerson(ctx, p); err != nil {
+			return err
+		}
+
+		if err := m.deleteContactInfos(ctx, p.ID); err != nil {
+			return err
+		}
+
+		if err := m.insertContactInfos(ctx, p); err != nil {
+			return err
+		}
+
+		if err := m.deleteJobInfos(ctx, p.ID); err != nil {
+			return err
+		}
+
+		if err := m.insertJobInfos(ctx, p); err != nil {
+			return err
+		}
+
+		if err := m.upsertSettings(ctx, p); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Restore inserts a person deleted earlier back under its old id, with the
+// contact infos, job infos and settings carried by p.
+func (m *PersonRepository) Restore(ctx context.Context, p *Person) error {
+	return txcontext.Run(ctx, m.db, func(ctx context.Context) error {
+		if err := m.restorePerson(ctx, p); err != nil {
+			return err
+		}
+
+		if err := m.insertContactInfos(ctx, p); err != nil {
+			return err
+		}
+
+		if err := m.insertJobInfos(ctx, p); err != nil {
+			return err
+		}
+
+		return m.insertSettings(ctx, p)
+	})
+}
+
+// Delete removes the person. Contact infos, job infos, settings and
+// notifications are removed by the database through ON DELETE CASCADE.
+func (m *PersonRepository) Delete(ctx context.Context, id int) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
 		return err
 	}
 
-	if err := m.insertContactInfos(ctx, p); err != nil {
+	const stmt = `DELETE FROM persons WHERE id = $1 AND owner_id = $2`
+
+	res, err := m.getDB(ctx).ExecContext(ctx, stmt, id, ownerID)
+	if err != nil {
 		return err
 	}
 
-	if err := m.insertJobInfos(ctx, p); err != nil {
+	affected, err := res.RowsAffected()
+	if err != nil {
 		return err
 	}
 
-	if err := m.insertSettings(ctx, p); err != nil {
+	if affected == 0 {
+		return models.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+type SortField string
+
+const (
+	SortByName      SortField = "name"
+	SortByBirthDate SortField = "birth_date"
+)
+
+var sortColumns = map[SortField]string{
+	SortByName:      "first_name %[1]s, last_name %[1]s NULLS LAST",
+	SortByBirthDate: "birth_date %[1]s NULLS LAST",
+}
+
+const defaultPageSize = 20
+
+type ListOptions struct {
+	Sort SortField
+	Desc bool
+	// Page is 1-based. Values below 1 are treated as the first page.
+	Page     int
+	PageSize int
+	// Details populates contact infos, job infos and settings of the
+	// listed persons.
+	Details bool
+}
+
+func (o ListOptions) orderBy() string {
+	column, ok := sortColumns[o.Sort]
+	if !ok {
+		column = sortColumns[SortByName]
+	}
+
+	direction := "ASC"
+	if o.Desc {
+		direction = "DESC"
+	}
+
+	return fmt.Sprintf(column, direction) + ", id"
+}
+
+func (o ListOptions) limitOffset() (int, int) {
+	size := o.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+
+	page := o.Page
+	if page < 1 {
+		page = 1
+	}
+
+	return size, (page - 1) * size
+}
+
+// List returns one page of persons together with the total number of
+// persons. Only the persons rows are loaded; use Get for the full record.
+func (m *PersonRepository) List(ctx context.Context, opts ListOptions) ([]Person, int, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	const countStmt = `SELECT count(*) FROM persons WHERE owner_id = $1`
+
+	var total int
+	if err := m.getDB(ctx).QueryRowContext(ctx, countStmt, ownerID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT id, owner_id, first_name, last_name, second_name, birth_date, last_contacted_at, vcard_extra
+        FROM persons
+        WHERE owner_id = $1
+        ORDER BY ` + opts.orderBy() + `
+        LIMIT $2 OFFSET $3`
+
+	limit, offset := opts.limitOffset()
+
+	rows, err := m.getDB(ctx).QueryContext(ctx, stmt, ownerID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var ps []Person
+	for rows.Next() {
+		var p Person
+		if err := rows.Scan(&p.ID, &p.OwnerID, &p.FirstName, &p.LastName, &p.SecondName, &p.BirthDate, &p.LastContactedAt, &p.VCardExtra); err != nil {
+			return nil, 0, err
+		}
+		ps = append(ps, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if opts.Details {
+		for i := range ps {
+			if err := m.fetchDetails(ctx, &ps[i]); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	return ps, total, nil
+}
+
+// searchThreshold is the minimum rank of a search result. Ranks are
+// pg_trgm word similarities, with 1 for a plain substring match.
+const searchThreshold = 0.3
+
+type SearchResult struct {
+	Person Person
+	Rank   float64
+}
+
+// Search finds persons whose names, contact data or jobs resemble the query,
+// best matches first. Matching tolerates typos, accents and Cyrillic/Latin
+// transliteration. Contact infos, job infos and settings of the results are
+// populated.
+func (m *PersonRepository) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+
+	if limit < 1 {
+		limit = defaultPageSize
+	}
+
+	const stmt = `WITH q AS (SELECT crm_fold($1) AS term)
+        SELECT p.id, p.owner_id, p.first_name, p.last_name, p.second_name, p.birth_date, p.last_contacted_at, m.rank
+        FROM persons p
+        CROSS JOIN q
+        CROSS JOIN LATERAL (
+            SELECT MAX(GREATEST(word_similarity(q.term, f.term), CASE WHEN strpos(f.term, q.term) > 0 THEN 1 ELSE 0 END)) AS rank
+            FROM (
+                SELECT crm_fold(concat_ws(' ', p.first_name, p.last_name, p.second_name)) AS term
+                UNION ALL
+                SELECT crm_fold(c.contact_data) FROM contact_infos c WHERE c.person_id = p.id
+                UNION ALL
+                SELECT crm_fold(concat_ws(' ', j.company, j.position)) FROM job_infos j WHERE j.person_id = p.id
+            ) f
+        ) m
+        WHERE p.owner_id = $4 AND m.rank >= $2
+        ORDER BY m.rank DESC, p.id
+        LIMIT $3`
+
+	rows, err := m.getDB(ctx).QueryContext(ctx, stmt, query, searchThreshold, limit, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		p := &r.Person
+		if err := rows.Scan(&p.ID, &p.OwnerID, &p.FirstName, &p.LastName, &p.SecondName, &p.BirthDate, &p.LastContactedAt, &r.Rank); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		if err := m.fetchDetails(ctx, &results[i].Person); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// ListBirthdayNotify returns every person of every owner with a known birth
+// date who opted in to birthday notifications. It is meant for the
+// scheduler. Settings are populated, child rows are not.
+func (m *PersonRepository) ListBirthdayNotify(ctx context.Context) ([]Person, error) {
+	const stmt = `SELECT p.id, p.owner_id, p.first_name, p.last_name, p.second_name, p.birth_date,
+            s.birthday_notify, s.birthday_notify_days_before
+        FROM persons p
+        JOIN person_settings s ON s.person_id = p.id
+        WHERE s.birthday_notify AND p.birth_date IS NOT NULL
+        ORDER BY p.id`
+
+	rows, err := m.getDB(ctx).QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ps []Person
+	for rows.Next() {
+		var p Person
+		err := rows.Scan(&p.ID, &p.OwnerID, &p.FirstName, &p.LastName, &p.SecondName, &p.BirthDate,
+			&p.Settings.BirthdayNotify, &p.Settings.BirthdayNotifyDaysBefore)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+
+	return ps, rows.Err()
+}
+
+// ListKeepInTouch returns every person of every owner with a keep-in-touch
+// cadence. It is meant for the scheduler. Settings and LastContactedAt are
+// populated, child rows are not.
+func (m *PersonRepository) ListKeepInTouch(ctx context.Context) ([]Person, error) {
+	const stmt = `SELECT p.id, p.owner_id, p.first_name, p.last_name, p.second_name, p.birth_date, p.last_contacted_at,
+            s.birthday_notify, s.birthday_notify_days_before, s.keep_in_touch_cadence
+        FROM persons p
+        JOIN person_settings s ON s.person_id = p.id
+        WHERE s.keep_in_touch_cadence IS NOT NULL
+        ORDER BY p.id`
+
+	rows, err := m.getDB(ctx).QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ps []Person
+	for rows.Next() {
+		var p Person
+		err := rows.Scan(&p.ID, &p.OwnerID, &p.FirstName, &p.LastName, &p.SecondName, &p.BirthDate, &p.LastContactedAt,
+			&p.Settings.BirthdayNotify, &p.Settings.BirthdayNotifyDaysBefore, &p.Settings.KeepInTouch)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+
+	return ps, rows.Err()
+}
+
+// UpdateSettings replaces the settings of the person without touching the
+// rest of the record.
+func (m *PersonRepository) UpdateSettings(ctx context.Context, id int, settings Settings) error {
+	if _, err := m.Get(ctx, id); err != nil {
+		return err
+	}
+
+	return m.upsertSettings(ctx, &Person{ID: id, Settings: settings})
+}
+
+// TouchLastContacted records a contact with the person at the given time.
+// An earlier time than the one already stored is ignored.
+func (m *PersonRepository) TouchLastContacted(ctx context.Context, id int, at time.Time) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `UPDATE persons
+        SET last_contacted_at = GREATEST(last_contacted_at, $1)
+        WHERE id = $2 AND owner_id = $3`
+
+	res, err := m.getDB(ctx).ExecContext(ctx, stmt, at, id, ownerID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
 		return err
 	}
 
+	if affected == 0 {
+		return models.ErrRecordNotFound
+	}
+
 	return nil
 }
 
 func (m *PersonRepository) fetchPerson(ctx context.Context, id int, p *Person) error {
-	const stmt = `SELECT id, first_name, last_name, second_name, birth_date 
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `SELECT id, owner_id, first_name, last_name, second_name, birth_date, last_contacted_at, vcard_extra
         FROM persons 
-        WHERE id = $1`
+        WHERE id = $1 AND owner_id = $2`
 
-	err := m.getDB(ctx).QueryRowContext(ctx, stmt, id).Scan(&p.ID, &p.FirstName, &p.LastName, &p.SecondName, &p.BirthDate)
+	err = m.getDB(ctx).QueryRowContext(ctx, stmt, id, ownerID).Scan(&p.ID, &p.OwnerID, &p.FirstName, &p.LastName, &p.SecondName, &p.BirthDate, &p.LastContactedAt, &p.VCardExtra)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.ErrRecordNotFound
@@ -88,8 +514,117 @@ func (m *PersonRepository) fetchPerson(ctx context.Context, id int, p *Person) e
 	return nil
 }
 
+// fetchDetails populates the contact infos, job infos and settings of a
+// person fetched without them.
+func (m *PersonRepository) fetchDetails(ctx context.Context, p *Person) error {
+	if err := m.fetchContactInfos(ctx, p.ID, p); err != nil {
+		return err
+	}
+
+	if err := m.fetchJobInfos(ctx, p.ID, p); err != nil {
+		return err
+	}
+
+	return m.fetchPersonSettings(ctx, p.ID, p)
+}
+
+// fetchManyDetails populates the contact infos, job infos and settings of
+// the persons by id, one query for each.
+func (m *PersonRepository) fetchManyDetails(ctx context.Context, ids pq.Int64Array, persons map[int]*Person) error {
+	if err := m.fetchManyContactInfos(ctx, ids, persons); err != nil {
+		return err
+	}
+
+	if err := m.fetchManyJobInfos(ctx, ids, persons); err != nil {
+		return err
+	}
+
+	return m.fetchManyPersonSettings(ctx, ids, persons)
+}
+
+func (m *PersonRepository) fetchManyContactInfos(ctx context.Context, ids pq.Int64Array, persons map[int]*Person) error {
+	const stmt = `SELECT person_id, method, contact_data, is_primary
+        FROM contact_infos
+        WHERE person_id = ANY($1)`
+
+	rows, err := m.getDB(ctx).QueryContext(ctx, stmt, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			personID int
+			c        ContactInfo
+		)
+		if err := rows.Scan(&personID, &c.Method, &c.Data, &c.Primary); err != nil {
+			return err
+		}
+		if p, ok := persons[personID]; ok {
+			p.ContactInfos = append(p.ContactInfos, c)
+		}
+	}
+
+	return rows.Err()
+}
+
+func (m *PersonRepository) fetchManyJobInfos(ctx context.Context, ids pq.Int64Array, persons map[int]*Person) error {
+	const stmt = `SELECT person_id, company, position, current
+        FROM job_infos
+        WHERE person_id = ANY($1)`
+
+	rows, err := m.getDB(ctx).QueryContext(ctx, stmt, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			personID int
+			j        JobInfo
+		)
+		if err := rows.Scan(&personID, &j.Company, &j.Position, &j.Current); err != nil {
+			return err
+		}
+		if p, ok := persons[personID]; ok {
+			p.JobInfos = append(p.JobInfos, j)
+		}
+	}
+
+	return rows.Err()
+}
+
+func (m *PersonRepository) fetchManyPersonSettings(ctx context.Context, ids pq.Int64Array, persons map[int]*Person) error {
+	const stmt = `SELECT person_id, birthday_notify, birthday_notify_days_before, keep_in_touch_cadence
+        FROM person_settings
+        WHERE person_id = ANY($1)`
+
+	rows, err := m.getDB(ctx).QueryContext(ctx, stmt, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			personID int
+			settings Settings
+		)
+		if err := rows.Scan(&personID, &settings.BirthdayNotify, &settings.BirthdayNotifyDaysBefore, &settings.KeepInTouch); err != nil {
+			return err
+		}
+		if p, ok := persons[personID]; ok {
+			p.Settings = settings
+		}
+	}
+
+	return rows.Err()
+}
+
 func (m *PersonRepository) fetchContactInfos(ctx context.Context, id int, p *Person) error {
-	const stmt = `SELECT method_name, contact_data 
+	const stmt = `SELECT method, contact_data, is_primary
         FROM contact_infos 
         WHERE person_id = $1`
 
@@ -101,7 +636,7 @@ func (m *PersonRepository) fetchContactInfos(ctx context.Context, id int, p *Per
 
 	for rows.Next() {
 		var c ContactInfo
-		if err := rows.Scan(&c.Method, &c.Data); err != nil {
+		if err := rows.Scan(&c.Method, &c.Data, &c.Primary); err != nil {
 			return err
 		}
 		p.ContactInfos = append(p.ContactInfos, c)
@@ -133,11 +668,11 @@ func (m *PersonRepository) fetchJobInfos(ctx context.Context, id int, p *Person)
 }
 
 func (m *PersonRepository) fetchPersonSettings(ctx context.Context, id int, p *Person) error {
-	const stmt = `SELECT birthday_notify
+	const stmt = `SELECT birthday_notify, birthday_notify_days_before, keep_in_touch_cadence
         FROM person_settings
         WHERE person_id = $1`
 
-	err := m.getDB(ctx).QueryRowContext(ctx, stmt, id).Scan(&p.Settings.BirthdayNotify)
+	err := m.getDB(ctx).QueryRowContext(ctx, stmt, id).Scan(&p.Settings.BirthdayNotify, &p.Settings.BirthdayNotifyDaysBefore, &p.Settings.KeepInTouch)
 	if err != nil {
 		return err
 	}
@@ -146,20 +681,81 @@ func (m *PersonRepository) fetchPersonSettings(ctx context.Context, id int, p *P
 }
 
 func (m *PersonRepository) insertPerson(ctx context.Context, p *Person) error {
-	const stmt = `INSERT INTO persons (first_name, last_name, second_name, birth_date)
-        VALUES($1, $2, $3, $4) RETURNING id`
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `INSERT INTO persons (owner_id, first_name, last_name, second_name, birth_date, vcard_extra)
+        VALUES($1, $2, $3, $4, $5, $6) RETURNING id, owner_id`
+
+	return m.getDB(ctx).QueryRowContext(ctx, stmt, ownerID, p.FirstName, p.LastName, p.SecondName, p.BirthDate, p.VCardExtra).Scan(&p.ID, &p.OwnerID)
+}
+
+func (m *PersonRepository) restorePerson(ctx context.Context, p *Person) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `INSERT INTO persons (id, owner_id, first_name, last_name, second_name, birth_date, last_contacted_at, vcard_extra)
+        VALUES($1, $2, $3, $4, $5, $6, $7, $8) RETURNING owner_id`
+
+	return m.getDB(ctx).QueryRowContext(ctx, stmt, p.ID, ownerID, p.FirstName, p.LastName, p.SecondName, p.BirthDate,
+		p.LastContactedAt, p.VCardExtra).Scan(&p.OwnerID)
+}
+
+func (m *PersonRepository) updatePerson(ctx context.Context, p *Person) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `UPDATE persons
+        SET first_name = $1, last_name = $2, second_name = $3, birth_date = $4, vcard_extra = $5
+        WHERE id = $6 AND owner_id = $7`
 
-	m.getDB(ctx).QueryRowContext(ctx, stmt, p.FirstName, p.LastName, p.SecondName, p.BirthDate).Scan(&p.ID)
+	res, err := m.getDB(ctx).ExecContext(ctx, stmt, p.FirstName, p.LastName, p.SecondName, p.BirthDate, p.VCardExtra, p.ID, ownerID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return models.ErrRecordNotFound
+	}
 
 	return nil
 }
 
+func (m *PersonRepository) deleteContactInfos(ctx context.Context, personID int) error {
+	const stmt = `DELETE FROM contact_infos WHERE person_id = $1`
+
+	_, err := m.getDB(ctx).ExecContext(ctx, stmt, personID)
+	return err
+}
+
+func (m *PersonRepository) deleteJobInfos(ctx context.Context, personID int) error {
+	const stmt = `DELETE FROM job_infos WHERE person_id = $1`
+
+	_, err := m.getDB(ctx).ExecContext(ctx, stmt, personID)
+	return err
+}
+
 func (m *PersonRepository) insertContactInfos(ctx context.Context, p *Person) error {
-	const stmt = `INSERT INTO contact_infos (person_id, method_name, contact_data) 
-        VALUES($1, $2, $3)`
+	const stmt = `INSERT INTO contact_infos (person_id, method, contact_data, is_primary) 
+        VALUES($1, $2, $3, $4)`
+
+	if err := NormalizeContactInfos(p.ContactInfos); err != nil {
+		return err
+	}
 
 	for _, c := range p.ContactInfos {
-		_, err := m.getDB(ctx).ExecContext(ctx, stmt, p.ID, c.Method, c.Data)
+		_, err := m.getDB(ctx).ExecContext(ctx, stmt, p.ID, c.Method, c.Data, c.Primary)
 		if err != nil {
 			return err
 		}
@@ -183,10 +779,26 @@ func (m *PersonRepository) insertJobInfos(ctx context.Context, p *Person) error
 }
 
 func (m *PersonRepository) insertSettings(ctx context.Context, p *Person) error {
-	const stmt = `INSERT INTO person_settings (person_id, birthday_notify)
-        VALUES($1, $2)`
+	const stmt = `INSERT INTO person_settings (person_id, birthday_notify, birthday_notify_days_before, keep_in_touch_cadence)
+        VALUES($1, $2, $3, $4)`
+
+	_, err := m.getDB(ctx).ExecContext(ctx, stmt, p.ID, p.Settings.BirthdayNotify, p.Settings.BirthdayNotifyDaysBefore, p.Settings.KeepInTouch)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *PersonRepository) upsertSettings(ctx context.Context, p *Person) error {
+	const stmt = `INSERT INTO person_settings (person_id, birthday_notify, birthday_notify_days_before, keep_in_touch_cadence)
+        VALUES($1, $2, $3, $4)
+        ON CONFLICT (person_id) DO UPDATE
+        SET birthday_notify = EXCLUDED.birthday_notify,
+            birthday_notify_days_before = EXCLUDED.birthday_notify_days_before,
+            keep_in_touch_cadence = EXCLUDED.keep_in_touch_cadence`
 
-	_, err := m.getDB(ctx).ExecContext(ctx, stmt, p.ID, p.Settings.BirthdayNotify)
+	_, err := m.getDB(ctx).ExecContext(ctx, stmt, p.ID, p.Settings.BirthdayNotify, p.Settings.BirthdayNotifyDaysBefore, p.Settings.KeepInTouch)
 	if err != nil {
 		return err
 	}
@@ -1,11 +1,15 @@
 package person
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 	"time"
 
+	"github.com/lincentpega/personal-crm/internal/common/owner"
 	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/users"
 	"github.com/lincentpega/personal-crm/internal/test"
 	"github.com/stretchr/testify/suite"
 )
@@ -15,16 +19,18 @@ const (
 	testLastName       = "Smith"
 	testSecondName     = "James"
 	testBirthDate      = "2002-07-19"
-	testMethodName     = "email"
+	testMethodName     = Email
 	testContactData    = "john.smith@example.com"
 	testCompany        = "Meta"
 	testPosition       = "Platform SE L3"
 	testCurrent        = true
 	testBirthdayNotify = true
-	testMethod1        = "telegram"
-	testMethod2        = "phone"
+	testMethod1        = Telegram
+	testMethod2        = Phone
 	testData1          = "@paveldurov"
-	testData2          = "+795554433"
+	testData1Norm      = "paveldurov"
+	testData2          = "+7 955 544-33"
+	testData2Norm      = "+795554433"
 	testCompany1       = "Meta"
 	testPosition1      = "Platform SE L3"
 	testCurrent1       = true
@@ -41,6 +47,7 @@ type personRepoTestSuite struct {
 
 func (suite *personRepoTestSuite) SetupSuite() {
 	suite.TestSuite.SetupSuite()
+	suite.Ctx = owner.WithID(suite.Ctx, users.DefaultID)
 
 	suite.repo = NewRepository(suite.DB)
 }
@@ -60,12 +67,12 @@ func (suite *personRepoTestSuite) TestGet() {
 	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
 
 	var personID int
-	stmt := `INSERT INTO persons (first_name, last_name, second_name, birth_date) 
-	VALUES ($1, $2, $3, $4) RETURNING id`
-	err := suite.tx.QueryRowContext(ctx, stmt, testFirstName, testLastName, testSecondName, testBirthDate).Scan(&personID)
+	stmt := `INSERT INTO persons (owner_id, first_name, last_name, second_name, birth_date) 
+	VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err := suite.tx.QueryRowContext(ctx, stmt, users.DefaultID, testFirstName, testLastName, testSecondName, testBirthDate).Scan(&personID)
 	suite.NoError(err)
 
-	stmt = `INSERT INTO contact_infos (person_id, method_name, contact_data) 
+	stmt = `INSERT INTO contact_infos (person_id, method, contact_data) 
 	VALUES ($1, $2, $3)`
 	_, err = suite.tx.ExecContext(ctx, stmt, personID, testMethodName, testContactData)
 	suite.NoError(err)
@@ -106,7 +113,7 @@ func (suite *personRepoTestSuite) TestInsert() {
 
 	birthDate := time.Date(2002, time.July, 19, 0, 0, 0, 0, time.UTC)
 
-	ci1 := ContactInfo{Method: testMethod1, Data: testData1}
+	ci1 := ContactInfo{Method: testMethod1, Data: testData1, Primary: true}
 	ci2 := ContactInfo{Method: testMethod2, Data: testData2}
 	contactInfos := []ContactInfo{ci1, ci2}
 
@@ -114,7 +121,11 @@ func (suite *personRepoTestSuite) TestInsert() {
 	ji2 := JobInfo{Company: testCompany2, Position: testPosition2, Current: testCurrent2}
 	jobInfos := []JobInfo{ji1, ji2}
 
-	settings := Settings{BirthdayNotify: testBirthdayNotify}
+	settings := Settings{
+		BirthdayNotify:           testBirthdayNotify,
+		BirthdayNotifyDaysBefore: 3,
+		KeepInTouch:              Cadence{Count: 2, Unit: Week},
+	}
 
 	person := Person{
 		FirstName:    testFirstName,
@@ -134,11 +145,360 @@ func (suite *personRepoTestSuite) TestInsert() {
 	suite.Equal(testLastName, insertedPerson.LastName.String)
 	suite.Equal(testSecondName, insertedPerson.SecondName.String)
 	suite.Equal(birthDate.UTC(), insertedPerson.BirthDate.Time.UTC())
-	suite.Equal(contactInfos, insertedPerson.ContactInfos)
+	suite.Equal([]ContactInfo{
+		{Method: testMethod1, Data: testData1Norm, Primary: true},
+		{Method: testMethod2, Data: testData2Norm},
+	}, insertedPerson.ContactInfos)
 	suite.Equal(jobInfos, insertedPerson.JobInfos)
 	suite.Equal(settings, insertedPerson.Settings)
 }
 
+func (suite *personRepoTestSuite) TestGetMany() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	anna := Person{
+		FirstName:    "Anna",
+		ContactInfos: []ContactInfo{{Method: Email, Data: "anna@example.com", Primary: true}},
+		JobInfos:     []JobInfo{{Company: testCompany1, Position: testPosition1, Current: true}},
+		Settings:     Settings{KeepInTouch: Cadence{Count: 2, Unit: Week}},
+	}
+	suite.Require().NoError(suite.repo.Insert(ctx, &anna))
+
+	boris := Person{FirstName: "Boris"}
+	suite.Require().NoError(suite.repo.Insert(ctx, &boris))
+
+	got, err := suite.repo.GetMany(ctx, []int{boris.ID, anna.ID, -1})
+	suite.Require().NoError(err)
+	suite.Require().Len(got, 2)
+
+	byID := map[int]Person{got[0].ID: got[0], got[1].ID: got[1]}
+	for _, want := range []Person{anna, boris} {
+		p, err := suite.repo.Get(ctx, want.ID)
+		suite.Require().NoError(err)
+		suite.Equal(*p, byID[want.ID])
+	}
+
+	got, err = suite.repo.GetMany(ctx, nil)
+	suite.NoError(err)
+	suite.Empty(got)
+}
+
+func (suite *personRepoTestSuite) TestInsertInvalidContact() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := Person{
+		FirstName:    testFirstName,
+		ContactInfos: []ContactInfo{{Method: Email, Data: "not an email"}},
+	}
+	suite.ErrorIs(suite.repo.Insert(ctx, &person), ErrInvalidContactData)
+
+	person = Person{
+		FirstName: testFirstName,
+		ContactInfos: []ContactInfo{
+			{Method: Phone, Data: testData2, Primary: true},
+			{Method: Phone, Data: "+441234567890", Primary: true},
+		},
+	}
+	suite.ErrorIs(suite.repo.Insert(ctx, &person), ErrMultiplePrimaryContacts)
+}
+
+func (suite *personRepoTestSuite) TestUpdate() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := Person{
+		FirstName:    testFirstName,
+		LastName:     sql.NullString{String: testLastName, Valid: true},
+		ContactInfos: []ContactInfo{{Method: testMethod1, Data: testData1}},
+		JobInfos:     []JobInfo{{Company: testCompany1, Position: testPosition1, Current: testCurrent1}},
+		Settings:     Settings{BirthdayNotify: false},
+	}
+	suite.Require().NoError(suite.repo.Insert(ctx, &person))
+
+	birthDate := time.Date(2002, time.July, 19, 0, 0, 0, 0, time.UTC)
+	contactInfos := []ContactInfo{{Method: testMethod2, Data: testData2Norm}}
+	jobInfos := []JobInfo{{Company: testCompany2, Position: testPosition2, Current: testCurrent2}}
+	settings := Settings{BirthdayNotify: true}
+
+	person.FirstName = "Jon"
+	person.SecondName = sql.NullString{String: testSecondName, Valid: true}
+	person.BirthDate = sql.NullTime{Time: birthDate, Valid: true}
+	person.ContactInfos = contactInfos
+	person.JobInfos = jobInfos
+	person.Settings = settings
+
+	suite.Require().NoError(suite.repo.Update(ctx, &person))
+
+	updatedPerson, err := suite.repo.Get(ctx, person.ID)
+	suite.Require().NoError(err)
+	suite.Equal("Jon", updatedPerson.FirstName)
+	suite.Equal(testLastName, updatedPerson.LastName.String)
+	suite.Equal(testSecondName, updatedPerson.SecondName.String)
+	suite.Equal(birthDate.UTC(), updatedPerson.BirthDate.Time.UTC())
+	suite.Equal(contactInfos, updatedPerson.ContactInfos)
+	suite.Equal(jobInfos, updatedPerson.JobInfos)
+	suite.Equal(settings, updatedPerson.Settings)
+}
+
+func (suite *personRepoTestSuite) TestUpdateNotFound() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	err := suite.repo.Update(ctx, &Person{ID: -1, FirstName: testFirstName})
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+}
+
+func (suite *personRepoTestSuite) TestDelete() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := Person{
+		FirstName:    testFirstName,
+		ContactInfos: []ContactInfo{{Method: testMethod1, Data: testData1}},
+		JobInfos:     []JobInfo{{Company: testCompany1, Position: testPosition1, Current: testCurrent1}},
+		Settings:     Settings{BirthdayNotify: testBirthdayNotify},
+	}
+	suite.Require().NoError(suite.repo.Insert(ctx, &person))
+
+	stmt := `INSERT INTO notifications (owner_id, person_id, type, status, notification_time) 
+	VALUES ($1, $2, 'keep_in_touch', 'pending', NOW())`
+	_, err := suite.tx.ExecContext(ctx, stmt, users.DefaultID, person.ID)
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(suite.repo.Delete(ctx, person.ID))
+
+	_, err = suite.repo.Get(ctx, person.ID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	for _, table := range []string{"contact_infos", "job_infos", "person_settings", "notifications"} {
+		var count int
+		err := suite.tx.QueryRowContext(ctx, "SELECT count(*) FROM "+table+" WHERE person_id = $1", person.ID).Scan(&count)
+		suite.Require().NoError(err)
+		suite.Zero(count, table)
+	}
+
+	suite.ErrorIs(suite.repo.Delete(ctx, person.ID), models.ErrRecordNotFound)
+}
+
+func (suite *personRepoTestSuite) TestList() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	persons := []Person{
+		{FirstName: "Anna", BirthDate: sql.NullTime{Time: time.Date(1990, time.May, 1, 0, 0, 0, 0, time.UTC), Valid: true}},
+		{FirstName: "Boris", BirthDate: sql.NullTime{Time: time.Date(1985, time.March, 2, 0, 0, 0, 0, time.UTC), Valid: true}},
+		{FirstName: "Clara"},
+	}
+	for i := range persons {
+		suite.Require().NoError(suite.repo.Insert(ctx, &persons[i]))
+	}
+
+	page, total, err := suite.repo.List(ctx, ListOptions{Sort: SortByName, Page: 1, PageSize: 2})
+	suite.Require().NoError(err)
+	suite.Equal(3, total)
+	suite.Require().Len(page, 2)
+	suite.Equal("Anna", page[0].FirstName)
+	suite.Equal("Boris", page[1].FirstName)
+
+	page, total, err = suite.repo.List(ctx, ListOptions{Sort: SortByName, Page: 2, PageSize: 2})
+	suite.Require().NoError(err)
+	suite.Equal(3, total)
+	suite.Require().Len(page, 1)
+	suite.Equal("Clara", page[0].FirstName)
+
+	page, _, err = suite.repo.List(ctx, ListOptions{Sort: SortByBirthDate})
+	suite.Require().NoError(err)
+	suite.Require().Len(page, 3)
+	suite.Equal("Boris", page[0].FirstName)
+	suite.Equal("Anna", page[1].FirstName)
+	suite.Equal("Clara", page[2].FirstName)
+
+	page, _, err = suite.repo.List(ctx, ListOptions{Sort: SortByBirthDate, Desc: true})
+	suite.Require().NoError(err)
+	suite.Require().Len(page, 3)
+	suite.Equal("Anna", page[0].FirstName)
+	suite.Equal("Boris", page[1].FirstName)
+	suite.Equal("Clara", page[2].FirstName)
+
+	suite.Empty(page[0].ContactInfos)
+
+	persons[0].ContactInfos = []ContactInfo{{Method: Email, Data: "anna@example.com", Primary: true}}
+	persons[0].Settings.KeepInTouch = Cadence{Count: 1, Unit: Month}
+	suite.Require().NoError(suite.repo.Update(ctx, &persons[0]))
+
+	page, _, err = suite.repo.List(ctx, ListOptions{Sort: SortByName, PageSize: 1, Details: true})
+	suite.Require().NoError(err)
+	suite.Require().Len(page, 1)
+	suite.Equal(persons[0].ContactInfos, page[0].ContactInfos)
+	suite.Equal(persons[0].Settings, page[0].Settings)
+}
+
+func (suite *personRepoTestSuite) TestSearch() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	anna := Person{
+		FirstName:    "Анна",
+		LastName:     sql.NullString{String: "Щукина", Valid: true},
+		ContactInfos: []ContactInfo{{Method: Telegram, Data: "@anna_sh"}},
+	}
+	boris := Person{
+		FirstName: "Boris",
+		LastName:  sql.NullString{String: "Müller", Valid: true},
+		JobInfos:  []JobInfo{{Company: "Yandex", Position: "Engineer", Current: true}},
+	}
+	for _, p := range []*Person{&anna, &boris} {
+		suite.Require().NoError(suite.repo.Insert(ctx, p))
+	}
+
+	searches := map[string]int{
+		"anna":        anna.ID,
+		"Shchukina":   anna.ID,
+		"Щукина":      anna.ID,
+		"shukina":     anna.ID,
+		"anna_sh":     anna.ID,
+		"muller":      boris.ID,
+		"Boriss":      boris.ID,
+		"yandex":      boris.ID,
+		"Яндекс":      boris.ID,
+		"engineer":    boris.ID,
+		"Борис Мюлер": boris.ID,
+	}
+
+	for query, expected := range searches {
+		results, err := suite.repo.Search(ctx, query, 10)
+		suite.Require().NoError(err, query)
+		suite.Require().NotEmpty(results, query)
+		suite.Equal(expected, results[0].Person.ID, query)
+	}
+
+	results, err := suite.repo.Search(ctx, "anna", 10)
+	suite.Require().NoError(err)
+	suite.Len(results[0].Person.ContactInfos, 1)
+	suite.Equal(1.0, results[0].Rank)
+
+	results, err = suite.repo.Search(ctx, "zzzzqqq", 10)
+	suite.Require().NoError(err)
+	suite.Empty(results)
+
+	results, err = suite.repo.Search(ctx, "  ", 10)
+	suite.Require().NoError(err)
+	suite.Empty(results)
+}
+
+func (suite *personRepoTestSuite) TestOwnerIsolation() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	other, err := users.NewRepository(suite.DB).EnsureTelegramUser(ctx, 1001, "Other")
+	suite.Require().NoError(err)
+	otherCtx := owner.WithID(ctx, other.ID)
+
+	p := Person{FirstName: "Anna", ContactInfos: []ContactInfo{{Method: Email, Data: "anna@example.com"}}}
+	suite.Require().NoError(suite.repo.Insert(ctx, &p))
+	suite.Equal(users.DefaultID, p.OwnerID)
+
+	_, err = suite.repo.Get(otherCtx, p.ID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	page, total, err := suite.repo.List(otherCtx, ListOptions{})
+	suite.Require().NoError(err)
+	suite.Zero(total)
+	suite.Empty(page)
+
+	results, err := suite.repo.Search(otherCtx, "anna", 10)
+	suite.Require().NoError(err)
+	suite.Empty(results)
+
+	many, err := suite.repo.GetMany(otherCtx, []int{p.ID})
+	suite.Require().NoError(err)
+	suite.Empty(many)
+
+	suite.ErrorIs(suite.repo.Update(otherCtx, &Person{ID: p.ID, FirstName: "Hijacked"}), models.ErrRecordNotFound)
+	suite.ErrorIs(suite.repo.UpdateSettings(otherCtx, p.ID, Settings{BirthdayNotify: true}), models.ErrRecordNotFound)
+	suite.ErrorIs(suite.repo.TouchLastContacted(otherCtx, p.ID, time.Now()), models.ErrRecordNotFound)
+	suite.ErrorIs(suite.repo.Delete(otherCtx, p.ID), models.ErrRecordNotFound)
+
+	got, err := suite.repo.Get(ctx, p.ID)
+	suite.Require().NoError(err)
+	suite.Equal("Anna", got.FirstName)
+	suite.Len(got.ContactInfos, 1)
+
+	_, err = suite.repo.Get(txcontext.WithTx(context.Background(), suite.tx), p.ID)
+	suite.ErrorIs(err, owner.ErrMissing)
+}
+
+func (suite *personRepoTestSuite) TestListBirthdayNotify() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	birthDate := sql.NullTime{Time: time.Date(2000, time.February, 29, 0, 0, 0, 0, time.UTC), Valid: true}
+	persons := []Person{
+		{FirstName: "Anna", BirthDate: birthDate, Settings: Settings{BirthdayNotify: true, BirthdayNotifyDaysBefore: 2}},
+		{FirstName: "Boris", BirthDate: birthDate, Settings: Settings{BirthdayNotify: false}},
+		{FirstName: "Clara", Settings: Settings{BirthdayNotify: true}},
+	}
+	for i := range persons {
+		suite.Require().NoError(suite.repo.Insert(ctx, &persons[i]))
+	}
+
+	ps, err := suite.repo.ListBirthdayNotify(ctx)
+	suite.Require().NoError(err)
+	suite.Require().Len(ps, 1)
+	suite.Equal(persons[0].ID, ps[0].ID)
+	suite.Equal(persons[0].Settings, ps[0].Settings)
+	suite.Equal(birthDate.Time, ps[0].BirthDate.Time.UTC())
+}
+
+func (suite *personRepoTestSuite) TestListKeepInTouch() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	persons := []Person{
+		{FirstName: "Anna", Settings: Settings{KeepInTouch: Cadence{Count: 3, Unit: Month}}},
+		{FirstName: "Boris"},
+	}
+	for i := range persons {
+		suite.Require().NoError(suite.repo.Insert(ctx, &persons[i]))
+	}
+
+	ps, err := suite.repo.ListKeepInTouch(ctx)
+	suite.Require().NoError(err)
+	suite.Require().Len(ps, 1)
+	suite.Equal(persons[0].ID, ps[0].ID)
+	suite.Equal(Cadence{Count: 3, Unit: Month}, ps[0].Settings.KeepInTouch)
+}
+
+func (suite *personRepoTestSuite) TestUpdateSettings() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := Person{
+		FirstName:    testFirstName,
+		ContactInfos: []ContactInfo{{Method: testMethod1, Data: testData1}},
+	}
+	suite.Require().NoError(suite.repo.Insert(ctx, &person))
+
+	settings := Settings{BirthdayNotify: true, KeepInTouch: Cadence{Count: 1, Unit: Week}}
+	suite.Require().NoError(suite.repo.UpdateSettings(ctx, person.ID, settings))
+
+	updatedPerson, err := suite.repo.Get(ctx, person.ID)
+	suite.Require().NoError(err)
+	suite.Equal(settings, updatedPerson.Settings)
+	suite.Len(updatedPerson.ContactInfos, 1)
+
+	suite.ErrorIs(suite.repo.UpdateSettings(ctx, -1, settings), models.ErrRecordNotFound)
+}
+
+func (suite *personRepoTestSuite) TestTouchLastContacted() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	person := Person{FirstName: testFirstName}
+	suite.Require().NoError(suite.repo.Insert(ctx, &person))
+
+	contactedAt := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+	suite.Require().NoError(suite.repo.TouchLastContacted(ctx, person.ID, contactedAt))
+	suite.Require().NoError(suite.repo.TouchLastContacted(ctx, person.ID, contactedAt.AddDate(0, 0, -1)))
+
+	updatedPerson, err := suite.repo.Get(ctx, person.ID)
+	suite.Require().NoError(err)
+	suite.True(updatedPerson.LastContactedAt.Valid)
+	suite.Equal(contactedAt, updatedPerson.LastContactedAt.Time.UTC())
+
+	suite.ErrorIs(suite.repo.TouchLastContacted(ctx, -1, contactedAt), models.ErrRecordNotFound)
+}
+
 func TestPersonRepoTestSuite(t *testing.T) {
 	suite.Run(t, new(personRepoTestSuite))
 }
@@ -0,0 +1,181 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+)
+
+const (
+	// tokenPrefix makes the tokens easy to recognize, for example by secret
+	// scanners.
+	tokenPrefix = "pcrm_"
+	tokenLength = 32
+)
+
+type TokenRepository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+type DB interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *TokenRepository) getDB(ctx context.Context) DB {
+	if tx, ok := txcontext.GetTx(ctx); ok {
+		return tx
+	}
+	return r.db
+}
+
+const tokenColumns = `id, user_id, name, scope, created_at, expires_at, last_used_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanToken(row scanner) (*Token, error) {
+	var t Token
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.CreatedAt, &t.ExpiresAt, &t.LastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Insert creates a token of the owner and returns it along with the secret
+// to authenticate with, which can't be recovered later. A zero expiresAt
+// means the token doesn't expire.
+func (r *TokenRepository) Insert(ctx context.Context, name string, scope Scope, expiresAt time.Time) (*Token, string, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	const stmt = `INSERT INTO api_tokens (user_id, name, token_hash, scope, expires_at)
+	VALUES($1, $2, $3, $4, $5)
+	RETURNING ` + tokenColumns
+
+	expires := sql.NullTime{Time: expiresAt, Valid: !expiresAt.IsZero()}
+
+	t, err := scanToken(r.getDB(ctx).QueryRowContext(ctx, stmt, ownerID, name, hashSecret(secret), scope, expires))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return t, secret, nil
+}
+
+// List returns the tokens of the owner, newest first.
+func (r *TokenRepository) List(ctx context.Context) ([]Token, error) {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const stmt = `SELECT ` + tokenColumns + `
+	FROM api_tokens
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.getDB(ctx).QueryContext(ctx, stmt, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ts []Token
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, *t)
+	}
+
+	return ts, rows.Err()
+}
+
+// Revoke deletes the token of the owner.
+func (r *TokenRepository) Revoke(ctx context.Context, id int) error {
+	ownerID, err := owner.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, id, ownerID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return models.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Authenticate returns the unexpired token with the secret and records that
+// it was used. It needs no owner, the token determines it.
+func (r *TokenRepository) Authenticate(ctx context.Context, secret string) (*Token, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return nil, models.ErrInvalidCredentials
+	}
+
+	const stmt = `UPDATE api_tokens SET last_used_at = NOW()
+	WHERE token_hash = $1 AND (expires_at IS NULL OR expires_at > NOW())
+	RETURNING ` + tokenColumns
+
+	t, err := scanToken(r.getDB(ctx).QueryRowContext(ctx, stmt, hashSecret(secret)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret is what is stored instead of the secret itself. The secret is
+// random enough for a fast hash.
+func hashSecret(secret string) []byte {
+	h := sha256.Sum256([]byte(secret))
+	return h[:]
+}
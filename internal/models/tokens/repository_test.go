@@ -0,0 +1,112 @@
+package tokens
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/test"
+	"github.com/stretchr/testify/suite"
+)
+
+const testTokenName = "Shortcuts"
+
+type tokenRepoTestSuite struct {
+	test.TestSuite
+	repo *TokenRepository
+	tx   *sql.Tx
+}
+
+func (suite *tokenRepoTestSuite) SetupSuite() {
+	suite.TestSuite.SetupSuite()
+	suite.Ctx = owner.WithID(suite.Ctx, users.DefaultID)
+
+	suite.repo = NewRepository(suite.DB)
+}
+
+func (suite *tokenRepoTestSuite) SetupTest() {
+	var err error
+	suite.tx, err = suite.DB.BeginTx(suite.Ctx, nil)
+	suite.Require().NoError(err)
+}
+
+func (suite *tokenRepoTestSuite) TearDownTest() {
+	err := suite.tx.Rollback()
+	suite.Require().NoError(err)
+}
+
+func (suite *tokenRepoTestSuite) TestAuthenticate() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	token, secret, err := suite.repo.Insert(ctx, testTokenName, ScopeRead, time.Time{})
+	suite.Require().NoError(err)
+	suite.Equal(users.DefaultID, token.UserID)
+	suite.Equal(ScopeRead, token.Scope)
+	suite.False(token.ExpiresAt.Valid)
+	suite.False(token.LastUsedAt.Valid)
+	suite.Regexp(`^pcrm_[A-Za-z0-9_-]{43}$`, secret)
+
+	got, err := suite.repo.Authenticate(ctx, secret)
+	suite.Require().NoError(err)
+	suite.Equal(token.ID, got.ID)
+	suite.True(got.LastUsedAt.Valid)
+	suite.False(got.CanWrite())
+
+	_, err = suite.repo.Authenticate(ctx, secret+"x")
+	suite.ErrorIs(err, models.ErrInvalidCredentials)
+
+	_, err = suite.repo.Authenticate(ctx, "not a token")
+	suite.ErrorIs(err, models.ErrInvalidCredentials)
+
+	expired, secret, err := suite.repo.Insert(ctx, testTokenName, ScopeReadWrite, time.Now().Add(-time.Minute))
+	suite.Require().NoError(err)
+	suite.True(expired.Expired())
+
+	_, err = suite.repo.Authenticate(ctx, secret)
+	suite.ErrorIs(err, models.ErrInvalidCredentials)
+}
+
+func (suite *tokenRepoTestSuite) TestListAndRevoke() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	first, _, err := suite.repo.Insert(ctx, "First", ScopeRead, time.Time{})
+	suite.Require().NoError(err)
+
+	second, secret, err := suite.repo.Insert(ctx, "Second", ScopeReadWrite, time.Now().Add(time.Hour))
+	suite.Require().NoError(err)
+
+	ts, err := suite.repo.List(ctx)
+	suite.Require().NoError(err)
+	suite.Require().Len(ts, 2)
+	suite.Equal(second.ID, ts[0].ID)
+	suite.Equal(first.ID, ts[1].ID)
+
+	var otherID int
+	err = suite.tx.QueryRowContext(ctx, `INSERT INTO users (name) VALUES ('Other') RETURNING id`).Scan(&otherID)
+	suite.Require().NoError(err)
+
+	otherCtx := owner.WithID(ctx, otherID)
+
+	ts, err = suite.repo.List(otherCtx)
+	suite.Require().NoError(err)
+	suite.Empty(ts)
+
+	err = suite.repo.Revoke(otherCtx, second.ID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	suite.Require().NoError(suite.repo.Revoke(ctx, second.ID))
+
+	_, err = suite.repo.Authenticate(ctx, secret)
+	suite.ErrorIs(err, models.ErrInvalidCredentials)
+
+	err = suite.repo.Revoke(ctx, second.ID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+}
+
+func TestTokenRepoTestSuite(t *testing.T) {
+	suite.Run(t, new(tokenRepoTestSuite))
+}
@@ -0,0 +1,40 @@
+package tokens
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Scope is what a token may be used for.
+type Scope string
+
+const (
+	// ScopeRead allows reading only.
+	ScopeRead Scope = "read"
+	// ScopeReadWrite allows changing data as well.
+	ScopeReadWrite Scope = "read-write"
+)
+
+var Scopes = []Scope{ScopeRead, ScopeReadWrite}
+
+// Token is a personal access token. The token itself is only known when it
+// is created; just its hash is stored.
+type Token struct {
+	CreatedAt  time.Time
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	Name       string
+	Scope      Scope
+	ID         int
+	UserID     int
+}
+
+// CanWrite reports whether the token may be used to change data.
+func (t *Token) CanWrite() bool {
+	return t.Scope == ScopeReadWrite
+}
+
+// Expired reports whether the token can no longer be used.
+func (t *Token) Expired() bool {
+	return t.ExpiresAt.Valid && !t.ExpiresAt.Time.After(time.Now())
+}
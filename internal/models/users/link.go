@@ -0,0 +1,167 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+)
+
+// linkCodeAlphabet leaves out characters that are easy to confuse.
+const (
+	linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	linkCodeLength   = 8
+)
+
+// LinkTelegram attaches the Telegram account to the user, replacing the one
+// linked before. A user the bot created for the Telegram account on its own
+// is merged into the user: its persons, notifications and interactions move
+// over and it is deleted. An account linked to another web user is left
+// alone and ErrTelegramTaken is returned.
+func (r *UserRepository) LinkTelegram(ctx context.Context, id int, telegramID int64) error {
+	return txcontext.Run(ctx, r.db, func(ctx context.Context) error {
+		const selectStmt = `SELECT id, email FROM users WHERE telegram_id = $1 FOR UPDATE`
+
+		var (
+			linkedID    int
+			linkedEmail sql.NullString
+		)
+
+		err := r.getDB(ctx).QueryRowContext(ctx, selectStmt, telegramID).Scan(&linkedID, &linkedEmail)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return err
+		case linkedID == id:
+			return nil
+		case linkedEmail.Valid:
+			return models.ErrTelegramTaken
+		default:
+			if err := r.mergeInto(ctx, linkedID, id); err != nil {
+				return err
+			}
+		}
+
+		const updateStmt = `UPDATE users SET telegram_id = $1 WHERE id = $2`
+
+		res, err := r.getDB(ctx).ExecContext(ctx, updateStmt, telegramID, id)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return models.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// mergeInto hands all data of the user from over to the user to and deletes
+// the user from.
+func (r *UserRepository) mergeInto(ctx context.Context, from, to int) error {
+	stmts := []string{
+		`UPDATE persons SET owner_id = $2 WHERE owner_id = $1`,
+		`UPDATE notifications SET owner_id = $2 WHERE owner_id = $1`,
+		`UPDATE interactions SET owner_id = $2 WHERE owner_id = $1`,
+		`UPDATE person_merges SET owner_id = $2 WHERE owner_id = $1`,
+		`DELETE FROM users WHERE id = $1`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := r.getDB(ctx).ExecContext(ctx, stmt, from, to); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateLinkCode issues a one-time code that links the Telegram account to
+// the web user who enters it before it expires. Only the latest code of an
+// account is valid.
+func (r *UserRepository) CreateLinkCode(ctx context.Context, telegramID int64, ttl time.Duration) (string, error) {
+	code, err := newLinkCode()
+	if err != nil {
+		return "", err
+	}
+
+	err = txcontext.Run(ctx, r.db, func(ctx context.Context) error {
+		const deleteStmt = `DELETE FROM link_codes WHERE telegram_id = $1 OR expires_at <= NOW()`
+
+		if _, err := r.getDB(ctx).ExecContext(ctx, deleteStmt, telegramID); err != nil {
+			return err
+		}
+
+		const insertStmt = `INSERT INTO link_codes (code_hash, telegram_id, expires_at)
+		VALUES($1, $2, NOW() + make_interval(secs => $3))`
+
+		_, err := r.getDB(ctx).ExecContext(ctx, insertStmt, hashLinkCode(code), telegramID, ttl.Seconds())
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code[:linkCodeLength/2] + "-" + code[linkCodeLength/2:], nil
+}
+
+// ConsumeLinkCode returns the Telegram account the code was issued for and
+// invalidates the code. The code is matched ignoring case, spaces and
+// dashes.
+func (r *UserRepository) ConsumeLinkCode(ctx context.Context, code string) (int64, error) {
+	const stmt = `DELETE FROM link_codes
+	WHERE code_hash = $1 AND expires_at > NOW()
+	RETURNING telegram_id`
+
+	var telegramID int64
+
+	err := r.getDB(ctx).QueryRowContext(ctx, stmt, hashLinkCode(normalizeLinkCode(code))).Scan(&telegramID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrRecordNotFound
+		}
+		return 0, err
+	}
+
+	return telegramID, nil
+}
+
+func newLinkCode() (string, error) {
+	b := make([]byte, linkCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = linkCodeAlphabet[int(b[i])%len(linkCodeAlphabet)]
+	}
+
+	return string(b), nil
+}
+
+func normalizeLinkCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
+
+// hashLinkCode is what is stored instead of the code itself.
+func hashLinkCode(code string) []byte {
+	h := sha256.Sum256([]byte(code))
+	return h[:]
+}
@@ -0,0 +1,182 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const bcryptCost = 12
+
+type UserRepository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+type DB interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *UserRepository) getDB(ctx context.Context) DB {
+	if tx, ok := txcontext.GetTx(ctx); ok {
+		return tx
+	}
+	return r.db
+}
+
+const userColumns = `id, telegram_id, email, name, created_at`
+
+func (r *UserRepository) Get(ctx context.Context, id int) (*User, error) {
+	const stmt = `SELECT ` + userColumns + `
+	FROM users
+	WHERE id = $1`
+
+	return r.get(ctx, stmt, id)
+}
+
+func (r *UserRepository) GetByTelegramID(ctx context.Context, telegramID int64) (*User, error) {
+	const stmt = `SELECT ` + userColumns + `
+	FROM users
+	WHERE telegram_id = $1`
+
+	return r.get(ctx, stmt, telegramID)
+}
+
+// EnsureTelegramUser returns the user with the Telegram id, creating it on
+// first sight.
+func (r *UserRepository) EnsureTelegramUser(ctx context.Context, telegramID int64, name string) (*User, error) {
+	const stmt = `INSERT INTO users (telegram_id, name)
+	VALUES($1, $2)
+	ON CONFLICT (telegram_id) DO UPDATE SET telegram_id = EXCLUDED.telegram_id
+	RETURNING ` + userColumns
+
+	return r.get(ctx, stmt, telegramID, name)
+}
+
+// AdoptDefault attaches the Telegram id to the default user, unless the
+// default user already has one or the Telegram id belongs to another user.
+// It hands the data created before there were users to its original owner.
+func (r *UserRepository) AdoptDefault(ctx context.Context, telegramID int64) error {
+	const stmt = `UPDATE users
+	SET telegram_id = $1
+	WHERE id = $2 AND telegram_id IS NULL
+		AND NOT EXISTS (SELECT 1 FROM users WHERE telegram_id = $1)`
+
+	_, err := r.getDB(ctx).ExecContext(ctx, stmt, telegramID, DefaultID)
+	return err
+}
+
+// Insert creates a user who logs in to the web UI with the email and
+// password.
+func (r *UserRepository) Insert(ctx context.Context, name, email, password string) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	const stmt = `INSERT INTO users (name, email, password_hash)
+	VALUES($1, $2, $3)
+	RETURNING ` + userColumns
+
+	u, err := r.get(ctx, stmt, name, normalizeEmail(email), string(hash))
+	if err != nil {
+		if isDuplicateEmail(err) {
+			return nil, models.ErrDuplicateEmail
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// SetCredentials sets the email and password the user logs in with,
+// replacing the previous ones.
+func (r *UserRepository) SetCredentials(ctx context.Context, id int, email, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return err
+	}
+
+	const stmt = `UPDATE users SET email = $1, password_hash = $2 WHERE id = $3`
+
+	res, err := r.getDB(ctx).ExecContext(ctx, stmt, normalizeEmail(email), string(hash), id)
+	if err != nil {
+		if isDuplicateEmail(err) {
+			return models.ErrDuplicateEmail
+		}
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return models.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Authenticate returns the id of the user with the email and password.
+func (r *UserRepository) Authenticate(ctx context.Context, email, password string) (int, error) {
+	const stmt = `SELECT id, password_hash FROM users WHERE email = $1 AND password_hash IS NOT NULL`
+
+	var (
+		id   int
+		hash string
+	)
+
+	err := r.getDB(ctx).QueryRowContext(ctx, stmt, normalizeEmail(email)).Scan(&id, &hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func isDuplicateEmail(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "uq_users_email"
+}
+
+func (r *UserRepository) get(ctx context.Context, stmt string, args ...any) (*User, error) {
+	var u User
+
+	err := r.getDB(ctx).QueryRowContext(ctx, stmt, args...).Scan(&u.ID, &u.TelegramID, &u.Email, &u.Name, &u.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
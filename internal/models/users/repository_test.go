@@ -0,0 +1,199 @@
+package users
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/test"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	testTelegramID int64 = 419672615
+	testName             = "Pavel"
+	testEmail            = "pavel@example.com"
+	testPassword         = "correct horse battery staple"
+)
+
+type userRepoTestSuite struct {
+	test.TestSuite
+	repo *UserRepository
+	tx   *sql.Tx
+}
+
+func (suite *userRepoTestSuite) SetupSuite() {
+	suite.TestSuite.SetupSuite()
+
+	suite.repo = NewRepository(suite.DB)
+}
+
+func (suite *userRepoTestSuite) SetupTest() {
+	var err error
+	suite.tx, err = suite.DB.BeginTx(suite.Ctx, nil)
+	suite.Require().NoError(err)
+}
+
+func (suite *userRepoTestSuite) TearDownTest() {
+	err := suite.tx.Rollback()
+	suite.Require().NoError(err)
+}
+
+func (suite *userRepoTestSuite) TestEnsureTelegramUser() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	_, err := suite.repo.GetByTelegramID(ctx, testTelegramID)
+	suite.Require().ErrorIs(err, models.ErrRecordNotFound)
+
+	u, err := suite.repo.EnsureTelegramUser(ctx, testTelegramID, testName)
+	suite.Require().NoError(err)
+	suite.NotEqual(DefaultID, u.ID)
+	suite.Equal(testTelegramID, u.TelegramID.Int64)
+	suite.Equal(testName, u.Name)
+
+	again, err := suite.repo.EnsureTelegramUser(ctx, testTelegramID, "Renamed")
+	suite.Require().NoError(err)
+	suite.Equal(u.ID, again.ID)
+
+	got, err := suite.repo.GetByTelegramID(ctx, testTelegramID)
+	suite.Require().NoError(err)
+	suite.Equal(u.ID, got.ID)
+}
+
+func (suite *userRepoTestSuite) TestAdoptDefault() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	def, err := suite.repo.Get(ctx, DefaultID)
+	suite.Require().NoError(err)
+	suite.False(def.TelegramID.Valid)
+
+	suite.Require().NoError(suite.repo.AdoptDefault(ctx, testTelegramID))
+	suite.Require().NoError(suite.repo.AdoptDefault(ctx, testTelegramID+1))
+
+	def, err = suite.repo.Get(ctx, DefaultID)
+	suite.Require().NoError(err)
+	suite.Equal(testTelegramID, def.TelegramID.Int64)
+
+	u, err := suite.repo.EnsureTelegramUser(ctx, testTelegramID, testName)
+	suite.Require().NoError(err)
+	suite.Equal(DefaultID, u.ID)
+}
+
+func (suite *userRepoTestSuite) TestAuthenticate() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	u, err := suite.repo.Insert(ctx, testName, " Pavel@Example.com", testPassword)
+	suite.Require().NoError(err)
+	suite.Equal(testEmail, u.Email.String)
+
+	id, err := suite.repo.Authenticate(ctx, "PAVEL@example.com", testPassword)
+	suite.Require().NoError(err)
+	suite.Equal(u.ID, id)
+
+	_, err = suite.repo.Authenticate(ctx, testEmail, "wrong password")
+	suite.ErrorIs(err, models.ErrInvalidCredentials)
+
+	_, err = suite.repo.Authenticate(ctx, "nobody@example.com", testPassword)
+	suite.ErrorIs(err, models.ErrInvalidCredentials)
+
+	_, err = suite.repo.Insert(ctx, "Other", testEmail, testPassword)
+	suite.ErrorIs(err, models.ErrDuplicateEmail)
+}
+
+func (suite *userRepoTestSuite) TestSetCredentials() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	_, err := suite.repo.Authenticate(ctx, testEmail, testPassword)
+	suite.Require().ErrorIs(err, models.ErrInvalidCredentials)
+
+	suite.Require().NoError(suite.repo.SetCredentials(ctx, DefaultID, testEmail, testPassword))
+
+	id, err := suite.repo.Authenticate(ctx, testEmail, testPassword)
+	suite.Require().NoError(err)
+	suite.Equal(DefaultID, id)
+
+	suite.Require().NoError(suite.repo.SetCredentials(ctx, DefaultID, testEmail, "new password"))
+
+	_, err = suite.repo.Authenticate(ctx, testEmail, testPassword)
+	suite.ErrorIs(err, models.ErrInvalidCredentials)
+
+	err = suite.repo.SetCredentials(ctx, -1, "other@example.com", testPassword)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+}
+
+func (suite *userRepoTestSuite) TestLinkTelegram() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	web, err := suite.repo.Insert(ctx, testName, testEmail, testPassword)
+	suite.Require().NoError(err)
+
+	bot, err := suite.repo.EnsureTelegramUser(ctx, testTelegramID, testName)
+	suite.Require().NoError(err)
+
+	_, err = suite.tx.ExecContext(ctx, `INSERT INTO persons (first_name, owner_id) VALUES ('Anna', $1)`, bot.ID)
+	suite.Require().NoError(err)
+
+	// A merge made in the bot can still be undone from the web.
+	_, err = suite.tx.ExecContext(ctx, `INSERT INTO person_merges (owner_id, survivor_id, merged_id, merged_name, snapshot)
+		VALUES ($1, 1, 2, 'Anna', '{}')`, bot.ID)
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(suite.repo.LinkTelegram(ctx, web.ID, testTelegramID))
+	suite.Require().NoError(suite.repo.LinkTelegram(ctx, web.ID, testTelegramID))
+
+	u, err := suite.repo.GetByTelegramID(ctx, testTelegramID)
+	suite.Require().NoError(err)
+	suite.Equal(web.ID, u.ID)
+
+	_, err = suite.repo.Get(ctx, bot.ID)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	var owned int
+	err = suite.tx.QueryRowContext(ctx, `SELECT count(*) FROM persons WHERE owner_id = $1`, web.ID).Scan(&owned)
+	suite.Require().NoError(err)
+	suite.Equal(1, owned)
+
+	err = suite.tx.QueryRowContext(ctx, `SELECT count(*) FROM person_merges WHERE owner_id = $1`, web.ID).Scan(&owned)
+	suite.Require().NoError(err)
+	suite.Equal(1, owned)
+
+	other, err := suite.repo.Insert(ctx, "Other", "other@example.com", testPassword)
+	suite.Require().NoError(err)
+
+	err = suite.repo.LinkTelegram(ctx, other.ID, testTelegramID)
+	suite.ErrorIs(err, models.ErrTelegramTaken)
+}
+
+func (suite *userRepoTestSuite) TestLinkCode() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	first, err := suite.repo.CreateLinkCode(ctx, testTelegramID, time.Minute)
+	suite.Require().NoError(err)
+	suite.Regexp(`^[A-Z2-9]{4}-[A-Z2-9]{4}$`, first)
+
+	code, err := suite.repo.CreateLinkCode(ctx, testTelegramID, time.Minute)
+	suite.Require().NoError(err)
+
+	_, err = suite.repo.ConsumeLinkCode(ctx, first)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	telegramID, err := suite.repo.ConsumeLinkCode(ctx, " "+strings.ToLower(code))
+	suite.Require().NoError(err)
+	suite.Equal(testTelegramID, telegramID)
+
+	_, err = suite.repo.ConsumeLinkCode(ctx, code)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+
+	expired, err := suite.repo.CreateLinkCode(ctx, testTelegramID, -time.Minute)
+	suite.Require().NoError(err)
+
+	_, err = suite.repo.ConsumeLinkCode(ctx, expired)
+	suite.ErrorIs(err, models.ErrRecordNotFound)
+}
+
+func TestUserRepoTestSuite(t *testing.T) {
+	suite.Run(t, new(userRepoTestSuite))
+}
@@ -0,0 +1,21 @@
+package users
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DefaultID is the user that owns the data created before there were users.
+const DefaultID = 1
+
+type User struct {
+	CreatedAt time.Time
+	// TelegramID is the Telegram user id, which is also the id of the
+	// private chat with the bot where the user's reminders are sent.
+	TelegramID sql.NullInt64
+	// Email is the login of the web UI. Users without one can only use
+	// the bot.
+	Email sql.NullString
+	Name  string
+	ID    int
+}
@@ -0,0 +1,119 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type EmailConfig struct {
+	// Addr is the host:port of the SMTP server.
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// EmailChannel sends messages as plain text emails. STARTTLS is used when
+// the server offers it; authentication is only attempted when a username is
+// configured.
+type EmailChannel struct {
+	config EmailConfig
+}
+
+func NewEmailChannel(config EmailConfig) *EmailChannel {
+	return &EmailChannel{config: config}
+}
+
+// EmailChannelName is the name the email channel is routed by.
+const EmailChannelName = "email"
+
+func (c *EmailChannel) Name() string {
+	return EmailChannelName
+}
+
+func (c *EmailChannel) Send(ctx context.Context, msg Message) error {
+	host, _, err := net.SplitHostPort(c.config.Addr)
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.config.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if c.config.Username != "" {
+		auth := smtp.PlainAuth("", c.config.Username, c.config.Password, host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(c.config.From); err != nil {
+		return err
+	}
+
+	for _, to := range c.config.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(c.compose(msg, time.Now())); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (c *EmailChannel) compose(msg Message, date time.Time) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", c.config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(c.config.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	return b.Bytes()
+}
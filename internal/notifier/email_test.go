@@ -0,0 +1,125 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type smtpEnvelope struct {
+	from string
+	to   []string
+	data string
+}
+
+// runSMTPServer serves a single SMTP session on a local port, standing in
+// for a real mail server, and reports the received envelope.
+func runSMTPServer(t *testing.T) (string, <-chan smtpEnvelope) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	received := make(chan smtpEnvelope, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		var env smtpEnvelope
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				env.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				env.to = append(env.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				env.data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				received <- env
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+
+	return l.Addr().String(), received
+}
+
+func TestEmailChannelSend(t *testing.T) {
+	addr, received := runSMTPServer(t)
+
+	c := NewEmailChannel(EmailConfig{
+		Addr: addr,
+		From: "crm@example.com",
+		To:   []string{"me@example.com", "assistant@example.com"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := c.Send(ctx, Message{Subject: "Напоминание", Text: "It's time to contact with John Smith"})
+	require.NoError(t, err)
+
+	select {
+	case env := <-received:
+		assert.Equal(t, "crm@example.com", env.from)
+		assert.Equal(t, []string{"me@example.com", "assistant@example.com"}, env.to)
+		assert.Contains(t, env.data, "To: me@example.com, assistant@example.com\r\n")
+		assert.Contains(t, env.data, "Subject: =?utf-8?q?")
+		assert.Contains(t, env.data, "\r\n\r\nIt's time to contact with John Smith\r\n")
+	case <-ctx.Done():
+		t.Fatal("SMTP server did not receive the message")
+	}
+}
+
+func TestEmailChannelSendUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	c := NewEmailChannel(EmailConfig{Addr: addr, From: "crm@example.com", To: []string{"me@example.com"}})
+	assert.Error(t, c.Send(context.Background(), Message{Text: "hello"}))
+}
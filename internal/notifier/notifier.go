@@ -0,0 +1,109 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownChannel = errors.New("unknown notification channel")
+
+// Message is a channel-independent reminder. Channels decide how to render
+// it; e.g. the Subject becomes the email subject and is dropped by Telegram.
+type Message struct {
+	Subject string
+	Text    string
+	// Actions are offered as buttons by channels that support them and are
+	// ignored by the rest.
+	Actions []Action
+	// TelegramChatID is the chat the Telegram channel delivers to instead of
+	// its default chat. Zero means the default chat.
+	TelegramChatID int64
+}
+
+// Action is a button on a message. Data is passed back to the handler of
+// the button when it is pressed.
+type Action struct {
+	Text string
+	Data string
+}
+
+// SharedRecipient reports whether the channel delivers to a recipient set
+// for the whole deployment, such as the configured mailbox, rather than to
+// the owner of the message.
+func SharedRecipient(name string) bool {
+	return name == EmailChannelName || name == WebhookChannelName
+}
+
+// Channel delivers messages to one destination, such as a Telegram chat or
+// a mailbox.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// Notifier routes messages to registered channels by name.
+type Notifier struct {
+	channels map[string]Channel
+	defaults []string
+}
+
+// New registers the channels and sets the ones used when a message is sent
+// without explicit routing.
+func New(defaults []string, channels ...Channel) (*Notifier, error) {
+	n := &Notifier{channels: make(map[string]Channel), defaults: defaults}
+
+	for _, c := range channels {
+		n.channels[c.Name()] = c
+	}
+
+	if len(defaults) == 0 {
+		return nil, errors.New("at least one default notification channel is required")
+	}
+
+	for _, name := range defaults {
+		if _, ok := n.channels[name]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownChannel, name)
+		}
+	}
+
+	return n, nil
+}
+
+// Channels returns the channels a message sent to names goes to: names
+// itself, or the default channels when names is empty.
+func (n *Notifier) Channels(names []string) []string {
+	if len(names) == 0 {
+		return n.defaults
+	}
+	return names
+}
+
+// Send delivers the message to every named channel, or to the default
+// channels when names is empty, and returns the channels that delivered it.
+// Every channel is attempted; the returned error joins the failures of all
+// channels that did not deliver.
+func (n *Notifier) Send(ctx context.Context, names []string, msg Message) ([]string, error) {
+	names = n.Channels(names)
+
+	var (
+		delivered []string
+		errs      []error
+	)
+	for _, name := range names {
+		c, ok := n.channels[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: %q", ErrUnknownChannel, name))
+			continue
+		}
+
+		if err := c.Send(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+
+		delivered = append(delivered, name)
+	}
+
+	return delivered, errors.Join(errs...)
+}
@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeChannel struct {
+	name string
+	err  error
+	sent []Message
+}
+
+func (c *fakeChannel) Name() string {
+	return c.name
+}
+
+func (c *fakeChannel) Send(ctx context.Context, msg Message) error {
+	c.sent = append(c.sent, msg)
+	return c.err
+}
+
+func TestNew(t *testing.T) {
+	_, err := New([]string{"telegram"}, &fakeChannel{name: "email"})
+	assert.ErrorIs(t, err, ErrUnknownChannel)
+
+	_, err = New(nil, &fakeChannel{name: "email"})
+	assert.Error(t, err)
+}
+
+func TestNotifierSend(t *testing.T) {
+	telegram := &fakeChannel{name: "telegram"}
+	email := &fakeChannel{name: "email"}
+	webhook := &fakeChannel{name: "webhook", err: errors.New("connection refused")}
+
+	n, err := New([]string{"telegram"}, telegram, email, webhook)
+	require.NoError(t, err)
+
+	msg := Message{Subject: "Keep in touch", Text: "It's time to contact with John Smith"}
+
+	delivered, err := n.Send(context.Background(), nil, msg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"telegram"}, delivered)
+	assert.Equal(t, []Message{msg}, telegram.sent)
+	assert.Empty(t, email.sent)
+
+	delivered, err = n.Send(context.Background(), []string{"email", "webhook", "pager"}, msg)
+	assert.Equal(t, []string{"email"}, delivered)
+	assert.ErrorIs(t, err, ErrUnknownChannel)
+	assert.ErrorContains(t, err, "webhook: connection refused")
+	assert.Equal(t, []Message{msg}, email.sent)
+	assert.Equal(t, []Message{msg}, webhook.sent)
+	assert.Len(t, telegram.sent, 1)
+}
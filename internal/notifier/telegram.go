@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"context"
+
+	"gopkg.in/telebot.v3"
+)
+
+type TelegramChannel struct {
+	bot    *telebot.Bot
+	chatID int64
+}
+
+func NewTelegramChannel(bot *telebot.Bot, chatID int64) *TelegramChannel {
+	return &TelegramChannel{bot: bot, chatID: chatID}
+}
+
+// TelegramChannelName is the name the Telegram channel is routed by.
+const TelegramChannelName = "telegram"
+
+func (c *TelegramChannel) Name() string {
+	return TelegramChannelName
+}
+
+// ActionUnique is the telebot unique identifier of action buttons. Bots
+// handle presses by registering a handler for an InlineButton with it.
+const ActionUnique = "reminder"
+
+func (c *TelegramChannel) Send(ctx context.Context, msg Message) error {
+	var opts []interface{}
+	if len(msg.Actions) > 0 {
+		opts = append(opts, actionsMarkup(msg.Actions))
+	}
+
+	chatID := c.chatID
+	if msg.TelegramChatID != 0 {
+		chatID = msg.TelegramChatID
+	}
+
+	_, err := c.bot.Send(telebot.ChatID(chatID), msg.Text, opts...)
+	return err
+}
+
+func actionsMarkup(actions []Action) *telebot.ReplyMarkup {
+	row := make([]telebot.InlineButton, 0, len(actions))
+	for _, a := range actions {
+		row = append(row, telebot.InlineButton{Unique: ActionUnique, Text: a.Text, Data: a.Data})
+	}
+
+	return &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{row}}
+}
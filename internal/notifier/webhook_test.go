@@ -0,0 +1,38 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookChannelSend(t *testing.T) {
+	var payload webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewWebhookChannel(srv.URL)
+	err := c.Send(context.Background(), Message{Subject: "Birthday", Text: "Today is John Smith's birthday"})
+	require.NoError(t, err)
+	assert.Equal(t, webhookPayload{Subject: "Birthday", Text: "Today is John Smith's birthday"}, payload)
+}
+
+func TestWebhookChannelSendErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	err := NewWebhookChannel(srv.URL).Send(context.Background(), Message{Text: "hello"})
+	assert.ErrorContains(t, err, "502")
+}
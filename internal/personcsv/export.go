@@ -0,0 +1,136 @@
+package personcsv
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+)
+
+// valueSeparator separates several values in a column.
+const valueSeparator = "; "
+
+// exportPageSize is the number of persons loaded at a time for exporting.
+const exportPageSize = 100
+
+// Lister pages through the persons of the owner in the context.
+type Lister interface {
+	List(ctx context.Context, opts person.ListOptions) ([]person.Person, int, error)
+}
+
+// Export writes all persons of the owner in the context as CSV, with a
+// header row of the field names.
+func Export(ctx context.Context, w io.Writer, persons Lister) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(Fields))
+	for i, f := range Fields {
+		header[i] = string(f)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	err := eachPerson(ctx, persons, func(p *person.Person) {
+		record := Record(p)
+		for i, f := range Fields {
+			record[i] = escapeCell(f, record[i])
+		}
+		cw.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Record returns the columns of p in the order of Fields. Primary contacts
+// come first in their column, and a column without a primary contact starts
+// with an empty value, so that importing it marks none as primary.
+func Record(p *person.Person) []string {
+	values := make(map[Field][]string)
+
+	for _, primary := range []bool{true, false} {
+		for _, c := range p.ContactInfos {
+			if c.Primary != primary {
+				continue
+			}
+			for f, method := range contactFields {
+				if method != c.Method {
+					continue
+				}
+				if len(values[f]) == 0 && !primary {
+					values[f] = append(values[f], "")
+				}
+				values[f] = append(values[f], c.Data)
+			}
+		}
+	}
+
+	for _, j := range p.JobInfos {
+		values[Company] = append(values[Company], j.Company)
+		values[Position] = append(values[Position], j.Position)
+		values[CurrentJob] = append(values[CurrentJob], formatBool(j.Current))
+	}
+
+	values[FirstName] = []string{p.FirstName}
+	values[LastName] = []string{p.LastName.String}
+	values[SecondName] = []string{p.SecondName.String}
+	if p.BirthDate.Valid {
+		values[BirthDate] = []string{p.BirthDate.Time.Format(time.DateOnly)}
+	}
+
+	values[BirthdayNotify] = []string{formatBool(p.Settings.BirthdayNotify)}
+	if p.Settings.BirthdayNotifyDaysBefore > 0 {
+		values[BirthdayNotifyDaysBefore] = []string{strconv.Itoa(p.Settings.BirthdayNotifyDaysBefore)}
+	}
+	values[KeepInTouch] = []string{p.Settings.KeepInTouch.String()}
+
+	record := make([]string, len(Fields))
+	for i, f := range Fields {
+		record[i] = strings.Join(values[f], valueSeparator)
+	}
+
+	return record
+}
+
+// formulaPrefixes are the characters spreadsheets start a formula with.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeCell prefixes a cell a spreadsheet would take for a formula with an
+// apostrophe, so that opening the export cannot run it. Normalized phone
+// numbers start with + but are left as they are.
+func escapeCell(f Field, s string) string {
+	if s == "" || !strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return s
+	}
+	if method := contactFields[f]; (method == person.Phone || method == person.WhatsApp) && isPhoneList(s) {
+		return s
+	}
+	return "'" + s
+}
+
+// unescapeCell undoes escapeCell.
+func unescapeCell(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+func isPhoneList(s string) bool {
+	return strings.Trim(s, "+0123456789; ") == ""
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
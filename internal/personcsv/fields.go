@@ -0,0 +1,170 @@
+// Package personcsv converts persons to and from CSV files with one person
+// per row. Contacts are grouped into a column per method and jobs into
+// parallel company, position and current_job columns, several values of a
+// column being separated by semicolons.
+package personcsv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+)
+
+// Field is a column of the CSV format, which is also what a column of an
+// imported file can be mapped to.
+type Field string
+
+const (
+	FirstName                Field = "first_name"
+	LastName                 Field = "last_name"
+	SecondName               Field = "second_name"
+	BirthDate                Field = "birth_date"
+	Phone                    Field = "phone"
+	Email                    Field = "email"
+	Telegram                 Field = "telegram"
+	WhatsApp                 Field = "whatsapp"
+	URL                      Field = "url"
+	Address                  Field = "address"
+	Other                    Field = "other"
+	Company                  Field = "company"
+	Position                 Field = "position"
+	CurrentJob               Field = "current_job"
+	BirthdayNotify           Field = "birthday_notify"
+	BirthdayNotifyDaysBefore Field = "birthday_notify_days_before"
+	KeepInTouch              Field = "keep_in_touch"
+)
+
+// Fields is every field in the order of the exported columns.
+var Fields = []Field{
+	FirstName, LastName, SecondName, BirthDate,
+	Phone, Email, Telegram, WhatsApp, URL, Address, Other,
+	Company, Position, CurrentJob,
+	BirthdayNotify, BirthdayNotifyDaysBefore, KeepInTouch,
+}
+
+// contactFields are the fields holding contacts of the method.
+var contactFields = map[Field]person.ContactMethod{
+	Phone:    person.Phone,
+	Email:    person.Email,
+	Telegram: person.Telegram,
+	WhatsApp: person.WhatsApp,
+	URL:      person.URL,
+	Address:  person.Address,
+	Other:    person.Other,
+}
+
+// fieldAliases are column names other tools use for a field. Names are
+// compared in lower case with spaces, dashes and underscores removed.
+var fieldAliases = map[string]Field{
+	"name":         FirstName,
+	"givenname":    FirstName,
+	"firstname":    FirstName,
+	"familyname":   LastName,
+	"surname":      LastName,
+	"lastname":     LastName,
+	"middlename":   SecondName,
+	"secondname":   SecondName,
+	"patronymic":   SecondName,
+	"birthday":     BirthDate,
+	"birthdate":    BirthDate,
+	"dateofbirth":  BirthDate,
+	"mobile":       Phone,
+	"mobilephone":  Phone,
+	"phonenumber":  Phone,
+	"telephone":    Phone,
+	"mail":         Email,
+	"emailaddress": Email,
+	"website":      URL,
+	"homepage":     URL,
+	"organization": Company,
+	"organisation": Company,
+	"employer":     Company,
+	"jobtitle":     Position,
+	"title":        Position,
+	"notes":        Other,
+}
+
+func normalizeColumnName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(s)))
+}
+
+// Mapping assigns the columns of a file, by index, to fields. Columns that
+// aren't mapped are ignored.
+type Mapping map[Field]int
+
+// GuessMapping maps the columns of the header row whose names are those of
+// a field or a common alias of one. The first column wins when several
+// match the same field.
+func GuessMapping(header []string) Mapping {
+	names := make(map[string]Field, len(Fields)+len(fieldAliases))
+	for alias, f := range fieldAliases {
+		names[alias] = f
+	}
+	for _, f := range Fields {
+		names[normalizeColumnName(string(f))] = f
+	}
+
+	m := make(Mapping)
+	for i, column := range header {
+		f, ok := names[normalizeColumnName(column)]
+		if !ok {
+			continue
+		}
+		if _, taken := m[f]; !taken {
+			m[f] = i
+		}
+	}
+
+	return m
+}
+
+// ParseField returns the field with the name.
+func ParseField(s string) (Field, error) {
+	for _, f := range Fields {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown field %q", s)
+}
+
+// ParseMapping reads a mapping written as field=column pairs separated by
+// commas, where column is the name of a column of the header row.
+func ParseMapping(s string, header []string) (Mapping, error) {
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		if _, ok := columns[column]; !ok {
+			columns[column] = i
+		}
+	}
+
+	m := make(Mapping)
+	for _, pair := range strings.Split(s, ",") {
+		name, column, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("mapping %q is not field=column", pair)
+		}
+
+		f, err := ParseField(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := columns[strings.TrimSpace(column)]
+		if !ok {
+			return nil, fmt.Errorf("there is no column %q", strings.TrimSpace(column))
+		}
+
+		m[f] = i
+	}
+
+	return m, nil
+}
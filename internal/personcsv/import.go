@@ -0,0 +1,463 @@
+package personcsv
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+)
+
+const utf8BOM = "\ufeff"
+
+// ErrEmpty is returned for a file without a header row.
+var ErrEmpty = errors.New("the file is empty")
+
+// maxValueLength is the length of the longest contact, company or position
+// the database holds.
+const maxValueLength = 256
+
+// birthDateLayouts are the accepted formats of birth dates.
+var birthDateLayouts = []string{time.DateOnly, "02.01.2006", "2006/01/02"}
+
+// Store is where imported persons are looked up and saved.
+type Store interface {
+	Lister
+	Insert(ctx context.Context, p *person.Person) error
+}
+
+type Importer struct {
+	db      *sql.DB
+	persons Store
+}
+
+func NewImporter(db *sql.DB, persons Store) *Importer {
+	return &Importer{db: db, persons: persons}
+}
+
+// Row is the outcome of importing a row of the file.
+type Row struct {
+	// Duplicate is set when the person is already known, in which case it
+	// is not imported.
+	Duplicate *Duplicate
+	// Errors are the reasons the row is invalid, each prefixed with the
+	// field at fault.
+	Errors []string
+	Person person.Person
+	// Line is the line of the file the row starts at. The header is line 1.
+	Line int
+}
+
+// Duplicate identifies the person a row duplicates: an existing person or
+// a row above it in the same file.
+type Duplicate struct {
+	Name     string
+	PersonID int
+	Line     int
+}
+
+// Result is the outcome of an import. Nothing is saved unless every row is
+// valid.
+type Result struct {
+	Rows []Row
+	// Applied reports whether the new persons were saved, which doesn't
+	// happen for a dry run or a file with invalid rows.
+	Applied bool
+}
+
+// Invalid returns the number of rows with errors.
+func (r *Result) Invalid() int {
+	n := 0
+	for _, row := range r.Rows {
+		if len(row.Errors) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Duplicates returns the number of rows skipped as duplicates.
+func (r *Result) Duplicates() int {
+	n := 0
+	for _, row := range r.Rows {
+		if row.Duplicate != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// New returns the number of rows that are, or would be, imported.
+func (r *Result) New() int {
+	return len(r.Rows) - r.Invalid() - r.Duplicates()
+}
+
+// ReadHeader returns the header row of the file along with the first row
+// after it, which is nil if there is none.
+func ReadHeader(r io.Reader) ([]string, []string, error) {
+	cr := newReader(r)
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, ErrEmpty
+		}
+		return nil, nil, err
+	}
+
+	first, err := cr.Read()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+
+	return header, first, nil
+}
+
+// Import reads the persons from the file, skipping its header row, and
+// saves the ones that aren't duplicates of existing persons or of rows
+// above them. Duplicates are found by the name along with a phone number
+// or email in common. The file is applied in a single transaction, and
+// only if all rows are valid; a dry run only reports what would happen.
+func (im *Importer) Import(ctx context.Context, r io.Reader, m Mapping, dryRun bool) (*Result, error) {
+	cr := newReader(r)
+
+	if _, err := cr.Read(); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrEmpty
+		}
+		return nil, err
+	}
+
+	result := &Result{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if isBlank(record) {
+			continue
+		}
+
+		line, _ := cr.FieldPos(0)
+		p, errs := parseRecord(record, m)
+		result.Rows = append(result.Rows, Row{Line: line, Person: p, Errors: errs})
+	}
+
+	err := txcontext.Run(ctx, im.db, func(ctx context.Context) error {
+		index := make(duplicateIndex)
+		err := eachPerson(ctx, im.persons, func(p *person.Person) {
+			index.add(p, &Duplicate{Name: p.FullName(), PersonID: p.ID})
+		})
+		if err != nil {
+			return err
+		}
+
+		for i := range result.Rows {
+			row := &result.Rows[i]
+			if len(row.Errors) > 0 {
+				continue
+			}
+
+			if dup := index.find(&row.Person); dup != nil {
+				row.Duplicate = dup
+				continue
+			}
+
+			index.add(&row.Person, &Duplicate{Name: row.Person.FullName(), Line: row.Line})
+		}
+
+		if dryRun || result.Invalid() > 0 {
+			return nil
+		}
+
+		for i := range result.Rows {
+			row := &result.Rows[i]
+			if row.Duplicate != nil {
+				continue
+			}
+
+			if err := im.persons.Insert(ctx, &row.Person); err != nil {
+				return fmt.Errorf("line %d: %w", row.Line, err)
+			}
+		}
+
+		result.Applied = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// newReader reads CSV from r, skipping the byte order mark spreadsheets put
+// at the start of UTF-8 files.
+func newReader(r io.Reader) *csv.Reader {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(len(utf8BOM)); err == nil && string(bom) == utf8BOM {
+		br.Discard(len(utf8BOM))
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return cr
+}
+
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// eachPerson calls fn with every person of the owner in the context,
+// including contacts, jobs and settings.
+func eachPerson(ctx context.Context, persons Lister, fn func(p *person.Person)) error {
+	opts := person.ListOptions{Sort: person.SortByName, PageSize: exportPageSize, Details: true}
+	for opts.Page = 1; ; opts.Page++ {
+		ps, total, err := persons.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		for i := range ps {
+			fn(&ps[i])
+		}
+
+		if opts.Page*exportPageSize >= total {
+			return nil
+		}
+	}
+}
+
+// parseRecord reads the person from the mapped columns of the record,
+// returning the problems found along with it.
+func parseRecord(record []string, m Mapping) (person.Person, []string) {
+	get := func(f Field) string {
+		i, ok := m[f]
+		if !ok || i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(unescapeCell(record[i]))
+	}
+
+	var (
+		p    person.Person
+		errs []string
+	)
+	addError := func(f Field, format string, args ...any) {
+		errs = append(errs, string(f)+": "+fmt.Sprintf(format, args...))
+	}
+
+	p.FirstName = get(FirstName)
+	if p.FirstName == "" {
+		addError(FirstName, "cannot be blank")
+	}
+	for f, name := range map[Field]string{FirstName: p.FirstName, LastName: get(LastName), SecondName: get(SecondName)} {
+		if len([]rune(name)) > 100 {
+			addError(f, "cannot be more than 100 characters long")
+		}
+	}
+	p.LastName = nullString(get(LastName))
+	p.SecondName = nullString(get(SecondName))
+
+	if s := get(BirthDate); s != "" {
+		birthDate, ok := parseBirthDate(s)
+		switch {
+		case !ok:
+			addError(BirthDate, "%q is not a date such as 1990-05-17", s)
+		case birthDate.After(time.Now()):
+			addError(BirthDate, "cannot be in the future")
+		default:
+			p.BirthDate = sql.NullTime{Time: birthDate, Valid: true}
+		}
+	}
+
+	for _, f := range Fields {
+		method, ok := contactFields[f]
+		if !ok {
+			continue
+		}
+
+		for i, data := range splitValues(get(f)) {
+			if data == "" {
+				continue
+			}
+
+			c, err := person.ContactInfo{Method: method, Data: data, Primary: i == 0}.Normalize()
+			if err != nil {
+				addError(f, "%v", err)
+				continue
+			}
+			if len([]rune(c.Data)) > maxValueLength {
+				addError(f, "cannot be more than %d characters long", maxValueLength)
+				continue
+			}
+			p.ContactInfos = append(p.ContactInfos, c)
+		}
+	}
+
+	companies, positions, current := splitValues(get(Company)), splitValues(get(Position)), splitValues(get(CurrentJob))
+	for i := range max(len(companies), len(positions)) {
+		j := person.JobInfo{Company: valueAt(companies, i), Position: valueAt(positions, i), Current: true}
+		if j.Company == "" && j.Position == "" {
+			continue
+		}
+		if j.Company == "" {
+			addError(Company, "job %d has a position but no company", i+1)
+			continue
+		}
+		if len([]rune(j.Company)) > maxValueLength {
+			addError(Company, "job %d cannot be more than %d characters long", i+1, maxValueLength)
+		}
+		if len([]rune(j.Position)) > maxValueLength {
+			addError(Position, "job %d cannot be more than %d characters long", i+1, maxValueLength)
+		}
+
+		if s := valueAt(current, i); s != "" {
+			var ok bool
+			if j.Current, ok = parseBool(s); !ok {
+				addError(CurrentJob, "%q is not yes or no", s)
+			}
+		}
+		p.JobInfos = append(p.JobInfos, j)
+	}
+
+	if s := get(BirthdayNotify); s != "" {
+		var ok bool
+		if p.Settings.BirthdayNotify, ok = parseBool(s); !ok {
+			addError(BirthdayNotify, "%q is not yes or no", s)
+		}
+	}
+
+	if s := get(BirthdayNotifyDaysBefore); s != "" {
+		days, err := strconv.Atoi(s)
+		if err != nil || days < 0 || days > 365 {
+			addError(BirthdayNotifyDaysBefore, "%q is not a number of days from 0 to 365", s)
+		}
+		p.Settings.BirthdayNotifyDaysBefore = days
+	}
+
+	if (p.Settings.BirthdayNotify || p.Settings.BirthdayNotifyDaysBefore > 0) && !p.BirthDate.Valid {
+		addError(BirthdayNotify, "birthday reminders need a birth date")
+	}
+
+	if s := get(KeepInTouch); s != "" {
+		cadence, err := person.ParseCadence(s)
+		if err != nil {
+			addError(KeepInTouch, "%q is not a cadence such as 2w, 3 months or monthly", s)
+		}
+		p.Settings.KeepInTouch = cadence
+	}
+
+	return p, errs
+}
+
+// splitValues splits a column holding several values. Empty values are
+// kept, so that parallel columns stay aligned.
+func splitValues(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	values := strings.Split(s, ";")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
+}
+
+func valueAt(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func parseBirthDate(s string) (time.Time, bool) {
+	for _, layout := range birthDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "yes", "y", "true", "1", "on":
+		return true, true
+	case "no", "n", "false", "0", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// duplicateIndex finds persons by their name and a phone number or email.
+type duplicateIndex map[string][]duplicateCandidate
+
+type duplicateCandidate struct {
+	keys map[string]bool
+	dup  *Duplicate
+}
+
+func (idx duplicateIndex) add(p *person.Person, dup *Duplicate) {
+	keys := duplicateKeys(p)
+	if len(keys) == 0 {
+		return
+	}
+
+	name := nameKey(p)
+	idx[name] = append(idx[name], duplicateCandidate{keys: keys, dup: dup})
+}
+
+func (idx duplicateIndex) find(p *person.Person) *Duplicate {
+	keys := duplicateKeys(p)
+	for _, c := range idx[nameKey(p)] {
+		for key := range keys {
+			if c.keys[key] {
+				return c.dup
+			}
+		}
+	}
+	return nil
+}
+
+func nameKey(p *person.Person) string {
+	return strings.ToLower(strings.Join(strings.Fields(p.FullName()), " "))
+}
+
+// duplicateKeys are the phone numbers and emails of the person. WhatsApp
+// numbers count as phone numbers.
+func duplicateKeys(p *person.Person) map[string]bool {
+	keys := make(map[string]bool)
+	for _, c := range p.ContactInfos {
+		switch c.Method {
+		case person.Phone, person.WhatsApp:
+			keys["phone:"+c.Data] = true
+		case person.Email:
+			keys["email:"+c.Data] = true
+		}
+	}
+	return keys
+}
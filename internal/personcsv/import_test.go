@@ -0,0 +1,121 @@
+package personcsv
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/test"
+	"github.com/stretchr/testify/suite"
+)
+
+const testFile = `Given Name,Family Name,Mobile,E-mail,Organization
+Anna,Petrova,+7 916 123-45-67,,Acme
+Boris,Ivanov,,boris@example.com,
+anna,PETROVA,+79161234567,,
+Vera,Sidorova,,vera@example.com,
+`
+
+type importTestSuite struct {
+	test.TestSuite
+	repo     *person.PersonRepository
+	importer *Importer
+	tx       *sql.Tx
+}
+
+func (suite *importTestSuite) SetupSuite() {
+	suite.TestSuite.SetupSuite()
+	suite.Ctx = owner.WithID(suite.Ctx, users.DefaultID)
+
+	suite.repo = person.NewRepository(suite.DB)
+	suite.importer = NewImporter(suite.DB, suite.repo)
+}
+
+func (suite *importTestSuite) SetupTest() {
+	var err error
+	suite.tx, err = suite.DB.BeginTx(suite.Ctx, nil)
+	suite.Require().NoError(err)
+}
+
+func (suite *importTestSuite) TearDownTest() {
+	err := suite.tx.Rollback()
+	suite.Require().NoError(err)
+}
+
+func (suite *importTestSuite) TestImport() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	existing := person.Person{
+		FirstName:    "Vera",
+		LastName:     sql.NullString{String: "Sidorova", Valid: true},
+		ContactInfos: []person.ContactInfo{{Method: person.Email, Data: "vera@example.com"}},
+	}
+	suite.Require().NoError(suite.repo.Insert(ctx, &existing))
+
+	header, _, err := ReadHeader(strings.NewReader(testFile))
+	suite.Require().NoError(err)
+	m := GuessMapping(header)
+
+	preview, err := suite.importer.Import(ctx, strings.NewReader(testFile), m, true)
+	suite.Require().NoError(err)
+	suite.False(preview.Applied)
+	suite.Require().Len(preview.Rows, 4)
+	suite.Equal(2, preview.New())
+	suite.Equal(2, preview.Duplicates())
+
+	suite.Equal(2, preview.Rows[2].Duplicate.Line)
+	suite.Equal(existing.ID, preview.Rows[3].Duplicate.PersonID)
+
+	_, total, err := suite.repo.List(ctx, person.ListOptions{})
+	suite.Require().NoError(err)
+	suite.Equal(1, total)
+
+	result, err := suite.importer.Import(ctx, strings.NewReader(testFile), m, false)
+	suite.Require().NoError(err)
+	suite.True(result.Applied)
+
+	_, total, err = suite.repo.List(ctx, person.ListOptions{})
+	suite.Require().NoError(err)
+	suite.Equal(3, total)
+
+	anna, err := suite.repo.Get(ctx, result.Rows[0].Person.ID)
+	suite.Require().NoError(err)
+	suite.Equal("+79161234567", anna.ContactInfos[0].Data)
+	suite.Equal("Acme", anna.JobInfos[0].Company)
+
+	again, err := suite.importer.Import(ctx, strings.NewReader(testFile), m, false)
+	suite.Require().NoError(err)
+	suite.Equal(0, again.New())
+}
+
+func (suite *importTestSuite) TestImportInvalidRows() {
+	ctx := txcontext.WithTx(suite.Ctx, suite.tx)
+
+	file := "first_name,phone\nAnna,+79161234567\n,+79167654321\nBoris,not a phone\n"
+
+	header, _, err := ReadHeader(strings.NewReader(file))
+	suite.Require().NoError(err)
+
+	result, err := suite.importer.Import(ctx, strings.NewReader(file), GuessMapping(header), false)
+	suite.Require().NoError(err)
+	suite.False(result.Applied)
+	suite.Equal(2, result.Invalid())
+	suite.Equal(3, result.Rows[1].Line)
+	suite.Contains(result.Rows[1].Errors[0], "first_name")
+	suite.Contains(result.Rows[2].Errors[0], "phone")
+
+	_, total, err := suite.repo.List(ctx, person.ListOptions{})
+	suite.Require().NoError(err)
+	suite.Zero(total)
+
+	_, err = suite.importer.Import(ctx, strings.NewReader(""), Mapping{}, true)
+	suite.ErrorIs(err, ErrEmpty)
+}
+
+func TestImportTestSuite(t *testing.T) {
+	suite.Run(t, new(importTestSuite))
+}
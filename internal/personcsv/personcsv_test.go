@@ -0,0 +1,207 @@
+package personcsv
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPerson() person.Person {
+	return person.Person{
+		FirstName:  "Anna",
+		LastName:   sql.NullString{String: "Petrova", Valid: true},
+		SecondName: sql.NullString{String: "Sergeevna", Valid: true},
+		BirthDate:  sql.NullTime{Time: time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC), Valid: true},
+		ContactInfos: []person.ContactInfo{
+			{Method: person.Phone, Data: "+79161234567"},
+			{Method: person.Phone, Data: "+79167654321", Primary: true},
+			{Method: person.Email, Data: "anna@example.com", Primary: true},
+			{Method: person.Address, Data: "Moscow, Tverskaya 1"},
+		},
+		JobInfos: []person.JobInfo{
+			{Company: "Acme", Position: "Engineer", Current: true},
+			{Company: "Initech", Current: false},
+		},
+		Settings: person.Settings{
+			BirthdayNotify:           true,
+			BirthdayNotifyDaysBefore: 3,
+			KeepInTouch:              person.Cadence{Count: 2, Unit: person.Week},
+		},
+	}
+}
+
+func TestGuessMapping(t *testing.T) {
+	m := GuessMapping([]string{"Given Name", "Family Name", "E-mail", "Mobile Phone", "Notes", "Phone", "first_name"})
+
+	assert.Equal(t, Mapping{FirstName: 0, LastName: 1, Email: 2, Phone: 3, Other: 4}, m)
+}
+
+func TestParseMapping(t *testing.T) {
+	header := []string{"Name", " Cell ", "Mail"}
+
+	m, err := ParseMapping("first_name=Name, phone=Cell,email=Mail", header)
+	require.NoError(t, err)
+	assert.Equal(t, Mapping{FirstName: 0, Phone: 1, Email: 2}, m)
+
+	_, err = ParseMapping("nickname=Name", header)
+	assert.Error(t, err)
+
+	_, err = ParseMapping("phone=Fax", header)
+	assert.Error(t, err)
+
+	_, err = ParseMapping("phone", header)
+	assert.Error(t, err)
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	p := testPerson()
+
+	record := Record(&p)
+	require.Len(t, record, len(Fields))
+
+	m := make(Mapping)
+	for i, f := range Fields {
+		m[f] = i
+	}
+
+	assert.Equal(t, "+79167654321; +79161234567", record[m[Phone]])
+	assert.Equal(t, "; Moscow, Tverskaya 1", record[m[Address]])
+	assert.Equal(t, "Acme; Initech", record[m[Company]])
+	assert.Equal(t, "Engineer; ", record[m[Position]])
+	assert.Equal(t, "yes; no", record[m[CurrentJob]])
+
+	got, errs := parseRecord(record, m)
+	require.Empty(t, errs)
+
+	assert.Equal(t, p.FirstName, got.FirstName)
+	assert.Equal(t, p.LastName, got.LastName)
+	assert.Equal(t, p.SecondName, got.SecondName)
+	assert.True(t, p.BirthDate.Time.Equal(got.BirthDate.Time))
+	assert.ElementsMatch(t, []person.ContactInfo{
+		{Method: person.Phone, Data: "+79167654321", Primary: true},
+		{Method: person.Phone, Data: "+79161234567"},
+		{Method: person.Email, Data: "anna@example.com", Primary: true},
+		{Method: person.Address, Data: "Moscow, Tverskaya 1"},
+	}, got.ContactInfos)
+	assert.Equal(t, p.JobInfos, got.JobInfos)
+	assert.Equal(t, p.Settings, got.Settings)
+}
+
+func TestParseRecordErrors(t *testing.T) {
+	m := Mapping{FirstName: 0, BirthDate: 1, Phone: 2, Position: 3, BirthdayNotify: 4, KeepInTouch: 5}
+
+	_, errs := parseRecord([]string{"", "17/05/1990", "12345", "Engineer", "maybe", "sometimes"}, m)
+
+	assert.Len(t, errs, 6)
+	for i, prefix := range []string{"first_name:", "birth_date:", "phone:", "company:", "birthday_notify:", "keep_in_touch:"} {
+		assert.Contains(t, errs[i], prefix)
+	}
+
+	_, errs = parseRecord([]string{"Anna", "", "", "", "yes"}, m)
+	assert.Equal(t, []string{"birthday_notify: birthday reminders need a birth date"}, errs)
+
+	// Longer values than the database holds are row errors too.
+	long := strings.Repeat("a", 257)
+	m = Mapping{FirstName: 0, Email: 1, Company: 2, Position: 3}
+	_, errs = parseRecord([]string{"Anna", long + "@example.com", long, long}, m)
+	assert.Equal(t, []string{
+		"email: cannot be more than 256 characters long",
+		"company: job 1 cannot be more than 256 characters long",
+		"position: job 1 cannot be more than 256 characters long",
+	}, errs)
+}
+
+func TestImportParseError(t *testing.T) {
+	// The malformed line comes after the rows ReadHeader checks, and is
+	// reported before the database is used.
+	file := "first_name\nAnna\nBoris\n\"Vera\n"
+
+	_, _, err := ReadHeader(strings.NewReader(file))
+	require.NoError(t, err)
+
+	_, err = NewImporter(nil, nil).Import(context.Background(), strings.NewReader(file), Mapping{FirstName: 0}, true)
+	var parseErr *csv.ParseError
+	assert.ErrorAs(t, err, &parseErr)
+}
+
+func TestEscapeCell(t *testing.T) {
+	tests := []struct {
+		field Field
+		cell  string
+		want  string
+	}{
+		{FirstName, "Anna", "Anna"},
+		{FirstName, "=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{Position, "-2+3", "'-2+3"},
+		{Other, "@SUM(A1)", "'@SUM(A1)"},
+		{Other, "+1 call me", "'+1 call me"},
+		{Phone, "+79167654321; +79161234567", "+79167654321; +79161234567"},
+		{WhatsApp, "+79167654321", "+79167654321"},
+		{Phone, "+7=1", "'+7=1"},
+	}
+
+	for _, tt := range tests {
+		got := escapeCell(tt.field, tt.cell)
+		assert.Equal(t, tt.want, got, tt.cell)
+		assert.Equal(t, tt.cell, unescapeCell(got), tt.cell)
+	}
+}
+
+type fakeLister []person.Person
+
+func (l fakeLister) List(_ context.Context, opts person.ListOptions) ([]person.Person, int, error) {
+	limit, offset := opts.PageSize, (opts.Page-1)*opts.PageSize
+	end := min(offset+limit, len(l))
+	return l[min(offset, end):end], len(l), nil
+}
+
+func TestExport(t *testing.T) {
+	persons := make(fakeLister, exportPageSize+1)
+	for i := range persons {
+		persons[i] = testPerson()
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(context.Background(), &buf, persons))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, len(persons)+1)
+	assert.Equal(t, "first_name", records[0][0])
+	assert.Equal(t, "Anna", records[len(persons)][0])
+}
+
+func TestExportEscapesFormulas(t *testing.T) {
+	p := testPerson()
+	p.FirstName = "=cmd|' /C calc'!A0"
+	p.ContactInfos = append(p.ContactInfos, person.ContactInfo{Method: person.Other, Data: "@risky", Primary: true})
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(context.Background(), &buf, fakeLister{p}))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	m := make(Mapping)
+	for i, f := range Fields {
+		m[f] = i
+	}
+	record := records[1]
+	assert.Equal(t, "'=cmd|' /C calc'!A0", record[m[FirstName]])
+	assert.Equal(t, "'@risky", record[m[Other]])
+	assert.Equal(t, "+79167654321; +79161234567", record[m[Phone]])
+
+	got, errs := parseRecord(record, m)
+	require.Empty(t, errs)
+	assert.Equal(t, p.FirstName, got.FirstName)
+	assert.Contains(t, got.ContactInfos, person.ContactInfo{Method: person.Other, Data: "@risky", Primary: true})
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/callback"
+	"github.com/lincentpega/personal-crm/internal/common/txcontext"
+	"github.com/lincentpega/personal-crm/internal/models/interactions"
+	"github.com/lincentpega/personal-crm/internal/models/notifications"
+	"github.com/lincentpega/personal-crm/internal/notifier"
+)
+
+var (
+	ErrActionNotAllowed = errors.New("reminder action is not allowed")
+	ErrAlreadyHandled   = errors.New("reminder is already handled")
+)
+
+// reminderActions returns the buttons offered on a raised reminder. The
+// callback data is signed for the recipient, so the buttons only work for
+// them and only for this notification.
+func (s *NotificationService) reminderActions(n *notifications.Notification, recipient int64) []notifier.Action {
+	encode := func(a callback.Action) string {
+		return s.signer.Encode(callback.Payload{Action: a, NotificationID: n.ID, UserID: recipient})
+	}
+
+	return []notifier.Action{
+		{Text: "Done", Data: encode(callback.Done)},
+		{Text: "Snooze 1d", Data: encode(callback.Snooze1d)},
+		{Text: "Snooze 1w", Data: encode(callback.Snooze1w)},
+		{Text: "Skip", Data: encode(callback.Skip)},
+	}
+}
+
+// HandleReminderAction applies a reminder button pressed by the Telegram
+// user userID and returns a short description of the outcome.
+func (s *NotificationService) HandleReminderAction(ctx context.Context, data string, userID int64) (string, error) {
+	payload, err := s.signer.Decode(data)
+	if err != nil {
+		return "", err
+	}
+
+	if payload.UserID != userID {
+		return "", ErrActionNotAllowed
+	}
+
+	n, err := s.notificationsRepo.Get(ctx, payload.NotificationID)
+	if err != nil {
+		return "", err
+	}
+
+	switch payload.Action {
+	case callback.Done:
+		return s.acknowledge(ctx, n)
+	case callback.Snooze1d:
+		return s.snooze(ctx, n, 24*time.Hour)
+	case callback.Snooze1w:
+		return s.snooze(ctx, n, 7*24*time.Hour)
+	case callback.Skip:
+		return s.skip(ctx, n)
+	default:
+		return "", callback.ErrInvalid
+	}
+}
+
+func (s *NotificationService) transition(ctx context.Context, n *notifications.Notification, to notifications.Status) error {
+	ok, err := s.notificationsRepo.TransitionStatus(ctx, n.ID, notifications.Raised, to)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrAlreadyHandled
+	}
+
+	return nil
+}
+
+// acknowledge logs an interaction with the person, which pushes their next
+// keep-in-touch reminder one cadence period from now. It all happens in one
+// transaction, so a failure leaves the reminder raised and the button can be
+// pressed again.
+func (s *NotificationService) acknowledge(ctx context.Context, n *notifications.Notification) (string, error) {
+	err := txcontext.Run(ctx, s.db, func(ctx context.Context) error {
+		if err := s.transition(ctx, n, notifications.Acknowledged); err != nil {
+			return err
+		}
+
+		interaction := &interactions.Interaction{
+			Kind:       interactions.Message,
+			OccurredAt: time.Now().UTC(),
+			Note:       "Marked done from a reminder",
+			PersonIDs:  []int{n.PersonID},
+		}
+		if err := s.interactionRepo.Insert(ctx, interaction); err != nil {
+			return err
+		}
+
+		return s.RescheduleKeepInTouch(ctx, n.PersonID)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "Done", nil
+}
+
+func (s *NotificationService) snooze(ctx context.Context, n *notifications.Notification, d time.Duration) (string, error) {
+	snoozed := &notifications.Notification{
+		PersonID:         n.PersonID,
+		Type:             n.Type,
+		Status:           notifications.Pending,
+		NotificationTime: time.Now().UTC().Add(d),
+		Description:      n.Description,
+		Channels:         n.Channels,
+	}
+
+	err := txcontext.Run(ctx, s.db, func(ctx context.Context) error {
+		if err := s.transition(ctx, n, notifications.Snoozed); err != nil {
+			return err
+		}
+
+		return s.notificationsRepo.Insert(ctx, snoozed)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "Snoozed until " + snoozed.NotificationTime.Format("02 Jan 15:04 MST"), nil
+}
+
+// skip leaves the reminder unanswered. The next keep-in-touch reminder was
+// already scheduled when this one was raised.
+func (s *NotificationService) skip(ctx context.Context, n *notifications.Notification) (string, error) {
+	if err := s.transition(ctx, n, notifications.Skipped); err != nil {
+		return "", err
+	}
+
+	return "Skipped", nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/models/notifications"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/lincentpega/personal-crm/internal/notifier"
+)
+
+// birthdayNotificationHour is the UTC hour at which birthday notifications
+// are raised.
+const birthdayNotificationHour = 9
+
+func (s *NotificationService) scheduleBirthdays(ctx context.Context) error {
+	persons, err := s.personRepo.ListBirthdayNotify(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, p := range persons {
+		if err := s.scheduleBirthday(owner.WithID(ctx, p.OwnerID), &p, now); err != nil {
+			s.log.ErrorLog.Printf("Failed to schedule birthday notification: personID %d: %v", p.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// scheduleBirthday creates the notifications for the next birthday of p
+// that were not created already. Each time is checked on its own, so raising
+// the days before later still adds the heads-up. Notifications for a birthday that is today count
+// as the next birthday until the day is over, so a raised notification is not
+// recreated on the same day.
+func (s *NotificationService) scheduleBirthday(ctx context.Context, p *person.Person, now time.Time) error {
+	next := person.NextBirthday(p.BirthDate.Time, now)
+
+	times := []time.Time{next.Add(birthdayNotificationHour * time.Hour)}
+	if p.Settings.BirthdayNotifyDaysBefore > 0 {
+		headsUp := next.AddDate(0, 0, -p.Settings.BirthdayNotifyDaysBefore)
+		if t := headsUp.Add(birthdayNotificationHour * time.Hour); t.After(now) {
+			times = append(times, t)
+		}
+	}
+
+	age := person.AgeOn(p.BirthDate.Time, next)
+	for _, t := range times {
+		exists, err := s.notificationsRepo.ExistsAt(ctx, p.ID, notifications.Birthday, t)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		err = s.notificationsRepo.Insert(ctx, &notifications.Notification{
+			PersonID:         p.ID,
+			NotificationTime: t,
+			Status:           notifications.Pending,
+			Type:             notifications.Birthday,
+			Description:      fmt.Sprintf("%s turns %d on %s", p.FullName(), age, next.Format("2 January")),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *NotificationService) processBirthday(ctx context.Context, n *notifications.Notification) {
+	person, err := s.personRepo.Get(ctx, n.PersonID)
+	if err != nil {
+		s.log.ErrorLog.Printf("Failed to load person: personID %d: %v", n.PersonID, err)
+		s.handleError(ctx, n, err)
+		return
+	}
+
+	if !person.BirthDate.Valid || !person.Settings.BirthdayNotify {
+		s.markNotificationsRaised(ctx, n)
+		return
+	}
+
+	msg := notifier.Message{
+		Subject: person.FullName() + "'s birthday",
+		Text:    birthdayMessage(person, n.NotificationTime),
+	}
+	err = s.send(ctx, n, msg)
+	if err != nil {
+		s.handleError(ctx, n, err)
+		return
+	}
+
+	s.markNotificationsRaised(ctx, n)
+}
+
+func birthdayMessage(p *person.Person, at time.Time) string {
+	birthday := person.NextBirthday(p.BirthDate.Time, at)
+	age := person.AgeOn(p.BirthDate.Time, birthday)
+
+	today := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(birthday.Sub(today).Hours() / 24)
+
+	switch days {
+	case 0:
+		return fmt.Sprintf("Today is %s's birthday, turning %d", p.FullName(), age)
+	case 1:
+		return fmt.Sprintf("Tomorrow is %s's birthday, turning %d", p.FullName(), age)
+	default:
+		return fmt.Sprintf("In %d days, on %s, is %s's birthday, turning %d",
+			days, birthday.Format("2 January"), p.FullName(), age)
+	}
+}
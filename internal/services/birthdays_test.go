@@ -0,0 +1,27 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBirthdayMessage(t *testing.T) {
+	p := &person.Person{
+		FirstName: "John",
+		LastName:  sql.NullString{String: "Smith", Valid: true},
+		BirthDate: sql.NullTime{Time: time.Date(2000, time.February, 29, 0, 0, 0, 0, time.UTC), Valid: true},
+	}
+
+	at := time.Date(2025, time.February, 28, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, "Today is John Smith's birthday, turning 25", birthdayMessage(p, at))
+
+	at = time.Date(2025, time.February, 27, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, "Tomorrow is John Smith's birthday, turning 25", birthdayMessage(p, at))
+
+	at = time.Date(2028, time.February, 22, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, "In 7 days, on 29 February, is John Smith's birthday, turning 28", birthdayMessage(p, at))
+}
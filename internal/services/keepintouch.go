@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/common/owner"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/notifications"
+	"github.com/lincentpega/personal-crm/internal/models/person"
+)
+
+// RecordContact stores that the person was contacted at the given time and
+// pushes their pending keep-in-touch reminder one cadence period past it.
+func (s *NotificationService) RecordContact(ctx context.Context, personID int, at time.Time) error {
+	if err := s.personRepo.TouchLastContacted(ctx, personID, at.UTC()); err != nil {
+		return err
+	}
+
+	return s.RescheduleKeepInTouch(ctx, personID)
+}
+
+// RescheduleKeepInTouch moves the pending keep-in-touch reminder of the
+// person to its next occurrence, creating it when there is none.
+func (s *NotificationService) RescheduleKeepInTouch(ctx context.Context, personID int) error {
+	p, err := s.personRepo.Get(ctx, personID)
+	if err != nil {
+		return err
+	}
+
+	return s.scheduleKeepInTouch(ctx, p, time.Now().UTC(), true)
+}
+
+func (s *NotificationService) scheduleKeepInTouches(ctx context.Context) error {
+	persons, err := s.personRepo.ListKeepInTouch(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, p := range persons {
+		if err := s.scheduleKeepInTouch(owner.WithID(ctx, p.OwnerID), &p, now, false); err != nil {
+			s.log.ErrorLog.Printf("Failed to schedule keep in touch notification: personID %d: %v", p.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// scheduleKeepInTouch makes sure the person has a pending keep-in-touch
+// reminder. An existing reminder is only moved when move is set.
+func (s *NotificationService) scheduleKeepInTouch(ctx context.Context, p *person.Person, now time.Time, move bool) error {
+	if p.Settings.KeepInTouch.IsZero() {
+		return nil
+	}
+
+	next := nextKeepInTouch(p, now)
+
+	pending, err := s.notificationsRepo.GetPending(ctx, p.ID, notifications.KeepInTouch)
+	switch {
+	case err == nil:
+		if !move || pending.NotificationTime.Equal(next) {
+			return nil
+		}
+		err := s.notificationsRepo.Reschedule(ctx, pending.ID, next)
+		if errors.Is(err, notifications.ErrNotPending) {
+			// A sender claimed the reminder in the meantime. It schedules
+			// the next one from the last contact once it is done.
+			return nil
+		}
+		return err
+	case errors.Is(err, models.ErrRecordNotFound):
+		return s.notificationsRepo.Insert(ctx, &notifications.Notification{
+			PersonID:         p.ID,
+			NotificationTime: next,
+			Status:           notifications.Pending,
+			Type:             notifications.KeepInTouch,
+		})
+	default:
+		return err
+	}
+}
+
+// contactedWithinCadence reports whether the person was contacted less than
+// one cadence period ago, in which case a due reminder is postponed to the
+// returned time instead of being sent. This covers interactions logged by
+// clients that do not reschedule reminders themselves.
+func contactedWithinCadence(p *person.Person, now time.Time) (time.Time, bool) {
+	if p.Settings.KeepInTouch.IsZero() || !p.LastContactedAt.Valid {
+		return time.Time{}, false
+	}
+
+	next := p.Settings.KeepInTouch.Next(p.LastContactedAt.Time.UTC())
+	return next, next.After(now)
+}
+
+// nextKeepInTouch counts the next reminder from the last contact with the
+// person, so that contacting someone early pushes the reminder back. When
+// that moment has already passed, or the person was never contacted, the
+// reminder is counted from now.
+func nextKeepInTouch(p *person.Person, now time.Time) time.Time {
+	cadence := p.Settings.KeepInTouch
+
+	if p.LastContactedAt.Valid {
+		if next := cadence.Next(p.LastContactedAt.Time.UTC()); next.After(now) {
+			return next
+		}
+	}
+
+	return cadence.Next(now)
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextKeepInTouch(t *testing.T) {
+	now := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+	cadence := person.Cadence{Count: 2, Unit: person.Week}
+
+	p := &person.Person{Settings: person.Settings{KeepInTouch: cadence}}
+	assert.Equal(t, now.AddDate(0, 0, 14), nextKeepInTouch(p, now))
+
+	p.LastContactedAt = sql.NullTime{Time: now.AddDate(0, 0, -3), Valid: true}
+	assert.Equal(t, now.AddDate(0, 0, 11), nextKeepInTouch(p, now))
+
+	p.LastContactedAt = sql.NullTime{Time: now.AddDate(0, -1, 0), Valid: true}
+	assert.Equal(t, now.AddDate(0, 0, 14), nextKeepInTouch(p, now))
+}
@@ -2,30 +2,53 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"slices"
 	"time"
 
+	"github.com/lincentpega/personal-crm/internal/common/callback"
+	"github.com/lincentpega/personal-crm/internal/common/owner"
 	"github.com/lincentpega/personal-crm/internal/config"
 	"github.com/lincentpega/personal-crm/internal/log"
+	"github.com/lincentpega/personal-crm/internal/models"
+	"github.com/lincentpega/personal-crm/internal/models/interactions"
 	"github.com/lincentpega/personal-crm/internal/models/notifications"
 	"github.com/lincentpega/personal-crm/internal/models/person"
-	"gopkg.in/telebot.v3"
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/lincentpega/personal-crm/internal/notifier"
 )
 
+// ErrNoRecipient is returned when none of the channels of a notification can
+// reach its owner.
+var ErrNoRecipient = errors.New("no channel of the notification reaches its owner")
+
 type NotificationService struct {
-	bot               *telebot.Bot
+	db                *sql.DB
+	notifier          *notifier.Notifier
+	signer            *callback.Signer
 	notificationsRepo *notifications.NotificationRepository
 	personRepo        *person.PersonRepository
+	interactionRepo   *interactions.InteractionRepository
+	userRepo          *users.UserRepository
 	log               *log.Logger
 	config            *config.AppConfig
 }
 
-func NewNotificationService(bot *telebot.Bot, notificationsRepo *notifications.NotificationRepository,
-	personRepo *person.PersonRepository, log *log.Logger, config *config.AppConfig) *NotificationService {
+func NewNotificationService(db *sql.DB, notifier *notifier.Notifier, signer *callback.Signer,
+	notificationsRepo *notifications.NotificationRepository, personRepo *person.PersonRepository,
+	interactionRepo *interactions.InteractionRepository, userRepo *users.UserRepository, log *log.Logger,
+	config *config.AppConfig) *NotificationService {
 	return &NotificationService{
-		bot:               bot,
+		db:                db,
+		notifier:          notifier,
+		signer:            signer,
 		notificationsRepo: notificationsRepo,
 		personRepo:        personRepo,
+		interactionRepo:   interactionRepo,
+		userRepo:          userRepo,
 		log:               log,
 		config:            config,
 	}
@@ -41,23 +64,64 @@ func (s *NotificationService) ProcessNotifications(ctx context.Context) {
 			s.log.InfoLog.Print("Stopping schedulled notifications processing")
 			return
 		case <-ticker.C:
-			s.execProcessNotifications(ctx)
+			if err := s.execProcessNotifications(ctx); err != nil {
+				s.log.ErrorLog.Printf("Failed to claim due notifications: %v", err)
+			}
 		}
 	}
 }
 
+// ScheduleNotifications periodically creates the notifications derived from
+// person settings: upcoming birthdays and keep-in-touch reminders.
+func (s *NotificationService) ScheduleNotifications(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if err := s.scheduleBirthdays(ctx); err != nil {
+			s.log.ErrorLog.Printf("Failed to schedule birthday notifications: %v", err)
+		}
+
+		if err := s.scheduleKeepInTouches(ctx); err != nil {
+			s.log.ErrorLog.Printf("Failed to schedule keep in touch notifications: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			s.log.InfoLog.Print("Stopping notifications scheduling")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimBatchSize limits how many notifications one instance claims per tick.
+const claimBatchSize = 20
+
 func (s *NotificationService) execProcessNotifications(ctx context.Context) error {
-	ns, err := s.notificationsRepo.GetAwaitingSend(ctx)
+	reaped, err := s.notificationsRepo.ReapExpiredLeases(ctx)
+	if err != nil {
+		s.log.ErrorLog.Printf("Failed to reap expired notification leases: %v", err)
+	} else if reaped > 0 {
+		s.log.InfoLog.Printf("Returned %d notifications with expired leases to pending", reaped)
+	}
+
+	ns, err := s.notificationsRepo.ClaimDue(ctx, claimBatchSize)
 	if err != nil {
 		return err
 	}
 
 	for _, n := range ns {
+		ctx := owner.WithID(ctx, n.OwnerID)
+
 		switch n.Type {
 		case notifications.KeepInTouch:
 			go s.processKeepInTouch(ctx, &n)
+		case notifications.Birthday:
+			go s.processBirthday(ctx, &n)
 		default:
 			s.log.ErrorLog.Print("Notification type is not defined yet")
+			s.failNotification(ctx, &n, fmt.Errorf("unknown notification type %q", n.Type))
 		}
 	}
 
@@ -67,37 +131,136 @@ func (s *NotificationService) execProcessNotifications(ctx context.Context) erro
 func (s *NotificationService) processKeepInTouch(ctx context.Context, n *notifications.Notification) {
 	person, err := s.personRepo.Get(ctx, n.PersonID)
 	if err != nil {
-		s.log.ErrorLog.Printf("Failed to load person: personID %d. Notification moved to failed", n.PersonID)
-		s.failNotification(ctx, n)
+		s.log.ErrorLog.Printf("Failed to load person: personID %d: %v", n.PersonID, err)
+		s.handleError(ctx, n, err)
 		return
 	}
 
-	var lastName string
-	if person.LastName.Valid {
-		lastName = person.LastName.String
-	} else {
-		lastName = ""
+	if next, ok := contactedWithinCadence(person, time.Now().UTC()); ok {
+		err := s.notificationsRepo.RescheduleClaimed(ctx, n.ID, n.LeaseExpiresAt.Time, next)
+		if errors.Is(err, notifications.ErrLeaseLost) {
+			s.log.ErrorLog.Printf("Notification %d lease was lost before it was postponed", n.ID)
+		} else if err != nil {
+			s.log.ErrorLog.Printf("Failed to postpone notification: notificationID %d: %v", n.ID, err)
+		}
+		return
 	}
 
-	msg := fmt.Sprintf("It's time to contact with %s %s", person.FirstName, lastName)
-	_, err = s.bot.Send(telebot.ChatID(s.config.UserID), msg)
+	msg := notifier.Message{
+		Subject: "Keep in touch with " + person.FullName(),
+		Text:    fmt.Sprintf("It's time to contact with %s", person.FullName()),
+	}
+	err = s.send(ctx, n, msg)
 	if err != nil {
-		s.failNotification(ctx, n)
+		s.handleError(ctx, n, err)
+		return
 	}
 
 	s.markNotificationsRaised(ctx, n)
+
+	if err := s.RescheduleKeepInTouch(ctx, n.PersonID); err != nil {
+		s.log.ErrorLog.Printf("Failed to schedule next keep in touch notification: personID %d: %v", n.PersonID, err)
+	}
 }
 
-func (s *NotificationService) failNotification(ctx context.Context, n *notifications.Notification) {
-	err := s.notificationsRepo.UpdateNotificationStatus(ctx, n.ID, notifications.Failed)
+// send delivers the reminder to the owner of the notification, with the
+// reminder actions signed for them. Channels that delivered it on an earlier
+// attempt are skipped.
+func (s *NotificationService) send(ctx context.Context, n *notifications.Notification, msg notifier.Message) error {
+	u, err := s.userRepo.Get(ctx, n.OwnerID)
 	if err != nil {
+		return err
+	}
+
+	channels := deliveryChannels(s.notifier.Channels(n.Channels), u)
+	if len(channels) == 0 {
+		return ErrNoRecipient
+	}
+
+	channels = undelivered(channels, n.DeliveredChannels)
+	if len(channels) == 0 {
+		return nil
+	}
+
+	if u.TelegramID.Valid {
+		msg.TelegramChatID = u.TelegramID.Int64
+		msg.Actions = s.reminderActions(n, u.TelegramID.Int64)
+	}
+
+	delivered, err := s.notifier.Send(ctx, channels, msg)
+	if len(delivered) > 0 {
+		// Recorded right away, so that a retry after a failure of another
+		// channel or a crash doesn't deliver the reminder twice.
+		if err := s.notificationsRepo.AddDeliveredChannels(ctx, n.ID, n.LeaseExpiresAt.Time, delivered); err != nil {
+			s.log.ErrorLog.Printf("Failed to record notification deliveries: notificationID %d: %v", n.ID, err)
+		}
+		n.DeliveredChannels = append(n.DeliveredChannels, delivered...)
+	}
+
+	return err
+}
+
+// undelivered returns the channels that are not among delivered.
+func undelivered(channels, delivered []string) []string {
+	return slices.DeleteFunc(slices.Clone(channels), func(name string) bool {
+		return slices.Contains(delivered, name)
+	})
+}
+
+// deliveryChannels leaves out of channels those that would deliver to
+// someone other than the owner: the Telegram channel when the owner has no
+// linked Telegram chat, as its default chat belongs to the bot admin, and
+// the email and webhook channels for all but the default user, as their
+// recipients are set for the whole deployment.
+func deliveryChannels(channels []string, u *users.User) []string {
+	return slices.DeleteFunc(slices.Clone(channels), func(name string) bool {
+		if name == notifier.TelegramChannelName {
+			return !u.TelegramID.Valid
+		}
+		return notifier.SharedRecipient(name) && u.ID != users.DefaultID
+	})
+}
+
+// handleError retries the notification later unless the error is permanent
+// or the notification ran out of attempts. Only the final outcome changes the
+// status; intermediate failures keep the notification pending.
+func (s *NotificationService) handleError(ctx context.Context, n *notifications.Notification, cause error) {
+	attempt := n.Attempts + 1
+	permanent := errors.Is(cause, models.ErrRecordNotFound) || errors.Is(cause, ErrNoRecipient)
+	if permanent || attempt >= s.config.NotifyMaxAttempts {
+		s.failNotification(ctx, n, cause)
+		return
+	}
+
+	retryAt := time.Now().UTC().Add(backoff(attempt, rand.Int64N))
+	err := s.notificationsRepo.ScheduleRetry(ctx, n.ID, n.LeaseExpiresAt.Time, cause.Error(), retryAt)
+	if errors.Is(err, notifications.ErrLeaseLost) {
+		s.log.ErrorLog.Printf("Notification %d lease was lost before its retry was scheduled", n.ID)
+		return
+	} else if err != nil {
+		s.log.ErrorLog.Printf("Failed to schedule notification retry: notificationID %d: %v", n.ID, err)
+		return
+	}
+
+	s.log.InfoLog.Printf("Notification %d failed on attempt %d, retrying at %s", n.ID, attempt, retryAt.Format(time.RFC3339))
+}
+
+func (s *NotificationService) failNotification(ctx context.Context, n *notifications.Notification, cause error) {
+	s.log.ErrorLog.Printf("Notification %d moved to failed: %v", n.ID, cause)
+
+	err := s.notificationsRepo.Fail(ctx, n.ID, n.LeaseExpiresAt.Time, cause.Error())
+	if errors.Is(err, notifications.ErrLeaseLost) {
+		s.log.ErrorLog.Printf("Notification %d lease was lost before it was marked as failed", n.ID)
+	} else if err != nil {
 		s.log.ErrorLog.Print("Failed to mark notification as failed")
 	}
 }
 
 func (s *NotificationService) markNotificationsRaised(ctx context.Context, n *notifications.Notification) {
-	err := s.notificationsRepo.UpdateNotificationStatus(ctx, n.ID, notifications.Raised)
-	if err != nil {
-		s.log.ErrorLog.Print("Failed to mark notification as failed")
+	err := s.notificationsRepo.UpdateNotificationStatus(ctx, n.ID, n.LeaseExpiresAt.Time, notifications.Raised)
+	if errors.Is(err, notifications.ErrLeaseLost) {
+		s.log.ErrorLog.Printf("Notification %d lease was lost before it was marked as raised", n.ID)
+	} else if err != nil {
+		s.log.ErrorLog.Print("Failed to mark notification as raised")
 	}
 }
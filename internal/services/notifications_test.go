@@ -0,0 +1,34 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/lincentpega/personal-crm/internal/models/users"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryChannels(t *testing.T) {
+	channels := []string{"telegram", "email", "webhook"}
+
+	linked := &users.User{ID: users.DefaultID, TelegramID: sql.NullInt64{Int64: 419672615, Valid: true}}
+	assert.Equal(t, []string{"telegram", "email", "webhook"}, deliveryChannels(channels, linked))
+
+	unlinked := &users.User{ID: users.DefaultID}
+	assert.Equal(t, []string{"email", "webhook"}, deliveryChannels(channels, unlinked))
+	assert.Empty(t, deliveryChannels([]string{"telegram"}, unlinked))
+	assert.Equal(t, []string{"telegram", "email", "webhook"}, channels)
+
+	// The mailbox and the webhook are the deployment's, not other users'.
+	other := &users.User{ID: users.DefaultID + 1, TelegramID: sql.NullInt64{Int64: 1001, Valid: true}}
+	assert.Equal(t, []string{"telegram"}, deliveryChannels(channels, other))
+	assert.Empty(t, deliveryChannels([]string{"email"}, &users.User{ID: users.DefaultID + 1}))
+}
+
+func TestUndelivered(t *testing.T) {
+	channels := []string{"telegram", "email", "webhook"}
+
+	assert.Equal(t, []string{"telegram", "webhook"}, undelivered(channels, []string{"email"}))
+	assert.Equal(t, channels, undelivered(channels, nil))
+	assert.Empty(t, undelivered(channels, channels))
+}
@@ -0,0 +1,24 @@
+package services
+
+import "time"
+
+const (
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// backoff returns the delay before the given retry attempt, counted from 1.
+// The delay doubles with every attempt up to retryMaxDelay, and its upper
+// half is randomized by randInt64N so that failed notifications do not all
+// retry at once.
+func backoff(attempt int, randInt64N func(int64) int64) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 32 {
+		if d := retryBaseDelay << (attempt - 1); d > 0 && d < retryMaxDelay {
+			delay = d
+		}
+	}
+
+	half := delay / 2
+	return half + time.Duration(randInt64N(int64(half)+1))
+}
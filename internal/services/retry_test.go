@@ -0,0 +1,20 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	noJitter := func(int64) int64 { return 0 }
+	maxJitter := func(n int64) int64 { return n - 1 }
+
+	assert.Equal(t, 15*time.Second, backoff(1, noJitter))
+	assert.Equal(t, 30*time.Second, backoff(1, maxJitter))
+	assert.Equal(t, 30*time.Second, backoff(2, noJitter))
+	assert.Equal(t, 4*time.Minute, backoff(4, maxJitter))
+	assert.Equal(t, 30*time.Minute, backoff(10, noJitter))
+	assert.Equal(t, time.Hour, backoff(100, maxJitter))
+}
@@ -0,0 +1,35 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator(t *testing.T) {
+	var v Validator
+	assert.True(t, v.Valid())
+
+	v.CheckField(NotBlank("Anna"), "first_name", "must not be blank")
+	v.CheckField(MaxChars("Анна", 4), "first_name", "is too long")
+	assert.True(t, v.Valid())
+
+	v.CheckField(NotBlank("  "), "first_name", "must not be blank")
+	v.CheckField(MaxChars("Анна", 3), "first_name", "is too long")
+	v.CheckField(Between(366, 0, 365), "days", "must be between 0 and 365")
+
+	assert.False(t, v.Valid())
+	assert.Equal(t, map[string]string{
+		"first_name": "must not be blank",
+		"days":       "must be between 0 and 365",
+	}, v.FieldErrors)
+}
+
+func TestNonFieldErrors(t *testing.T) {
+	var v Validator
+
+	v.AddNonFieldError("Email or password is incorrect")
+
+	assert.False(t, v.Valid())
+	assert.Equal(t, []string{"Email or password is incorrect"}, v.NonFieldErrors)
+}
@@ -0,0 +1,496 @@
+package vcard
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+)
+
+// ErrNoName is returned for a card without a name or an organization to
+// name the person after.
+var ErrNoName = errors.New("vcard: card has no name")
+
+// regenerated are properties that describe the card rather than the person
+// and are written anew on export.
+var regenerated = map[string]bool{"PRODID": true, "REV": true}
+
+// bdayLayouts are the formats of a birthday with a year, in both versions.
+var bdayLayouts = []string{"2006-01-02", "20060102"}
+
+// ToPerson converts the card to a person. Properties with no field of
+// their own, and values that can't be stored in one, such as a birthday
+// without a year or a local phone number, go to VCardExtra. So do contacts
+// and organizations with more to them than their fields hold, such as a
+// WORK type or a group, for FromPerson to write them back as they were.
+func ToPerson(c *Card) (person.Person, error) {
+	var (
+		p      person.Person
+		extra  []string
+		orgs   []Property
+		titles []string
+		// kept are the properties written back in place of the contacts
+		// and jobs they map to. They come first, in the order they are
+		// written back, for VCardExtra to stay the same on reimport.
+		kept []string
+	)
+	keep := func(prop Property) {
+		extra = append(extra, prop.String())
+	}
+	keepAsWritten := func(prop Property) {
+		kept = append(kept, prop.String())
+	}
+	primary := make(map[person.ContactMethod]bool)
+
+	for _, prop := range c.Properties {
+		switch prop.Name {
+		case "N":
+			n := prop.Components()
+			p.LastName = nullString(component(n, 0))
+			p.FirstName = component(n, 1)
+			p.SecondName = nullString(component(n, 2))
+			if component(n, 3) != "" || component(n, 4) != "" {
+				keep(Property{Name: "X-CRM-N", Value: prop.Value})
+			}
+		case "FN":
+			// The formatted name is derived from N on export.
+		case "BDAY":
+			birthDate, ok := parseBirthday(prop.Text())
+			if !ok || p.BirthDate.Valid {
+				keep(prop)
+				continue
+			}
+			p.BirthDate = sql.NullTime{Time: birthDate, Valid: true}
+		case "TEL", "EMAIL", "URL", "IMPP", "X-SOCIALPROFILE", "ADR", "X-CRM-OTHER":
+			ci, ok := contactOf(prop)
+			if !ok {
+				keep(prop)
+				continue
+			}
+			if isPreferred(prop) && !primary[ci.Method] {
+				ci.Primary = true
+				primary[ci.Method] = true
+			}
+			p.ContactInfos = append(p.ContactInfos, ci)
+			// The property as written is kept for export, which writes it
+			// in place of the contact while the contact is there. Whether
+			// it is preferred follows the contact.
+			if isLossy(prop) {
+				keepAsWritten(withPreferred(prop, false, c.Version))
+			}
+		case "ORG":
+			orgs = append(orgs, prop)
+		case "TITLE":
+			titles = append(titles, prop.Text())
+		default:
+			if !regenerated[prop.Name] {
+				keep(prop)
+			}
+		}
+	}
+
+	for i, org := range orgs {
+		j := person.JobInfo{Company: component(org.Components(), 0), Current: true}
+		if i < len(titles) {
+			j.Position = titles[i]
+		}
+		if current := org.Params["X-CRM-CURRENT"]; len(current) > 0 && strings.EqualFold(current[0], "false") {
+			j.Current = false
+		}
+		if j.Company == "" {
+			keep(org)
+			if j.Position != "" {
+				keep(Property{Name: "TITLE", Value: Escape(j.Position)})
+			}
+			continue
+		}
+		p.JobInfos = append(p.JobInfos, j)
+		// As with contacts, an organization with units or parameters is
+		// kept as written.
+		if isOrgLossy(org) {
+			org.Params = cloneParams(org.Params, "X-CRM-CURRENT")
+			keepAsWritten(org)
+		}
+	}
+	for _, title := range titles[min(len(orgs), len(titles)):] {
+		keep(Property{Name: "TITLE", Value: Escape(title)})
+	}
+
+	if p.FirstName == "" && !p.LastName.Valid {
+		p.FirstName, p.LastName = splitFormattedName(c)
+	}
+	if p.FirstName == "" {
+		if !p.LastName.Valid {
+			return person.Person{}, ErrNoName
+		}
+		// A person needs a first name; a card may only have a family name.
+		p.FirstName, p.LastName = p.LastName.String, sql.NullString{}
+	}
+
+	p.VCardExtra = strings.Join(append(kept, extra...), "\n")
+	return p, nil
+}
+
+// FromPerson converts the person to a card of the version, restoring the
+// properties kept in VCardExtra.
+func FromPerson(p *person.Person, version Version) Card {
+	c := Card{Version: version}
+
+	n := []string{p.LastName.String, p.FirstName, p.SecondName.String, "", ""}
+	var extra []Property
+	// Properties kept as written for the contacts and jobs they map to, by
+	// contactKey and company. Those of contacts and jobs removed since are
+	// dropped.
+	contacts := make(map[string][]Property)
+	orgs := make(map[string][]Property)
+	for _, line := range strings.Split(p.VCardExtra, "\n") {
+		if line == "" {
+			continue
+		}
+		prop, err := parseLine(line)
+		if err != nil {
+			continue
+		}
+		if prop.Name == "X-CRM-N" {
+			// Honorific prefixes and suffixes.
+			stored := splitUnescaped(prop.Value, ';')
+			n[3], n[4] = unescape(component(stored, 3)), unescape(component(stored, 4))
+			continue
+		}
+		if prop.Name == "ORG" {
+			if company := component(prop.Components(), 0); company != "" {
+				orgs[company] = append(orgs[company], prop)
+				continue
+			}
+		}
+		if ci, ok := contactOf(prop); ok {
+			contacts[contactKey(ci)] = append(contacts[contactKey(ci)], prop)
+			continue
+		}
+		extra = append(extra, prop)
+	}
+
+	fn := strings.Join(strings.Fields(strings.Join([]string{n[3], formattedName(p), n[4]}, " ")), " ")
+	for i := range n {
+		n[i] = Escape(n[i])
+	}
+	c.Add(Property{Name: "N", Value: strings.Join(n, ";")})
+	c.Add(Property{Name: "FN", Value: Escape(fn)})
+
+	if p.BirthDate.Valid {
+		layout := "2006-01-02"
+		if version == V4 {
+			layout = "20060102"
+		}
+		c.Add(Property{Name: "BDAY", Value: p.BirthDate.Time.Format(layout)})
+	}
+
+	for _, ci := range p.ContactInfos {
+		if kept := contacts[contactKey(ci)]; len(kept) > 0 {
+			contacts[contactKey(ci)] = kept[1:]
+			c.Add(withPreferred(kept[0], ci.Primary, version))
+			continue
+		}
+		c.Add(contactProperty(ci, version))
+	}
+
+	for _, j := range p.JobInfos {
+		org := Property{Name: "ORG", Value: Escape(j.Company)}
+		if kept := orgs[j.Company]; len(kept) > 0 {
+			orgs[j.Company] = kept[1:]
+			org = kept[0]
+		}
+		if !j.Current {
+			org.Params = cloneParams(org.Params)
+			org.Params["X-CRM-CURRENT"] = []string{"false"}
+		}
+		c.Add(org)
+		if j.Position != "" {
+			c.Add(Property{Name: "TITLE", Value: Escape(j.Position)})
+		}
+	}
+
+	c.Properties = append(c.Properties, extra...)
+	return c
+}
+
+// contactOf returns the contact a property maps to, normalized. It returns
+// false for properties of other kinds and for data no contact can hold.
+func contactOf(prop Property) (person.ContactInfo, bool) {
+	var (
+		method person.ContactMethod
+		data   = prop.Text()
+	)
+
+	switch prop.Name {
+	case "TEL":
+		method, data = person.Phone, strings.TrimPrefix(data, "tel:")
+	case "EMAIL":
+		method = person.Email
+	case "URL":
+		method = person.URL
+		if isTelegramURL(data) {
+			method = person.Telegram
+		}
+	case "IMPP":
+		scheme, handle, _ := strings.Cut(data, ":")
+		switch strings.ToLower(scheme) {
+		case "whatsapp":
+			method = person.WhatsApp
+		case "telegram", "tg":
+			method = person.Telegram
+		default:
+			return person.ContactInfo{}, false
+		}
+		data = handle
+	case "X-SOCIALPROFILE":
+		if !prop.HasType("telegram") {
+			return person.ContactInfo{}, false
+		}
+		if users := prop.Params["X-USER"]; len(users) > 0 {
+			data = users[0]
+		}
+		method, data = person.Telegram, strings.TrimPrefix(data, "x-apple:")
+	case "ADR":
+		var parts []string
+		for _, part := range prop.Components() {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		method, data = person.Address, strings.Join(parts, ", ")
+	case "X-CRM-OTHER":
+		method = person.Other
+	default:
+		return person.ContactInfo{}, false
+	}
+
+	ci, err := person.ContactInfo{Method: method, Data: data}.Normalize()
+	return ci, err == nil
+}
+
+func contactKey(ci person.ContactInfo) string {
+	return string(ci.Method) + ":" + ci.Data
+}
+
+// plainTypes are the TYPE values contactProperty writes, besides pref,
+// which a contact property can have without them being lost.
+var plainTypes = map[string][]string{
+	"TEL":   {"cell", "voice"},
+	"EMAIL": {"internet"},
+}
+
+// isLossy reports whether the contact a property maps to leaves out
+// something of it: a group that ties it to other properties, as iOS writes
+// labels, a type such as WORK, any other parameter, or the parts of an
+// address other than the street.
+func isLossy(prop Property) bool {
+	if prop.Group != "" {
+		return true
+	}
+
+	for name, values := range prop.Params {
+		switch name {
+		case "PREF", "VALUE":
+		case "TYPE":
+			for _, v := range values {
+				plain := func(t string) bool { return strings.EqualFold(t, v) }
+				if !strings.EqualFold(v, "pref") && !slices.ContainsFunc(plainTypes[prop.Name], plain) {
+					return true
+				}
+			}
+		default:
+			return true
+		}
+	}
+
+	if prop.Name == "ADR" {
+		for i, part := range prop.Components() {
+			// The street, where the whole address goes on export.
+			if i != 2 && strings.TrimSpace(part) != "" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isOrgLossy reports whether a job leaves out something of the ORG it is
+// read from: a group, parameters or organizational units.
+func isOrgLossy(org Property) bool {
+	if org.Group != "" || len(cloneParams(org.Params, "X-CRM-CURRENT")) > 0 {
+		return true
+	}
+
+	for _, unit := range org.Components()[1:] {
+		if strings.TrimSpace(unit) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// withPreferred returns the property marked as preferred or not, the way
+// contactProperty marks it.
+func withPreferred(prop Property, preferred bool, version Version) Property {
+	prop.Params = cloneParams(prop.Params, "PREF")
+	prop.Params["TYPE"] = slices.DeleteFunc(prop.Params["TYPE"], func(t string) bool {
+		return strings.EqualFold(t, "pref")
+	})
+	if len(prop.Params["TYPE"]) == 0 {
+		delete(prop.Params, "TYPE")
+	}
+
+	if preferred {
+		if version == V4 {
+			prop.Params["PREF"] = []string{"1"}
+		} else {
+			addType(prop.Params, "pref", version)
+		}
+	}
+
+	if len(prop.Params) == 0 {
+		prop.Params = nil
+	}
+	return prop
+}
+
+// cloneParams returns a copy of the params without the named ones, never
+// nil.
+func cloneParams(params Params, drop ...string) Params {
+	clone := make(Params, len(params))
+	for name, values := range params {
+		if !slices.Contains(drop, name) {
+			clone[name] = slices.Clone(values)
+		}
+	}
+	return clone
+}
+
+func contactProperty(ci person.ContactInfo, version Version) Property {
+	prop := Property{Params: make(Params)}
+
+	switch ci.Method {
+	case person.Phone:
+		prop.Name = "TEL"
+		if version == V4 {
+			prop.Params["VALUE"] = []string{"uri"}
+			prop.Value = "tel:" + ci.Data
+		} else {
+			prop.Value = Escape(ci.Data)
+		}
+		addType(prop.Params, "cell", version)
+	case person.Email:
+		prop.Name = "EMAIL"
+		prop.Value = Escape(ci.Data)
+		if version == V3 {
+			addType(prop.Params, "internet", version)
+		}
+	case person.Telegram:
+		prop.Name = "URL"
+		prop.Value = "https://t.me/" + ci.Data
+	case person.WhatsApp:
+		prop.Name = "IMPP"
+		prop.Value = "whatsapp:" + ci.Data
+	case person.URL:
+		prop.Name = "URL"
+		prop.Value = ci.Data
+	case person.Address:
+		prop.Name = "ADR"
+		prop.Value = ";;" + Escape(ci.Data) + ";;;;"
+	default:
+		prop.Name = "X-CRM-OTHER"
+		prop.Value = Escape(ci.Data)
+	}
+
+	if ci.Primary {
+		if version == V4 {
+			prop.Params["PREF"] = []string{"1"}
+		} else {
+			addType(prop.Params, "pref", version)
+		}
+	}
+
+	if len(prop.Params) == 0 {
+		prop.Params = nil
+	}
+	return prop
+}
+
+// addType adds a TYPE value, upper case as version 3.0 cards usually have
+// them.
+func addType(params Params, t string, version Version) {
+	if version == V3 {
+		t = strings.ToUpper(t)
+	}
+	params["TYPE"] = append(params["TYPE"], t)
+}
+
+// isPreferred reports whether the property is marked as preferred: with a
+// PREF parameter in version 4.0, and a pref type before.
+func isPreferred(prop Property) bool {
+	_, ok := prop.Params["PREF"]
+	return ok || prop.HasType("pref")
+}
+
+func isTelegramURL(s string) bool {
+	s = strings.ToLower(s)
+	for _, prefix := range []string{"https://", "http://", "www."} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	return strings.HasPrefix(s, "t.me/") || strings.HasPrefix(s, "telegram.me/")
+}
+
+func parseBirthday(s string) (time.Time, bool) {
+	// Drop a time, as in 1990-05-17T00:00:00Z.
+	date, _, _ := strings.Cut(s, "T")
+	for _, layout := range bdayLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// splitFormattedName splits the FN of a card without N into a first name
+// and the rest. The card of an organization, named after it or marked as
+// one by iOS, is named after the organization as a whole.
+func splitFormattedName(c *Card) (string, sql.NullString) {
+	var org string
+	if orgs := c.Get("ORG"); len(orgs) > 0 {
+		org = component(orgs[0].Components(), 0)
+	}
+
+	fn := c.Get("FN")
+	if len(fn) == 0 {
+		return org, sql.NullString{}
+	}
+
+	name := strings.TrimSpace(fn[0].Text())
+	showAs := c.Get("X-ABSHOWAS")
+	if org != "" && (strings.EqualFold(name, org) || len(showAs) > 0 && strings.EqualFold(showAs[0].Text(), "COMPANY")) {
+		return org, sql.NullString{}
+	}
+
+	first, last, _ := strings.Cut(name, " ")
+	return first, nullString(strings.TrimSpace(last))
+}
+
+func formattedName(p *person.Person) string {
+	return strings.Join(strings.Fields(strings.Join([]string{p.FirstName, p.SecondName.String, p.LastName.String}, " ")), " ")
+}
+
+func component(parts []string, i int) string {
+	if i < len(parts) {
+		return strings.TrimSpace(parts[i])
+	}
+	return ""
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
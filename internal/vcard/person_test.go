@@ -0,0 +1,201 @@
+package vcard
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lincentpega/personal-crm/internal/models/person"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func decodeFile(t *testing.T, name string) []person.Person {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+
+	cards, err := Decode(f)
+	require.NoError(t, err)
+
+	persons := make([]person.Person, len(cards))
+	for i := range cards {
+		persons[i], err = ToPerson(&cards[i])
+		require.NoError(t, err)
+	}
+	return persons
+}
+
+func encodePersons(t *testing.T, persons []person.Person, version Version) []byte {
+	t.Helper()
+
+	cards := make([]Card, len(persons))
+	for i := range persons {
+		cards[i] = FromPerson(&persons[i], version)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, cards))
+	return buf.Bytes()
+}
+
+// TestGolden converts real exports to persons and back and compares the
+// result with the golden files. Run with -update after intended changes.
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.vcf"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".vcf")
+		persons := decodeFile(t, filepath.Base(file))
+
+		for _, version := range []Version{V3, V4} {
+			t.Run(name+"/"+string(version), func(t *testing.T) {
+				got := encodePersons(t, persons, version)
+
+				golden := filepath.Join("testdata", name+".v"+string(version[0])+".golden")
+				if *update {
+					require.NoError(t, os.WriteFile(golden, got, 0o644))
+				}
+
+				want, err := os.ReadFile(golden)
+				require.NoError(t, err)
+				assert.Equal(t, string(want), string(got))
+
+				// Exporting what was imported must not lose anything.
+				cards, err := Decode(bytes.NewReader(got))
+				require.NoError(t, err)
+				require.Len(t, cards, len(persons))
+				for i := range cards {
+					p, err := ToPerson(&cards[i])
+					require.NoError(t, err)
+					assert.Equal(t, persons[i], p)
+				}
+			})
+		}
+	}
+}
+
+func TestToPersonAndroid(t *testing.T) {
+	persons := decodeFile(t, "android.vcf")
+	require.Len(t, persons, 2)
+
+	p := persons[0]
+	assert.Equal(t, "Ivan", p.FirstName)
+	assert.Equal(t, "Sergeevich", p.SecondName.String)
+	assert.Equal(t, "Petrov", p.LastName.String)
+	assert.Equal(t, time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC), p.BirthDate.Time)
+	assert.Equal(t, []person.ContactInfo{
+		{Method: person.Phone, Data: "+79161234567", Primary: true},
+		{Method: person.Phone, Data: "+74955550102"},
+		{Method: person.Email, Data: "ivan.petrov@example.com"},
+	}, p.ContactInfos)
+	assert.Equal(t, []person.JobInfo{{Company: "Acme", Position: "Engineer", Current: true}}, p.JobInfos)
+
+	// A local number can't be stored as a phone but isn't dropped.
+	assert.Contains(t, p.VCardExtra, "TEL;TYPE=HOME:8 495 555 01 03")
+	assert.Contains(t, p.VCardExtra, `NOTE:Met at the conference\nin Kazan`)
+	assert.Contains(t, p.VCardExtra, "X-ANDROID-CUSTOM:")
+
+	p = persons[1]
+	assert.Equal(t, "Анна", p.FirstName)
+	assert.Equal(t, "Смирнова", p.LastName.String)
+	assert.Equal(t, []person.ContactInfo{
+		{Method: person.Phone, Data: "+79031234567"},
+		{Method: person.Address, Data: "ул. Ленина, 1, Москва, 101000"},
+	}, p.ContactInfos)
+}
+
+func TestToPersonIOS(t *testing.T) {
+	persons := decodeFile(t, "ios.vcf")
+	require.Len(t, persons, 1)
+
+	p := persons[0]
+	assert.Equal(t, "John", p.FirstName)
+	assert.Equal(t, "Appleseed", p.LastName.String)
+	assert.Equal(t, []person.ContactInfo{
+		{Method: person.Email, Data: "john.appleseed@apple.com", Primary: true},
+		{Method: person.Email, Data: "johnny@icloud.com"},
+		{Method: person.Phone, Data: "+14085550123", Primary: true},
+		{Method: person.Phone, Data: "+14085550199"},
+		{Method: person.Address, Data: "1 Apple Park Way, Cupertino, CA, 95014, United States", Primary: true},
+		{Method: person.URL, Data: "https://www.apple.com", Primary: true},
+		{Method: person.Telegram, Data: "johnny_seed"},
+		{Method: person.WhatsApp, Data: "+14085550123", Primary: true},
+	}, p.ContactInfos)
+	assert.Equal(t, []person.JobInfo{{Company: "Apple Inc.", Position: "Evangelist", Current: true}}, p.JobInfos)
+	assert.Contains(t, p.VCardExtra, "NICKNAME:Johnny")
+	assert.Contains(t, p.VCardExtra, "item3.X-ABDATE;TYPE=pref:2015-06-08")
+
+	c := FromPerson(&p, V3)
+	assert.Equal(t, `Appleseed;John;;Dr.;`, c.Get("N")[0].Value)
+
+	// What the contacts and jobs don't hold is written back as it was.
+	assert.Equal(t, "ORG:Apple Inc.;Developer Relations;", c.Get("ORG")[0].String())
+	assert.Equal(t, "TEL;TYPE=IPHONE,CELL,VOICE:+1 408 555 0199", c.Get("TEL")[1].String())
+	assert.Equal(t, "item1.ADR;TYPE=WORK,PREF:;;1 Apple Park Way;Cupertino;CA;95014;United States", c.Get("ADR")[0].String())
+	assert.Equal(t, "item2.URL;TYPE=PREF:https://www.apple.com", c.Get("URL")[0].String())
+
+	// Whether one is preferred follows the contact, and one of a contact
+	// since removed is dropped.
+	p.ContactInfos[0].Primary, p.ContactInfos[1].Primary = false, true
+	p.ContactInfos = slices.DeleteFunc(p.ContactInfos, func(ci person.ContactInfo) bool { return ci.Method == person.Address })
+	c = FromPerson(&p, V4)
+	assert.Equal(t, "EMAIL;TYPE=INTERNET,WORK:John.Appleseed@apple.com", c.Get("EMAIL")[0].String())
+	assert.Equal(t, "EMAIL;TYPE=INTERNET,HOME;PREF=1:johnny@icloud.com", c.Get("EMAIL")[1].String())
+	assert.Empty(t, c.Get("ADR"))
+	assert.Equal(t, "item1.X-ABADR:us", c.Get("X-ABADR")[0].String())
+}
+
+func TestToPersonGoogle(t *testing.T) {
+	persons := decodeFile(t, "google.vcf")
+	require.Len(t, persons, 3)
+
+	assert.Equal(t, "Maria", persons[0].FirstName)
+	assert.False(t, persons[0].BirthDate.Valid)
+	assert.Contains(t, persons[0].VCardExtra, "BDAY:--0312")
+	assert.Contains(t, persons[0].ContactInfos, person.ContactInfo{Method: person.Telegram, Data: "maria_garcia"})
+
+	assert.Equal(t, "Tom", persons[1].FirstName)
+	assert.False(t, persons[1].LastName.Valid)
+
+	// A card of an organization is named after it as a whole.
+	assert.Equal(t, "Северный Завод", persons[2].FirstName)
+	assert.False(t, persons[2].LastName.Valid)
+}
+
+func TestFromPerson(t *testing.T) {
+	p := person.Person{
+		FirstName: "Jane",
+		LastName:  nullString("Doe"),
+		ContactInfos: []person.ContactInfo{
+			{Method: person.Phone, Data: "+79161234567", Primary: true},
+			{Method: person.Telegram, Data: "jane_doe"},
+			{Method: person.Other, Data: "skype: jane"},
+		},
+		JobInfos: []person.JobInfo{
+			{Company: "Acme", Position: "CEO", Current: true},
+			{Company: "Initech"},
+		},
+	}
+
+	c := FromPerson(&p, V4)
+	assert.Equal(t, "Jane Doe", c.Get("FN")[0].Text())
+	assert.Equal(t, "TEL;TYPE=cell;PREF=1;VALUE=uri:tel:+79161234567", c.Get("TEL")[0].String())
+	assert.Equal(t, "URL:https://t.me/jane_doe", c.Get("URL")[0].String())
+	assert.Equal(t, "skype: jane", c.Get("X-CRM-OTHER")[0].Text())
+	assert.Equal(t, "ORG;X-CRM-CURRENT=false:Initech", c.Get("ORG")[1].String())
+
+	_, err := ToPerson(&Card{})
+	assert.ErrorIs(t, err, ErrNoName)
+}
@@ -0,0 +1,376 @@
+// Package vcard reads and writes vCard files (RFC 2426 and RFC 6350) and
+// converts the cards to and from persons. Version 2.1 cards, which Android
+// still exports, are read as well.
+package vcard
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+type Version string
+
+const (
+	V3 Version = "3.0"
+	V4 Version = "4.0"
+)
+
+// maxLineLength is the length in octets lines are folded at.
+const maxLineLength = 75
+
+var (
+	ErrNoCards       = errors.New("vcard: no cards found")
+	ErrUnclosedCard  = errors.New("vcard: card is missing END:VCARD")
+	ErrMalformedLine = errors.New("vcard: malformed content line")
+)
+
+// Params are the parameters of a property. Names are upper case.
+type Params map[string][]string
+
+// Property is a content line of a card. Value is kept as it is written,
+// escaped; use Text or Components to read it.
+type Property struct {
+	Group  string
+	Name   string
+	Params Params
+	Value  string
+}
+
+// Card is a single vCard.
+type Card struct {
+	Version    Version
+	Properties []Property
+}
+
+// Get returns the properties with the name.
+func (c *Card) Get(name string) []Property {
+	var ps []Property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// Add appends a property.
+func (c *Card) Add(p Property) {
+	c.Properties = append(c.Properties, p)
+}
+
+// Text returns the value as text, unescaped.
+func (p Property) Text() string {
+	return unescape(p.Value)
+}
+
+// Components returns the components of a structured value such as N or
+// ADR, unescaped.
+func (p Property) Components() []string {
+	parts := splitUnescaped(p.Value, ';')
+	for i := range parts {
+		parts[i] = unescape(parts[i])
+	}
+	return parts
+}
+
+// HasType reports whether the TYPE parameter includes t, compared case
+// insensitively.
+func (p Property) HasType(t string) bool {
+	for _, v := range p.Params["TYPE"] {
+		for _, v := range strings.Split(v, ",") {
+			if strings.EqualFold(v, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Decode reads all cards from r.
+func Decode(r io.Reader) ([]Card, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cards []Card
+		card  *Card
+	)
+	for _, line := range lines {
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case p.Name == "BEGIN" && strings.EqualFold(p.Value, "VCARD"):
+			card = &Card{Version: V3}
+		case card == nil:
+			// Anything outside of a card is ignored.
+		case p.Name == "END" && strings.EqualFold(p.Value, "VCARD"):
+			cards = append(cards, *card)
+			card = nil
+		case p.Name == "VERSION":
+			card.Version = Version(p.Value)
+		default:
+			card.Add(p)
+		}
+	}
+
+	if card != nil {
+		return nil, ErrUnclosedCard
+	}
+
+	if len(cards) == 0 {
+		return nil, ErrNoCards
+	}
+
+	return cards, nil
+}
+
+// readLines returns the content lines of r, unfolded. Version 2.1 quoted
+// printable values continue on the next line after a trailing =.
+func readLines(r io.Reader) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	var lines []string
+	qpContinues := false
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		switch {
+		case qpContinues:
+			lines[len(lines)-1] = strings.TrimSuffix(lines[len(lines)-1], "=") + line
+		case len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0:
+			lines[len(lines)-1] += line[1:]
+			continue
+		case strings.TrimSpace(line) == "":
+			continue
+		default:
+			lines = append(lines, line)
+		}
+
+		last := lines[len(lines)-1]
+		qpContinues = strings.HasSuffix(last, "=") && isQuotedPrintable(last)
+	}
+
+	return lines, sc.Err()
+}
+
+func isQuotedPrintable(line string) bool {
+	head, _, _ := strings.Cut(strings.ToUpper(line), ":")
+	return strings.Contains(head, "QUOTED-PRINTABLE")
+}
+
+// parseLine parses a content line, group.NAME;PARAM=VALUE:value.
+func parseLine(line string) (Property, error) {
+	colon := -1
+	quoted := false
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		}
+		if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return Property{}, fmt.Errorf("%w: %q", ErrMalformedLine, line)
+	}
+
+	head, value := line[:colon], line[colon+1:]
+	parts := splitQuoted(head, ';')
+
+	p := Property{Name: strings.ToUpper(parts[0]), Params: make(Params), Value: value}
+	if group, name, ok := strings.Cut(p.Name, "."); ok {
+		p.Group, p.Name = strings.ToLower(group), name
+	}
+	if p.Name == "" {
+		return Property{}, fmt.Errorf("%w: %q", ErrMalformedLine, line)
+	}
+
+	for _, param := range parts[1:] {
+		name, v, ok := strings.Cut(param, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if !ok {
+			// Version 2.1 allows bare types, as in TEL;CELL.
+			v, name = name, "TYPE"
+		}
+		for _, v := range splitQuoted(v, ',') {
+			p.Params[name] = append(p.Params[name], strings.Trim(v, `"`))
+		}
+	}
+
+	if enc := p.Params["ENCODING"]; len(enc) > 0 && strings.EqualFold(enc[0], "QUOTED-PRINTABLE") {
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(p.Value)))
+		if err != nil {
+			return Property{}, fmt.Errorf("%w: %v", ErrMalformedLine, err)
+		}
+		// Decoded line breaks are escaped, as the value must stay on one
+		// content line.
+		p.Value = strings.NewReplacer("\r\n", `\n`, "\n", `\n`).Replace(string(decoded))
+		delete(p.Params, "ENCODING")
+		delete(p.Params, "CHARSET")
+	}
+
+	return p, nil
+}
+
+// splitQuoted splits s at sep outside of double quotes.
+func splitQuoted(s string, sep rune) []string {
+	var (
+		parts  []string
+		start  int
+		quoted bool
+	)
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitUnescaped splits s at sep not preceded by a backslash.
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`)
+
+// Escape escapes text for a property value.
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// Encode writes the cards to w.
+func Encode(w io.Writer, cards []Card) error {
+	bw := bufio.NewWriter(w)
+
+	for _, c := range cards {
+		writeLine(bw, "BEGIN:VCARD")
+		writeLine(bw, "VERSION:"+string(c.Version))
+		for _, p := range c.Properties {
+			writeLine(bw, p.String())
+		}
+		writeLine(bw, "END:VCARD")
+	}
+
+	return bw.Flush()
+}
+
+// String returns the property as an unfolded content line.
+func (p Property) String() string {
+	var b strings.Builder
+	if p.Group != "" {
+		b.WriteString(p.Group)
+		b.WriteByte('.')
+	}
+	b.WriteString(p.Name)
+
+	for _, name := range sortedKeys(p.Params) {
+		values := make([]string, len(p.Params[name]))
+		for i, v := range p.Params[name] {
+			if strings.ContainsAny(v, `:;,`) {
+				v = `"` + v + `"`
+			}
+			values[i] = v
+		}
+		fmt.Fprintf(&b, ";%s=%s", name, strings.Join(values, ","))
+	}
+
+	b.WriteByte(':')
+	b.WriteString(p.Value)
+	return b.String()
+}
+
+// writeLine writes the content line folded to maxLineLength octets, without
+// splitting UTF-8 sequences.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation line counts towards its
+		// length.
+		limit = maxLineLength - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+func sortedKeys(params Params) []string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	// TYPE comes first, as it is usually written, and the rest in
+	// alphabetical order to keep the output stable.
+	slices.SortFunc(keys, func(a, b string) int {
+		switch {
+		case a == b:
+			return 0
+		case a == "TYPE":
+			return -1
+		case b == "TYPE":
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	return keys
+}
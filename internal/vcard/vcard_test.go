@@ -0,0 +1,100 @@
+package vcard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	input := "\ufeffBEGIN:VCARD\r\n" +
+		"VERSION:4.0\r\n" +
+		"FN:Jane\r\n" +
+		"  Doe\r\n" +
+		"item1.EMAIL;TYPE=work,pref;PREF=1:jane@example.com\r\n" +
+		"NOTE:one\\, two\\; three\\nfour\r\n" +
+		"X-ODD;LABEL=\"a:b;c\":value\r\n" +
+		"END:VCARD\r\n" +
+		"BEGIN:VCARD\n" +
+		"N:Doe;John;;;\n" +
+		"END:VCARD\n"
+
+	cards, err := Decode(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, cards, 2)
+
+	c := cards[0]
+	assert.Equal(t, V4, c.Version)
+	assert.Equal(t, "Jane Doe", c.Get("FN")[0].Text())
+
+	email := c.Get("EMAIL")[0]
+	assert.Equal(t, "item1", email.Group)
+	assert.True(t, email.HasType("WORK"))
+	assert.True(t, email.HasType("pref"))
+	assert.Equal(t, []string{"1"}, email.Params["PREF"])
+
+	assert.Equal(t, "one, two; three\nfour", c.Get("NOTE")[0].Text())
+	assert.Equal(t, []string{"a:b;c"}, c.Get("X-ODD")[0].Params["LABEL"])
+
+	assert.Equal(t, V3, cards[1].Version)
+	assert.Equal(t, []string{"Doe", "John", "", "", ""}, cards[1].Get("N")[0].Components())
+}
+
+func TestDecodeVersion21(t *testing.T) {
+	input := "BEGIN:VCARD\r\n" +
+		"VERSION:2.1\r\n" +
+		"TEL;CELL;PREF:+79161234567\r\n" +
+		"NOTE;CHARSET=UTF-8;ENCODING=QUOTED-PRINTABLE:=D0=9F=D1=80=D0=B8=D0=B2=D0=B5=D1=82=0D=0A=\r\n" +
+		"=D0=BC=D0=B8=D1=80\r\n" +
+		"END:VCARD\r\n"
+
+	cards, err := Decode(strings.NewReader(input))
+	require.NoError(t, err)
+
+	tel := cards[0].Get("TEL")[0]
+	assert.True(t, tel.HasType("cell"))
+	assert.True(t, tel.HasType("pref"))
+
+	note := cards[0].Get("NOTE")[0]
+	assert.Empty(t, note.Params)
+	assert.Equal(t, "Привет\nмир", note.Text())
+}
+
+func TestDecodeErrors(t *testing.T) {
+	_, err := Decode(strings.NewReader("hello\r\n"))
+	assert.ErrorIs(t, err, ErrMalformedLine)
+
+	_, err = Decode(strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrNoCards)
+
+	_, err = Decode(strings.NewReader("BEGIN:VCARD\r\nFN:Jane\r\n"))
+	assert.ErrorIs(t, err, ErrUnclosedCard)
+}
+
+func TestEncode(t *testing.T) {
+	note := strings.Repeat("заметка ", 20)
+	c := Card{Version: V3}
+	c.Add(Property{Name: "FN", Value: Escape("Doe, Jane")})
+	c.Add(Property{Name: "EMAIL", Params: Params{"X-LABEL": {"a;b"}, "TYPE": {"INTERNET", "PREF"}}, Value: "jane@example.com"})
+	c.Add(Property{Name: "NOTE", Value: Escape(note)})
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, []Card{c}))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	assert.Equal(t, "BEGIN:VCARD", lines[0])
+	assert.Equal(t, "VERSION:3.0", lines[1])
+	assert.Equal(t, `FN:Doe\, Jane`, lines[2])
+	assert.Equal(t, `EMAIL;TYPE=INTERNET,PREF;X-LABEL="a;b":jane@example.com`, lines[3])
+	assert.Equal(t, "END:VCARD", lines[len(lines)-1])
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(line), maxLineLength, line)
+	}
+
+	cards, err := Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, note, cards[0].Get("NOTE")[0].Text())
+}